@@ -21,14 +21,376 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type AuthorizationAction int32
+
+const (
+	AuthorizationAction_AUTHORIZATION_ACTION_ALLOW AuthorizationAction = 0
+	AuthorizationAction_AUTHORIZATION_ACTION_DENY  AuthorizationAction = 1
+)
+
+// Enum value maps for AuthorizationAction.
+var (
+	AuthorizationAction_name = map[int32]string{
+		0: "AUTHORIZATION_ACTION_ALLOW",
+		1: "AUTHORIZATION_ACTION_DENY",
+	}
+	AuthorizationAction_value = map[string]int32{
+		"AUTHORIZATION_ACTION_ALLOW": 0,
+		"AUTHORIZATION_ACTION_DENY":  1,
+	}
+)
+
+func (x AuthorizationAction) Enum() *AuthorizationAction {
+	p := new(AuthorizationAction)
+	*p = x
+	return p
+}
+
+func (x AuthorizationAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AuthorizationAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_mesh_proto_enumTypes[0].Descriptor()
+}
+
+func (AuthorizationAction) Type() protoreflect.EnumType {
+	return &file_api_proto_mesh_proto_enumTypes[0]
+}
+
+func (x AuthorizationAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AuthorizationAction.Descriptor instead.
+func (AuthorizationAction) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{0}
+}
+
+// StringMatch is how a request value is compared with the expected one
+type StringMatch int32
+
+const (
+	StringMatch_STRING_MATCH_EXACT   StringMatch = 0 // Same value
+	StringMatch_STRING_MATCH_PREFIX  StringMatch = 1 // Value starts with the expected one
+	StringMatch_STRING_MATCH_REGEX   StringMatch = 2 // Whole value matches the RE2 regular expression
+	StringMatch_STRING_MATCH_PRESENT StringMatch = 3 // Present, whatever the value
+)
+
+// Enum value maps for StringMatch.
+var (
+	StringMatch_name = map[int32]string{
+		0: "STRING_MATCH_EXACT",
+		1: "STRING_MATCH_PREFIX",
+		2: "STRING_MATCH_REGEX",
+		3: "STRING_MATCH_PRESENT",
+	}
+	StringMatch_value = map[string]int32{
+		"STRING_MATCH_EXACT":   0,
+		"STRING_MATCH_PREFIX":  1,
+		"STRING_MATCH_REGEX":   2,
+		"STRING_MATCH_PRESENT": 3,
+	}
+)
+
+func (x StringMatch) Enum() *StringMatch {
+	p := new(StringMatch)
+	*p = x
+	return p
+}
+
+func (x StringMatch) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (StringMatch) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_mesh_proto_enumTypes[1].Descriptor()
+}
+
+func (StringMatch) Type() protoreflect.EnumType {
+	return &file_api_proto_mesh_proto_enumTypes[1]
+}
+
+func (x StringMatch) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use StringMatch.Descriptor instead.
+func (StringMatch) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{1}
+}
+
+// RateLimitKey is what identifies a client for rate limiting
+type RateLimitKey int32
+
+const (
+	RateLimitKey_RATE_LIMIT_KEY_CLIENT_IP RateLimitKey = 0 // Client address (RemoteAddr)
+	RateLimitKey_RATE_LIMIT_KEY_SUBJECT   RateLimitKey = 1 // Authenticated subject (auth_required routes only)
+	RateLimitKey_RATE_LIMIT_KEY_HEADER    RateLimitKey = 2 // Value of a request header
+)
+
+// Enum value maps for RateLimitKey.
+var (
+	RateLimitKey_name = map[int32]string{
+		0: "RATE_LIMIT_KEY_CLIENT_IP",
+		1: "RATE_LIMIT_KEY_SUBJECT",
+		2: "RATE_LIMIT_KEY_HEADER",
+	}
+	RateLimitKey_value = map[string]int32{
+		"RATE_LIMIT_KEY_CLIENT_IP": 0,
+		"RATE_LIMIT_KEY_SUBJECT":   1,
+		"RATE_LIMIT_KEY_HEADER":    2,
+	}
+)
+
+func (x RateLimitKey) Enum() *RateLimitKey {
+	p := new(RateLimitKey)
+	*p = x
+	return p
+}
+
+func (x RateLimitKey) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RateLimitKey) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_mesh_proto_enumTypes[2].Descriptor()
+}
+
+func (RateLimitKey) Type() protoreflect.EnumType {
+	return &file_api_proto_mesh_proto_enumTypes[2]
+}
+
+func (x RateLimitKey) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RateLimitKey.Descriptor instead.
+func (RateLimitKey) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{2}
+}
+
+// RetryOn is a failure a request can be retried on
+type RetryOn int32
+
+const (
+	RetryOn_RETRY_ON_CONNECT_FAILURE     RetryOn = 0 // The endpoint could not be reached
+	RetryOn_RETRY_ON_RESET               RetryOn = 1 // The connection was reset or closed before the response
+	RetryOn_RETRY_ON_BAD_GATEWAY         RetryOn = 2 // 502 from the endpoint, or any other upstream error
+	RetryOn_RETRY_ON_SERVICE_UNAVAILABLE RetryOn = 3 // 503 from the endpoint
+	RetryOn_RETRY_ON_GATEWAY_TIMEOUT     RetryOn = 4 // 504 from the endpoint, or the per-try timeout fired
+)
+
+// Enum value maps for RetryOn.
+var (
+	RetryOn_name = map[int32]string{
+		0: "RETRY_ON_CONNECT_FAILURE",
+		1: "RETRY_ON_RESET",
+		2: "RETRY_ON_BAD_GATEWAY",
+		3: "RETRY_ON_SERVICE_UNAVAILABLE",
+		4: "RETRY_ON_GATEWAY_TIMEOUT",
+	}
+	RetryOn_value = map[string]int32{
+		"RETRY_ON_CONNECT_FAILURE":     0,
+		"RETRY_ON_RESET":               1,
+		"RETRY_ON_BAD_GATEWAY":         2,
+		"RETRY_ON_SERVICE_UNAVAILABLE": 3,
+		"RETRY_ON_GATEWAY_TIMEOUT":     4,
+	}
+)
+
+func (x RetryOn) Enum() *RetryOn {
+	p := new(RetryOn)
+	*p = x
+	return p
+}
+
+func (x RetryOn) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RetryOn) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_mesh_proto_enumTypes[3].Descriptor()
+}
+
+func (RetryOn) Type() protoreflect.EnumType {
+	return &file_api_proto_mesh_proto_enumTypes[3]
+}
+
+func (x RetryOn) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RetryOn.Descriptor instead.
+func (RetryOn) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{3}
+}
+
+// HashKey is the source of the consistent hashing key
+type HashKey int32
+
+const (
+	HashKey_HASH_KEY_CLIENT_IP HashKey = 0 // Client address (RemoteAddr)
+	HashKey_HASH_KEY_HEADER    HashKey = 1 // Value of a request header
+	HashKey_HASH_KEY_COOKIE    HashKey = 2 // Value of a cookie
+)
+
+// Enum value maps for HashKey.
+var (
+	HashKey_name = map[int32]string{
+		0: "HASH_KEY_CLIENT_IP",
+		1: "HASH_KEY_HEADER",
+		2: "HASH_KEY_COOKIE",
+	}
+	HashKey_value = map[string]int32{
+		"HASH_KEY_CLIENT_IP": 0,
+		"HASH_KEY_HEADER":    1,
+		"HASH_KEY_COOKIE":    2,
+	}
+)
+
+func (x HashKey) Enum() *HashKey {
+	p := new(HashKey)
+	*p = x
+	return p
+}
+
+func (x HashKey) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (HashKey) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_mesh_proto_enumTypes[4].Descriptor()
+}
+
+func (HashKey) Type() protoreflect.EnumType {
+	return &file_api_proto_mesh_proto_enumTypes[4]
+}
+
+func (x HashKey) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use HashKey.Descriptor instead.
+func (HashKey) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{4}
+}
+
+// LoadBalancerPolicy selects the algorithm used to pick an endpoint
+type LoadBalancerPolicy int32
+
+const (
+	LoadBalancerPolicy_ROUND_ROBIN          LoadBalancerPolicy = 0 // Each endpoint in turn
+	LoadBalancerPolicy_RANDOM               LoadBalancerPolicy = 1 // Uniformly random endpoint
+	LoadBalancerPolicy_LEAST_REQUEST        LoadBalancerPolicy = 2 // Endpoint with the fewest in-flight requests
+	LoadBalancerPolicy_POWER_OF_TWO_CHOICES LoadBalancerPolicy = 3 // Least loaded of two random endpoints
+	LoadBalancerPolicy_RING_HASH            LoadBalancerPolicy = 4 // Consistent hashing on Route.hash_policy, same key same endpoint
+)
+
+// Enum value maps for LoadBalancerPolicy.
+var (
+	LoadBalancerPolicy_name = map[int32]string{
+		0: "ROUND_ROBIN",
+		1: "RANDOM",
+		2: "LEAST_REQUEST",
+		3: "POWER_OF_TWO_CHOICES",
+		4: "RING_HASH",
+	}
+	LoadBalancerPolicy_value = map[string]int32{
+		"ROUND_ROBIN":          0,
+		"RANDOM":               1,
+		"LEAST_REQUEST":        2,
+		"POWER_OF_TWO_CHOICES": 3,
+		"RING_HASH":            4,
+	}
+)
+
+func (x LoadBalancerPolicy) Enum() *LoadBalancerPolicy {
+	p := new(LoadBalancerPolicy)
+	*p = x
+	return p
+}
+
+func (x LoadBalancerPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LoadBalancerPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_mesh_proto_enumTypes[5].Descriptor()
+}
+
+func (LoadBalancerPolicy) Type() protoreflect.EnumType {
+	return &file_api_proto_mesh_proto_enumTypes[5]
+}
+
+func (x LoadBalancerPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LoadBalancerPolicy.Descriptor instead.
+func (LoadBalancerPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{5}
+}
+
+// PathMatch defines how a route path is matched
+// Exact routes are checked first, then the longest matching prefix wins
+type PathMatch int32
+
+const (
+	PathMatch_PATH_MATCH_PREFIX PathMatch = 0 // "/api" matches "/api", "/api/" and "/api/users" (not "/apiary")
+	PathMatch_PATH_MATCH_EXACT  PathMatch = 1 // "/api" matches only "/api"
+)
+
+// Enum value maps for PathMatch.
+var (
+	PathMatch_name = map[int32]string{
+		0: "PATH_MATCH_PREFIX",
+		1: "PATH_MATCH_EXACT",
+	}
+	PathMatch_value = map[string]int32{
+		"PATH_MATCH_PREFIX": 0,
+		"PATH_MATCH_EXACT":  1,
+	}
+)
+
+func (x PathMatch) Enum() *PathMatch {
+	p := new(PathMatch)
+	*p = x
+	return p
+}
+
+func (x PathMatch) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PathMatch) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_mesh_proto_enumTypes[6].Descriptor()
+}
+
+func (PathMatch) Type() protoreflect.EnumType {
+	return &file_api_proto_mesh_proto_enumTypes[6]
+}
+
+func (x PathMatch) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PathMatch.Descriptor instead.
+func (PathMatch) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{6}
+}
+
 // ProxyInfo contains information about a data plane proxy
 type ProxyInfo struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ProxyId       string                 `protobuf:"bytes,1,opt,name=proxy_id,json=proxyId,proto3" json:"proxy_id,omitempty"`          // Unique ID for this proxy (e.g., "proxy-1", "events-proxy")
-	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`                         // Proxy version (e.g., "1.0.0")
-	ListenAddr    string                 `protobuf:"bytes,3,opt,name=listen_addr,json=listenAddr,proto3" json:"listen_addr,omitempty"` // Address proxy is listening on (e.g., "0.0.0.0:8000")
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ProxyId        string                 `protobuf:"bytes,1,opt,name=proxy_id,json=proxyId,proto3" json:"proxy_id,omitempty"`                      // Unique ID for this proxy (e.g., "proxy-1", "events-proxy")
+	Version        string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`                                     // Proxy version (e.g., "1.0.0")
+	ListenAddr     string                 `protobuf:"bytes,3,opt,name=listen_addr,json=listenAddr,proto3" json:"listen_addr,omitempty"`             // Address proxy is listening on (e.g., "0.0.0.0:8000")
+	Namespace      string                 `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`                                 // Namespace of the workload, part of its identity (default: "default")
+	ServiceAccount string                 `protobuf:"bytes,5,opt,name=service_account,json=serviceAccount,proto3" json:"service_account,omitempty"` // Service account of the workload, part of its identity (default: the proxy ID)
+	InstanceId     string                 `protobuf:"bytes,6,opt,name=instance_id,json=instanceId,proto3" json:"instance_id,omitempty"`             // Random per proxy process: a reconnect keeps it, a second proxy with the same ID doesn't
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *ProxyInfo) Reset() {
@@ -82,6 +444,27 @@ func (x *ProxyInfo) GetListenAddr() string {
 	return ""
 }
 
+func (x *ProxyInfo) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ProxyInfo) GetServiceAccount() string {
+	if x != nil {
+		return x.ServiceAccount
+	}
+	return ""
+}
+
+func (x *ProxyInfo) GetInstanceId() string {
+	if x != nil {
+		return x.InstanceId
+	}
+	return ""
+}
+
 // RegistrationResponse is sent when a proxy successfully registers
 type RegistrationResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -135,30 +518,29 @@ func (x *RegistrationResponse) GetMessage() string {
 	return ""
 }
 
-// ConfigUpdate contains routing configuration updates
-// This is what the control plane sends to proxies
-type ConfigUpdate struct {
+// HealthReport is the health of every endpoint a proxy checks
+type HealthReport struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Version       int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"` // Config version number (increments with each update)
-	Routes        []*Route               `protobuf:"bytes,2,rep,name=routes,proto3" json:"routes,omitempty"`    // List of routing rules
+	ProxyId       string                 `protobuf:"bytes,1,opt,name=proxy_id,json=proxyId,proto3" json:"proxy_id,omitempty"` // Proxy sending the report
+	Endpoints     []*EndpointHealth      `protobuf:"bytes,2,rep,name=endpoints,proto3" json:"endpoints,omitempty"`            // Full list, replaces the previous report
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ConfigUpdate) Reset() {
-	*x = ConfigUpdate{}
+func (x *HealthReport) Reset() {
+	*x = HealthReport{}
 	mi := &file_api_proto_mesh_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ConfigUpdate) String() string {
+func (x *HealthReport) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ConfigUpdate) ProtoMessage() {}
+func (*HealthReport) ProtoMessage() {}
 
-func (x *ConfigUpdate) ProtoReflect() protoreflect.Message {
+func (x *HealthReport) ProtoReflect() protoreflect.Message {
 	mi := &file_api_proto_mesh_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -170,50 +552,49 @@ func (x *ConfigUpdate) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ConfigUpdate.ProtoReflect.Descriptor instead.
-func (*ConfigUpdate) Descriptor() ([]byte, []int) {
+// Deprecated: Use HealthReport.ProtoReflect.Descriptor instead.
+func (*HealthReport) Descriptor() ([]byte, []int) {
 	return file_api_proto_mesh_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *ConfigUpdate) GetVersion() int64 {
+func (x *HealthReport) GetProxyId() string {
 	if x != nil {
-		return x.Version
+		return x.ProxyId
 	}
-	return 0
+	return ""
 }
 
-func (x *ConfigUpdate) GetRoutes() []*Route {
+func (x *HealthReport) GetEndpoints() []*EndpointHealth {
 	if x != nil {
-		return x.Routes
+		return x.Endpoints
 	}
 	return nil
 }
 
-// Route defines how to route requests
-type Route struct {
+// EndpointHealth is the health state of one endpoint of a cluster
+type EndpointHealth struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`                                      // Path pattern (e.g., "/api/users", "/api/events")
-	Backend       string                 `protobuf:"bytes,2,opt,name=backend,proto3" json:"backend,omitempty"`                                // Backend address (e.g., "localhost:3000", "users-service:5000")
-	AuthRequired  bool                   `protobuf:"varint,3,opt,name=auth_required,json=authRequired,proto3" json:"auth_required,omitempty"` // Whether this route requires authentication
-	TimeoutMs     int32                  `protobuf:"varint,4,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`          // Request timeout in milliseconds
+	Cluster       string                 `protobuf:"bytes,1,opt,name=cluster,proto3" json:"cluster,omitempty"`   // Cluster name
+	Endpoint      string                 `protobuf:"bytes,2,opt,name=endpoint,proto3" json:"endpoint,omitempty"` // Endpoint address (e.g., "10.0.0.1:5000")
+	Healthy       bool                   `protobuf:"varint,3,opt,name=healthy,proto3" json:"healthy,omitempty"`  // Whether the endpoint receives traffic
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Route) Reset() {
-	*x = Route{}
+func (x *EndpointHealth) Reset() {
+	*x = EndpointHealth{}
 	mi := &file_api_proto_mesh_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Route) String() string {
+func (x *EndpointHealth) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Route) ProtoMessage() {}
+func (*EndpointHealth) ProtoMessage() {}
 
-func (x *Route) ProtoReflect() protoreflect.Message {
+func (x *EndpointHealth) ProtoReflect() protoreflect.Message {
 	mi := &file_api_proto_mesh_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -225,64 +606,3390 @@ func (x *Route) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Route.ProtoReflect.Descriptor instead.
-func (*Route) Descriptor() ([]byte, []int) {
+// Deprecated: Use EndpointHealth.ProtoReflect.Descriptor instead.
+func (*EndpointHealth) Descriptor() ([]byte, []int) {
 	return file_api_proto_mesh_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *Route) GetPath() string {
+func (x *EndpointHealth) GetCluster() string {
 	if x != nil {
-		return x.Path
+		return x.Cluster
 	}
 	return ""
 }
 
-func (x *Route) GetBackend() string {
+func (x *EndpointHealth) GetEndpoint() string {
 	if x != nil {
-		return x.Backend
+		return x.Endpoint
 	}
 	return ""
 }
 
-func (x *Route) GetAuthRequired() bool {
+func (x *EndpointHealth) GetHealthy() bool {
 	if x != nil {
-		return x.AuthRequired
+		return x.Healthy
 	}
 	return false
 }
 
-func (x *Route) GetTimeoutMs() int32 {
+// HealthReportResponse acknowledges a HealthReport
+type HealthReportResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthReportResponse) Reset() {
+	*x = HealthReportResponse{}
+	mi := &file_api_proto_mesh_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthReportResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthReportResponse) ProtoMessage() {}
+
+func (x *HealthReportResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[4]
 	if x != nil {
-		return x.TimeoutMs
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
+	return mi.MessageOf(x)
 }
 
-var File_api_proto_mesh_proto protoreflect.FileDescriptor
+// Deprecated: Use HealthReportResponse.ProtoReflect.Descriptor instead.
+func (*HealthReportResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{4}
+}
 
-const file_api_proto_mesh_proto_rawDesc = "" +
-	"\n" +
-	"\x14api/proto/mesh.proto\x12\x04mesh\"a\n" +
-	"\tProxyInfo\x12\x19\n" +
-	"\bproxy_id\x18\x01 \x01(\tR\aproxyId\x12\x18\n" +
-	"\aversion\x18\x02 \x01(\tR\aversion\x12\x1f\n" +
-	"\vlisten_addr\x18\x03 \x01(\tR\n" +
-	"listenAddr\"J\n" +
-	"\x14RegistrationResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"M\n" +
-	"\fConfigUpdate\x12\x18\n" +
-	"\aversion\x18\x01 \x01(\x03R\aversion\x12#\n" +
-	"\x06routes\x18\x02 \x03(\v2\v.mesh.RouteR\x06routes\"y\n" +
-	"\x05Route\x12\x12\n" +
-	"\x04path\x18\x01 \x01(\tR\x04path\x12\x18\n" +
-	"\abackend\x18\x02 \x01(\tR\abackend\x12#\n" +
-	"\rauth_required\x18\x03 \x01(\bR\fauthRequired\x12\x1d\n" +
+// ConfigUpdate contains routing configuration updates
+// This is what the control plane sends to proxies
+type ConfigUpdate struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	Version               int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`                                                         // Config version number (increments with each update)
+	Routes                []*Route               `protobuf:"bytes,2,rep,name=routes,proto3" json:"routes,omitempty"`                                                            // List of routing rules
+	Auth                  *AuthConfig            `protobuf:"bytes,3,opt,name=auth,proto3" json:"auth,omitempty"`                                                                // Credentials for auth_required routes (unset: proxy uses its local config)
+	Clusters              []*Cluster             `protobuf:"bytes,4,rep,name=clusters,proto3" json:"clusters,omitempty"`                                                        // Groups of upstream endpoints that routes can point to
+	RequestHeaders        *HeaderPolicy          `protobuf:"bytes,5,opt,name=request_headers,json=requestHeaders,proto3" json:"request_headers,omitempty"`                      // Changes to the request headers of every route (unset: X-Forwarded-By: GoMesh-Proxy, empty: none)
+	ResponseHeaders       *HeaderPolicy          `protobuf:"bytes,6,opt,name=response_headers,json=responseHeaders,proto3" json:"response_headers,omitempty"`                   // Changes to the response headers of every route
+	Certificates          []*TlsCertificate      `protobuf:"bytes,7,rep,name=certificates,proto3" json:"certificates,omitempty"`                                                // Served by proxies with TLS enabled, picked by SNI (added to their local ones)
+	WorkloadCertificate   *WorkloadCertificate   `protobuf:"bytes,8,opt,name=workload_certificate,json=workloadCertificate,proto3" json:"workload_certificate,omitempty"`       // Issued to the receiving proxy only, for mTLS inside the mesh (unset: keep the current one)
+	AuthorizationPolicies []*AuthorizationPolicy `protobuf:"bytes,9,rep,name=authorization_policies,json=authorizationPolicies,proto3" json:"authorization_policies,omitempty"` // Checked on every request, before authentication
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *ConfigUpdate) Reset() {
+	*x = ConfigUpdate{}
+	mi := &file_api_proto_mesh_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigUpdate) ProtoMessage() {}
+
+func (x *ConfigUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigUpdate.ProtoReflect.Descriptor instead.
+func (*ConfigUpdate) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ConfigUpdate) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ConfigUpdate) GetRoutes() []*Route {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+func (x *ConfigUpdate) GetAuth() *AuthConfig {
+	if x != nil {
+		return x.Auth
+	}
+	return nil
+}
+
+func (x *ConfigUpdate) GetClusters() []*Cluster {
+	if x != nil {
+		return x.Clusters
+	}
+	return nil
+}
+
+func (x *ConfigUpdate) GetRequestHeaders() *HeaderPolicy {
+	if x != nil {
+		return x.RequestHeaders
+	}
+	return nil
+}
+
+func (x *ConfigUpdate) GetResponseHeaders() *HeaderPolicy {
+	if x != nil {
+		return x.ResponseHeaders
+	}
+	return nil
+}
+
+func (x *ConfigUpdate) GetCertificates() []*TlsCertificate {
+	if x != nil {
+		return x.Certificates
+	}
+	return nil
+}
+
+func (x *ConfigUpdate) GetWorkloadCertificate() *WorkloadCertificate {
+	if x != nil {
+		return x.WorkloadCertificate
+	}
+	return nil
+}
+
+func (x *ConfigUpdate) GetAuthorizationPolicies() []*AuthorizationPolicy {
+	if x != nil {
+		return x.AuthorizationPolicies
+	}
+	return nil
+}
+
+// AuthorizationPolicy allows or denies requests by source identity, path, method and headers
+// DENY policies are checked first, then when any ALLOW policy exists a request must match one of them
+type AuthorizationPolicy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // Shown in the deny logs (e.g., "orders-from-checkout-only")
+	Action        AuthorizationAction    `protobuf:"varint,2,opt,name=action,proto3,enum=mesh.AuthorizationAction" json:"action,omitempty"`
+	Rules         []*AuthorizationRule   `protobuf:"bytes,3,rep,name=rules,proto3" json:"rules,omitempty"` // The policy applies when any rule matches (none: never applies)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthorizationPolicy) Reset() {
+	*x = AuthorizationPolicy{}
+	mi := &file_api_proto_mesh_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthorizationPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorizationPolicy) ProtoMessage() {}
+
+func (x *AuthorizationPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorizationPolicy.ProtoReflect.Descriptor instead.
+func (*AuthorizationPolicy) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AuthorizationPolicy) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AuthorizationPolicy) GetAction() AuthorizationAction {
+	if x != nil {
+		return x.Action
+	}
+	return AuthorizationAction_AUTHORIZATION_ACTION_ALLOW
+}
+
+func (x *AuthorizationPolicy) GetRules() []*AuthorizationRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+// AuthorizationRule matches when all its conditions match, an empty condition matches every request
+// Principals and paths end with "*" to match a prefix, a lone "*" matches any value (any identity for principals)
+type AuthorizationRule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Principals    []string               `protobuf:"bytes,1,rep,name=principals,proto3" json:"principals,omitempty"`                            // Source identities, from the mTLS certificate (e.g., "spiffe://gomesh/ns/default/sa/checkout")
+	NotPrincipals []string               `protobuf:"bytes,2,rep,name=not_principals,json=notPrincipals,proto3" json:"not_principals,omitempty"` // Source identities that don't match (a request without identity is none of them)
+	Paths         []string               `protobuf:"bytes,3,rep,name=paths,proto3" json:"paths,omitempty"`                                      // Request paths (e.g., "/orders", "/admin/*")
+	Methods       []string               `protobuf:"bytes,4,rep,name=methods,proto3" json:"methods,omitempty"`                                  // HTTP methods (e.g., "GET")
+	Headers       []*KeyValueMatch       `protobuf:"bytes,5,rep,name=headers,proto3" json:"headers,omitempty"`                                  // Request headers, all must match
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthorizationRule) Reset() {
+	*x = AuthorizationRule{}
+	mi := &file_api_proto_mesh_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthorizationRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorizationRule) ProtoMessage() {}
+
+func (x *AuthorizationRule) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorizationRule.ProtoReflect.Descriptor instead.
+func (*AuthorizationRule) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AuthorizationRule) GetPrincipals() []string {
+	if x != nil {
+		return x.Principals
+	}
+	return nil
+}
+
+func (x *AuthorizationRule) GetNotPrincipals() []string {
+	if x != nil {
+		return x.NotPrincipals
+	}
+	return nil
+}
+
+func (x *AuthorizationRule) GetPaths() []string {
+	if x != nil {
+		return x.Paths
+	}
+	return nil
+}
+
+func (x *AuthorizationRule) GetMethods() []string {
+	if x != nil {
+		return x.Methods
+	}
+	return nil
+}
+
+func (x *AuthorizationRule) GetHeaders() []*KeyValueMatch {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+// WorkloadCertificate is the short-lived certificate the control plane CA issues to one proxy
+// The proxy presents it to mesh clusters and to other proxies, the control plane renews it before it expires
+type WorkloadCertificate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CertPem       string                 `protobuf:"bytes,1,opt,name=cert_pem,json=certPem,proto3" json:"cert_pem,omitempty"` // Leaf certificate, then the intermediates
+	KeyPem        string                 `protobuf:"bytes,2,opt,name=key_pem,json=keyPem,proto3" json:"key_pem,omitempty"`    // Private key of the leaf
+	CaPem         string                 `protobuf:"bytes,3,opt,name=ca_pem,json=caPem,proto3" json:"ca_pem,omitempty"`       // Certificates of the mesh CA, peers must chain to one of them
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkloadCertificate) Reset() {
+	*x = WorkloadCertificate{}
+	mi := &file_api_proto_mesh_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkloadCertificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkloadCertificate) ProtoMessage() {}
+
+func (x *WorkloadCertificate) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkloadCertificate.ProtoReflect.Descriptor instead.
+func (*WorkloadCertificate) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WorkloadCertificate) GetCertPem() string {
+	if x != nil {
+		return x.CertPem
+	}
+	return ""
+}
+
+func (x *WorkloadCertificate) GetKeyPem() string {
+	if x != nil {
+		return x.KeyPem
+	}
+	return ""
+}
+
+func (x *WorkloadCertificate) GetCaPem() string {
+	if x != nil {
+		return x.CaPem
+	}
+	return ""
+}
+
+// TlsCertificate is a certificate chain and its private key, in PEM
+// The names it serves come from the certificate itself (DNS SANs, or the CN without them)
+type TlsCertificate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`                      // Identifies the certificate in logs (e.g., "api.example.com")
+	CertPem       string                 `protobuf:"bytes,2,opt,name=cert_pem,json=certPem,proto3" json:"cert_pem,omitempty"` // Leaf certificate first, then the intermediates
+	KeyPem        string                 `protobuf:"bytes,3,opt,name=key_pem,json=keyPem,proto3" json:"key_pem,omitempty"`    // Private key of the leaf
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TlsCertificate) Reset() {
+	*x = TlsCertificate{}
+	mi := &file_api_proto_mesh_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TlsCertificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TlsCertificate) ProtoMessage() {}
+
+func (x *TlsCertificate) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TlsCertificate.ProtoReflect.Descriptor instead.
+func (*TlsCertificate) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TlsCertificate) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TlsCertificate) GetCertPem() string {
+	if x != nil {
+		return x.CertPem
+	}
+	return ""
+}
+
+func (x *TlsCertificate) GetKeyPem() string {
+	if x != nil {
+		return x.KeyPem
+	}
+	return ""
+}
+
+// Cluster is a named group of endpoints serving the same service
+type Cluster struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Name             string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`                                                 // Referenced by Route.cluster (e.g., "users-service")
+	Endpoints        []string               `protobuf:"bytes,2,rep,name=endpoints,proto3" json:"endpoints,omitempty"`                                       // Endpoint addresses (e.g., "10.0.0.1:5000", "10.0.0.2:5000")
+	HealthCheck      *HealthCheck           `protobuf:"bytes,3,opt,name=health_check,json=healthCheck,proto3" json:"health_check,omitempty"`                // Active health checking (unset: endpoints are always healthy)
+	CircuitBreaker   *CircuitBreaker        `protobuf:"bytes,4,opt,name=circuit_breaker,json=circuitBreaker,proto3" json:"circuit_breaker,omitempty"`       // Stops sending traffic to a failing cluster (unset: disabled)
+	OutlierDetection *OutlierDetection      `protobuf:"bytes,5,opt,name=outlier_detection,json=outlierDetection,proto3" json:"outlier_detection,omitempty"` // Ejects endpoints that misbehave on real traffic (unset: disabled)
+	RetryBudget      *RetryBudget           `protobuf:"bytes,6,opt,name=retry_budget,json=retryBudget,proto3" json:"retry_budget,omitempty"`                // Caps concurrent retries to the cluster (unset: 20% of active requests, min 3)
+	Mtls             bool                   `protobuf:"varint,7,opt,name=mtls,proto3" json:"mtls,omitempty"`                                                // Endpoints are mesh proxies: connect over mTLS with the workload certificate
+	Identity         string                 `protobuf:"bytes,8,opt,name=identity,proto3" json:"identity,omitempty"`                                         // With mtls: SPIFFE ID the endpoints must present (e.g., "spiffe://gomesh/ns/default/sa/orders"), required
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Cluster) Reset() {
+	*x = Cluster{}
+	mi := &file_api_proto_mesh_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Cluster) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Cluster) ProtoMessage() {}
+
+func (x *Cluster) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Cluster.ProtoReflect.Descriptor instead.
+func (*Cluster) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Cluster) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Cluster) GetEndpoints() []string {
+	if x != nil {
+		return x.Endpoints
+	}
+	return nil
+}
+
+func (x *Cluster) GetHealthCheck() *HealthCheck {
+	if x != nil {
+		return x.HealthCheck
+	}
+	return nil
+}
+
+func (x *Cluster) GetCircuitBreaker() *CircuitBreaker {
+	if x != nil {
+		return x.CircuitBreaker
+	}
+	return nil
+}
+
+func (x *Cluster) GetOutlierDetection() *OutlierDetection {
+	if x != nil {
+		return x.OutlierDetection
+	}
+	return nil
+}
+
+func (x *Cluster) GetRetryBudget() *RetryBudget {
+	if x != nil {
+		return x.RetryBudget
+	}
+	return nil
+}
+
+func (x *Cluster) GetMtls() bool {
+	if x != nil {
+		return x.Mtls
+	}
+	return false
+}
+
+func (x *Cluster) GetIdentity() string {
+	if x != nil {
+		return x.Identity
+	}
+	return ""
+}
+
+// RetryBudget limits the retries in flight to a share of the active requests of a cluster,
+// so that retries cannot amplify an outage
+type RetryBudget struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	BudgetPercent       float64                `protobuf:"fixed64,1,opt,name=budget_percent,json=budgetPercent,proto3" json:"budget_percent,omitempty"`                    // Max retries in flight, as a percentage of active requests (default: 20)
+	MinRetryConcurrency int32                  `protobuf:"varint,2,opt,name=min_retry_concurrency,json=minRetryConcurrency,proto3" json:"min_retry_concurrency,omitempty"` // Retries always allowed in flight, whatever the active requests (default: 3)
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *RetryBudget) Reset() {
+	*x = RetryBudget{}
+	mi := &file_api_proto_mesh_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetryBudget) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetryBudget) ProtoMessage() {}
+
+func (x *RetryBudget) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetryBudget.ProtoReflect.Descriptor instead.
+func (*RetryBudget) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RetryBudget) GetBudgetPercent() float64 {
+	if x != nil {
+		return x.BudgetPercent
+	}
+	return 0
+}
+
+func (x *RetryBudget) GetMinRetryConcurrency() int32 {
+	if x != nil {
+		return x.MinRetryConcurrency
+	}
+	return 0
+}
+
+// OutlierDetection watches the responses of each endpoint and temporarily ejects the bad ones
+// Ejection lasts base_ejection_time, doubled on every new ejection of the same endpoint (up to max_ejection_time)
+// Zero values disable a detector or use the default
+type OutlierDetection struct {
+	state                      protoimpl.MessageState `protogen:"open.v1"`
+	ConsecutiveServerErrors    int32                  `protobuf:"varint,1,opt,name=consecutive_server_errors,json=consecutiveServerErrors,proto3" json:"consecutive_server_errors,omitempty"`          // Eject after this many consecutive 5xx (0: disabled)
+	ConsecutiveGatewayFailures int32                  `protobuf:"varint,2,opt,name=consecutive_gateway_failures,json=consecutiveGatewayFailures,proto3" json:"consecutive_gateway_failures,omitempty"` // Eject after this many consecutive 502/503/504 or connection failures (0: disabled)
+	LatencyFactor              float64                `protobuf:"fixed64,3,opt,name=latency_factor,json=latencyFactor,proto3" json:"latency_factor,omitempty"`                                         // Eject when the mean latency of an endpoint exceeds the cluster mean by this factor, e.g., 3 (0: disabled)
+	LatencyMinRequests         int32                  `protobuf:"varint,4,opt,name=latency_min_requests,json=latencyMinRequests,proto3" json:"latency_min_requests,omitempty"`                         // Requests an endpoint needs in an interval to be judged on latency (default: 10)
+	IntervalMs                 int32                  `protobuf:"varint,5,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`                                                   // How often latency is analyzed and ejections expire (default: 10000)
+	BaseEjectionTimeMs         int32                  `protobuf:"varint,6,opt,name=base_ejection_time_ms,json=baseEjectionTimeMs,proto3" json:"base_ejection_time_ms,omitempty"`                       // First ejection duration (default: 30000)
+	MaxEjectionTimeMs          int32                  `protobuf:"varint,7,opt,name=max_ejection_time_ms,json=maxEjectionTimeMs,proto3" json:"max_ejection_time_ms,omitempty"`                          // Longest ejection duration (default: 300000)
+	MaxEjectionPercent         int32                  `protobuf:"varint,8,opt,name=max_ejection_percent,json=maxEjectionPercent,proto3" json:"max_ejection_percent,omitempty"`                         // Max share of the cluster endpoints ejected at once, 1-100 (default: 10)
+	unknownFields              protoimpl.UnknownFields
+	sizeCache                  protoimpl.SizeCache
+}
+
+func (x *OutlierDetection) Reset() {
+	*x = OutlierDetection{}
+	mi := &file_api_proto_mesh_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutlierDetection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutlierDetection) ProtoMessage() {}
+
+func (x *OutlierDetection) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutlierDetection.ProtoReflect.Descriptor instead.
+func (*OutlierDetection) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *OutlierDetection) GetConsecutiveServerErrors() int32 {
+	if x != nil {
+		return x.ConsecutiveServerErrors
+	}
+	return 0
+}
+
+func (x *OutlierDetection) GetConsecutiveGatewayFailures() int32 {
+	if x != nil {
+		return x.ConsecutiveGatewayFailures
+	}
+	return 0
+}
+
+func (x *OutlierDetection) GetLatencyFactor() float64 {
+	if x != nil {
+		return x.LatencyFactor
+	}
+	return 0
+}
+
+func (x *OutlierDetection) GetLatencyMinRequests() int32 {
+	if x != nil {
+		return x.LatencyMinRequests
+	}
+	return 0
+}
+
+func (x *OutlierDetection) GetIntervalMs() int32 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+func (x *OutlierDetection) GetBaseEjectionTimeMs() int32 {
+	if x != nil {
+		return x.BaseEjectionTimeMs
+	}
+	return 0
+}
+
+func (x *OutlierDetection) GetMaxEjectionTimeMs() int32 {
+	if x != nil {
+		return x.MaxEjectionTimeMs
+	}
+	return 0
+}
+
+func (x *OutlierDetection) GetMaxEjectionPercent() int32 {
+	if x != nil {
+		return x.MaxEjectionPercent
+	}
+	return 0
+}
+
+// CircuitBreaker protects a cluster: it opens on too many errors, rejecting requests with 503,
+// then lets a few trial requests through (half-open) before closing again
+// Errors are 5xx responses and failed upstream requests. Zero values disable a threshold or use the default
+type CircuitBreaker struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ConsecutiveErrors  int32                  `protobuf:"varint,1,opt,name=consecutive_errors,json=consecutiveErrors,proto3" json:"consecutive_errors,omitempty"`      // Open after this many consecutive errors (0: disabled)
+	ErrorRatio         float64                `protobuf:"fixed64,2,opt,name=error_ratio,json=errorRatio,proto3" json:"error_ratio,omitempty"`                          // Open when errors / requests in the window reach this ratio, 0-1 (0: disabled)
+	WindowMs           int32                  `protobuf:"varint,3,opt,name=window_ms,json=windowMs,proto3" json:"window_ms,omitempty"`                                 // Rolling window for error_ratio (default: 10000)
+	MinRequests        int32                  `protobuf:"varint,4,opt,name=min_requests,json=minRequests,proto3" json:"min_requests,omitempty"`                        // Requests needed in the window before error_ratio applies (default: 20)
+	OpenDurationMs     int32                  `protobuf:"varint,5,opt,name=open_duration_ms,json=openDurationMs,proto3" json:"open_duration_ms,omitempty"`             // How long the breaker stays open before half-open (default: 30000)
+	HalfOpenRequests   int32                  `protobuf:"varint,6,opt,name=half_open_requests,json=halfOpenRequests,proto3" json:"half_open_requests,omitempty"`       // Trial requests that must succeed to close the breaker (default: 1)
+	MaxRequests        int32                  `protobuf:"varint,7,opt,name=max_requests,json=maxRequests,proto3" json:"max_requests,omitempty"`                        // Max concurrent requests to the cluster (0: unlimited)
+	MaxPendingRequests int32                  `protobuf:"varint,8,opt,name=max_pending_requests,json=maxPendingRequests,proto3" json:"max_pending_requests,omitempty"` // Requests that may wait for a slot when max_requests is reached (0: none)
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *CircuitBreaker) Reset() {
+	*x = CircuitBreaker{}
+	mi := &file_api_proto_mesh_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CircuitBreaker) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CircuitBreaker) ProtoMessage() {}
+
+func (x *CircuitBreaker) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CircuitBreaker.ProtoReflect.Descriptor instead.
+func (*CircuitBreaker) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CircuitBreaker) GetConsecutiveErrors() int32 {
+	if x != nil {
+		return x.ConsecutiveErrors
+	}
+	return 0
+}
+
+func (x *CircuitBreaker) GetErrorRatio() float64 {
+	if x != nil {
+		return x.ErrorRatio
+	}
+	return 0
+}
+
+func (x *CircuitBreaker) GetWindowMs() int32 {
+	if x != nil {
+		return x.WindowMs
+	}
+	return 0
+}
+
+func (x *CircuitBreaker) GetMinRequests() int32 {
+	if x != nil {
+		return x.MinRequests
+	}
+	return 0
+}
+
+func (x *CircuitBreaker) GetOpenDurationMs() int32 {
+	if x != nil {
+		return x.OpenDurationMs
+	}
+	return 0
+}
+
+func (x *CircuitBreaker) GetHalfOpenRequests() int32 {
+	if x != nil {
+		return x.HalfOpenRequests
+	}
+	return 0
+}
+
+func (x *CircuitBreaker) GetMaxRequests() int32 {
+	if x != nil {
+		return x.MaxRequests
+	}
+	return 0
+}
+
+func (x *CircuitBreaker) GetMaxPendingRequests() int32 {
+	if x != nil {
+		return x.MaxPendingRequests
+	}
+	return 0
+}
+
+// HealthCheck defines how the proxy probes the endpoints of a cluster
+type HealthCheck struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Path               string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`                                                        // HTTP path to probe (e.g., "/health")
+	IntervalMs         int32                  `protobuf:"varint,2,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`                         // Time between probes (default: 5000)
+	TimeoutMs          int32                  `protobuf:"varint,3,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`                            // Probe timeout (default: 1000)
+	HealthyThreshold   int32                  `protobuf:"varint,4,opt,name=healthy_threshold,json=healthyThreshold,proto3" json:"healthy_threshold,omitempty"`       // Consecutive successes to mark an endpoint healthy (default: 2)
+	UnhealthyThreshold int32                  `protobuf:"varint,5,opt,name=unhealthy_threshold,json=unhealthyThreshold,proto3" json:"unhealthy_threshold,omitempty"` // Consecutive failures to mark an endpoint unhealthy (default: 3)
+	ExpectedStatusMin  int32                  `protobuf:"varint,6,opt,name=expected_status_min,json=expectedStatusMin,proto3" json:"expected_status_min,omitempty"`  // Lowest healthy status code, inclusive (default: 200)
+	ExpectedStatusMax  int32                  `protobuf:"varint,7,opt,name=expected_status_max,json=expectedStatusMax,proto3" json:"expected_status_max,omitempty"`  // Highest healthy status code, inclusive (default: 299)
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *HealthCheck) Reset() {
+	*x = HealthCheck{}
+	mi := &file_api_proto_mesh_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthCheck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthCheck) ProtoMessage() {}
+
+func (x *HealthCheck) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthCheck.ProtoReflect.Descriptor instead.
+func (*HealthCheck) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *HealthCheck) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *HealthCheck) GetIntervalMs() int32 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+func (x *HealthCheck) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *HealthCheck) GetHealthyThreshold() int32 {
+	if x != nil {
+		return x.HealthyThreshold
+	}
+	return 0
+}
+
+func (x *HealthCheck) GetUnhealthyThreshold() int32 {
+	if x != nil {
+		return x.UnhealthyThreshold
+	}
+	return 0
+}
+
+func (x *HealthCheck) GetExpectedStatusMin() int32 {
+	if x != nil {
+		return x.ExpectedStatusMin
+	}
+	return 0
+}
+
+func (x *HealthCheck) GetExpectedStatusMax() int32 {
+	if x != nil {
+		return x.ExpectedStatusMax
+	}
+	return 0
+}
+
+// AuthConfig holds what the proxy needs to authenticate requests on auth_required routes
+type AuthConfig struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jwks          string                 `protobuf:"bytes,1,opt,name=jwks,proto3" json:"jwks,omitempty"`                      // JSON Web Key Set with HS256 ("oct") and RS256 ("RSA") keys
+	Issuer        string                 `protobuf:"bytes,2,opt,name=issuer,proto3" json:"issuer,omitempty"`                  // Expected "iss" claim of JWTs (empty: not checked)
+	Audience      string                 `protobuf:"bytes,3,opt,name=audience,proto3" json:"audience,omitempty"`              // Expected "aud" claim of JWTs (empty: not checked)
+	ApiKeys       []*ApiKey              `protobuf:"bytes,4,rep,name=api_keys,json=apiKeys,proto3" json:"api_keys,omitempty"` // Static API keys, sent by clients in the X-API-Key header
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthConfig) Reset() {
+	*x = AuthConfig{}
+	mi := &file_api_proto_mesh_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthConfig) ProtoMessage() {}
+
+func (x *AuthConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthConfig.ProtoReflect.Descriptor instead.
+func (*AuthConfig) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AuthConfig) GetJwks() string {
+	if x != nil {
+		return x.Jwks
+	}
+	return ""
+}
+
+func (x *AuthConfig) GetIssuer() string {
+	if x != nil {
+		return x.Issuer
+	}
+	return ""
+}
+
+func (x *AuthConfig) GetAudience() string {
+	if x != nil {
+		return x.Audience
+	}
+	return ""
+}
+
+func (x *AuthConfig) GetApiKeys() []*ApiKey {
+	if x != nil {
+		return x.ApiKeys
+	}
+	return nil
+}
+
+// ApiKey is a static credential mapped to an identity
+type ApiKey struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`         // The secret value sent by the client
+	Subject       string                 `protobuf:"bytes,2,opt,name=subject,proto3" json:"subject,omitempty"` // Who the key belongs to (e.g., "billing-service")
+	Scopes        []string               `protobuf:"bytes,3,rep,name=scopes,proto3" json:"scopes,omitempty"`   // What the key is allowed to do (e.g., "orders:read")
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApiKey) Reset() {
+	*x = ApiKey{}
+	mi := &file_api_proto_mesh_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApiKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApiKey) ProtoMessage() {}
+
+func (x *ApiKey) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApiKey.ProtoReflect.Descriptor instead.
+func (*ApiKey) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ApiKey) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ApiKey) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *ApiKey) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+// Route defines how to route requests
+type Route struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Path            string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`                                                       // Path pattern (e.g., "/api/users", "/api/events")
+	Backend         string                 `protobuf:"bytes,2,opt,name=backend,proto3" json:"backend,omitempty"`                                                 // Backend address (e.g., "localhost:3000"), used when cluster is empty
+	AuthRequired    bool                   `protobuf:"varint,3,opt,name=auth_required,json=authRequired,proto3" json:"auth_required,omitempty"`                  // Whether this route requires authentication
+	TimeoutMs       int32                  `protobuf:"varint,4,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`                           // Request timeout in milliseconds
+	PathMatch       PathMatch              `protobuf:"varint,5,opt,name=path_match,json=pathMatch,proto3,enum=mesh.PathMatch" json:"path_match,omitempty"`       // How path is compared with the request path (default: PREFIX)
+	Cluster         string                 `protobuf:"bytes,6,opt,name=cluster,proto3" json:"cluster,omitempty"`                                                 // Cluster to send requests to (takes precedence over backend)
+	LbPolicy        LoadBalancerPolicy     `protobuf:"varint,7,opt,name=lb_policy,json=lbPolicy,proto3,enum=mesh.LoadBalancerPolicy" json:"lb_policy,omitempty"` // How to pick an endpoint of the cluster (default: ROUND_ROBIN)
+	HashPolicy      *HashPolicy            `protobuf:"bytes,8,opt,name=hash_policy,json=hashPolicy,proto3" json:"hash_policy,omitempty"`                         // What RING_HASH hashes on (default: client IP)
+	RetryPolicy     *RetryPolicy           `protobuf:"bytes,9,opt,name=retry_policy,json=retryPolicy,proto3" json:"retry_policy,omitempty"`                      // When to retry a failed request (unset: no retries)
+	RateLimit       *RateLimit             `protobuf:"bytes,10,opt,name=rate_limit,json=rateLimit,proto3" json:"rate_limit,omitempty"`                           // Local token bucket limit, per client (unset: unlimited)
+	GlobalRateLimit *GlobalRateLimit       `protobuf:"bytes,11,opt,name=global_rate_limit,json=globalRateLimit,proto3" json:"global_rate_limit,omitempty"`       // Quota shared by all proxies, checked with the rate limit service (unset: none)
+	// Extra match conditions, all of them must hold (unset: any request on the path)
+	// Routes are tried from the most specific: exact path, then longest prefix, then routes with hosts,
+	// then routes with more conditions, then in config order
+	Methods      []string         `protobuf:"bytes,12,rep,name=methods,proto3" json:"methods,omitempty"`                               // HTTP methods (e.g., "GET", "POST")
+	Hosts        []string         `protobuf:"bytes,13,rep,name=hosts,proto3" json:"hosts,omitempty"`                                   // Host header without port, "*.example.com" matches any subdomain
+	Headers      []*KeyValueMatch `protobuf:"bytes,14,rep,name=headers,proto3" json:"headers,omitempty"`                               // Request headers
+	QueryParams  []*KeyValueMatch `protobuf:"bytes,15,rep,name=query_params,json=queryParams,proto3" json:"query_params,omitempty"`    // URL query parameters
+	TrafficSplit *TrafficSplit    `protobuf:"bytes,16,opt,name=traffic_split,json=trafficSplit,proto3" json:"traffic_split,omitempty"` // Spread requests over several clusters by weight (takes precedence over cluster and backend)
+	Mirror       *RequestMirror   `protobuf:"bytes,17,opt,name=mirror,proto3" json:"mirror,omitempty"`                                 // Copy requests to a shadow cluster (unset: no mirroring)
+	Fault        *FaultInjection  `protobuf:"bytes,18,opt,name=fault,proto3" json:"fault,omitempty"`                                   // Delay or fail requests on purpose, to test clients (unset: no faults)
+	// Rewrites of the request sent upstream (the route is still matched on the original request)
+	PrefixRewrite   string        `protobuf:"bytes,19,opt,name=prefix_rewrite,json=prefixRewrite,proto3" json:"prefix_rewrite,omitempty"`          // Replaces the matched path or prefix, e.g. "/" maps /api/users/42 to /42 on a "/api/users" route (empty: unchanged)
+	RegexRewrite    *RegexRewrite `protobuf:"bytes,20,opt,name=regex_rewrite,json=regexRewrite,proto3" json:"regex_rewrite,omitempty"`             // Regex substitution on the path (can't be combined with prefix_rewrite)
+	HostRewrite     string        `protobuf:"bytes,21,opt,name=host_rewrite,json=hostRewrite,proto3" json:"host_rewrite,omitempty"`                // Host header sent upstream (empty: the client one)
+	AutoHostRewrite bool          `protobuf:"varint,22,opt,name=auto_host_rewrite,json=autoHostRewrite,proto3" json:"auto_host_rewrite,omitempty"` // Send the endpoint address as Host header (can't be combined with host_rewrite)
+	Name            string        `protobuf:"bytes,23,opt,name=name,proto3" json:"name,omitempty"`                                                 // Route name, for %ROUTE% in header values (default: path)
+	RequestHeaders  *HeaderPolicy `protobuf:"bytes,24,opt,name=request_headers,json=requestHeaders,proto3" json:"request_headers,omitempty"`       // Changes to the request headers sent upstream, after the global ones
+	ResponseHeaders *HeaderPolicy `protobuf:"bytes,25,opt,name=response_headers,json=responseHeaders,proto3" json:"response_headers,omitempty"`    // Changes to the upstream response headers, after the global ones
+	// Actions answering at the proxy, without a backend (cluster and backend are then not needed)
+	Redirect       *Redirect       `protobuf:"bytes,26,opt,name=redirect,proto3" json:"redirect,omitempty"`                                   // Redirect the client elsewhere
+	DirectResponse *DirectResponse `protobuf:"bytes,27,opt,name=direct_response,json=directResponse,proto3" json:"direct_response,omitempty"` // Answer with a fixed response (e.g., a maintenance page)
+	IpAccess       *IPAccess       `protobuf:"bytes,28,opt,name=ip_access,json=ipAccess,proto3" json:"ip_access,omitempty"`                   // Clients allowed on this route, by IP address (unset: any client the listener accepts)
+	ExtAuthz       *ExtAuthz       `protobuf:"bytes,29,opt,name=ext_authz,json=extAuthz,proto3" json:"ext_authz,omitempty"`                   // Ask an external service whether to let each request through (unset: no external check)
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Route) Reset() {
+	*x = Route{}
+	mi := &file_api_proto_mesh_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Route) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Route) ProtoMessage() {}
+
+func (x *Route) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Route.ProtoReflect.Descriptor instead.
+func (*Route) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Route) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Route) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *Route) GetAuthRequired() bool {
+	if x != nil {
+		return x.AuthRequired
+	}
+	return false
+}
+
+func (x *Route) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *Route) GetPathMatch() PathMatch {
+	if x != nil {
+		return x.PathMatch
+	}
+	return PathMatch_PATH_MATCH_PREFIX
+}
+
+func (x *Route) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *Route) GetLbPolicy() LoadBalancerPolicy {
+	if x != nil {
+		return x.LbPolicy
+	}
+	return LoadBalancerPolicy_ROUND_ROBIN
+}
+
+func (x *Route) GetHashPolicy() *HashPolicy {
+	if x != nil {
+		return x.HashPolicy
+	}
+	return nil
+}
+
+func (x *Route) GetRetryPolicy() *RetryPolicy {
+	if x != nil {
+		return x.RetryPolicy
+	}
+	return nil
+}
+
+func (x *Route) GetRateLimit() *RateLimit {
+	if x != nil {
+		return x.RateLimit
+	}
+	return nil
+}
+
+func (x *Route) GetGlobalRateLimit() *GlobalRateLimit {
+	if x != nil {
+		return x.GlobalRateLimit
+	}
+	return nil
+}
+
+func (x *Route) GetMethods() []string {
+	if x != nil {
+		return x.Methods
+	}
+	return nil
+}
+
+func (x *Route) GetHosts() []string {
+	if x != nil {
+		return x.Hosts
+	}
+	return nil
+}
+
+func (x *Route) GetHeaders() []*KeyValueMatch {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *Route) GetQueryParams() []*KeyValueMatch {
+	if x != nil {
+		return x.QueryParams
+	}
+	return nil
+}
+
+func (x *Route) GetTrafficSplit() *TrafficSplit {
+	if x != nil {
+		return x.TrafficSplit
+	}
+	return nil
+}
+
+func (x *Route) GetMirror() *RequestMirror {
+	if x != nil {
+		return x.Mirror
+	}
+	return nil
+}
+
+func (x *Route) GetFault() *FaultInjection {
+	if x != nil {
+		return x.Fault
+	}
+	return nil
+}
+
+func (x *Route) GetPrefixRewrite() string {
+	if x != nil {
+		return x.PrefixRewrite
+	}
+	return ""
+}
+
+func (x *Route) GetRegexRewrite() *RegexRewrite {
+	if x != nil {
+		return x.RegexRewrite
+	}
+	return nil
+}
+
+func (x *Route) GetHostRewrite() string {
+	if x != nil {
+		return x.HostRewrite
+	}
+	return ""
+}
+
+func (x *Route) GetAutoHostRewrite() bool {
+	if x != nil {
+		return x.AutoHostRewrite
+	}
+	return false
+}
+
+func (x *Route) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Route) GetRequestHeaders() *HeaderPolicy {
+	if x != nil {
+		return x.RequestHeaders
+	}
+	return nil
+}
+
+func (x *Route) GetResponseHeaders() *HeaderPolicy {
+	if x != nil {
+		return x.ResponseHeaders
+	}
+	return nil
+}
+
+func (x *Route) GetRedirect() *Redirect {
+	if x != nil {
+		return x.Redirect
+	}
+	return nil
+}
+
+func (x *Route) GetDirectResponse() *DirectResponse {
+	if x != nil {
+		return x.DirectResponse
+	}
+	return nil
+}
+
+func (x *Route) GetIpAccess() *IPAccess {
+	if x != nil {
+		return x.IpAccess
+	}
+	return nil
+}
+
+func (x *Route) GetExtAuthz() *ExtAuthz {
+	if x != nil {
+		return x.ExtAuthz
+	}
+	return nil
+}
+
+// ExtAuthz delegates the allow/deny decision of a route to an external authorization service
+// The check runs after authentication, so the service sees the verified subject
+type ExtAuthz struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	GrpcAddress    string                 `protobuf:"bytes,1,opt,name=grpc_address,json=grpcAddress,proto3" json:"grpc_address,omitempty"`          // host:port of an ExternalAuthorization gRPC service (plaintext)
+	HttpUrl        string                 `protobuf:"bytes,2,opt,name=http_url,json=httpUrl,proto3" json:"http_url,omitempty"`                      // Or a URL taking the CheckRequest as JSON by POST and answering a JSON CheckResponse (one of the two)
+	TimeoutMs      int32                  `protobuf:"varint,3,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`               // Max wait for the decision (default: 200)
+	FailClosed     bool                   `protobuf:"varint,4,opt,name=fail_closed,json=failClosed,proto3" json:"fail_closed,omitempty"`            // Reject with 503 when the service can't decide (default: let requests through)
+	IncludeHeaders []string               `protobuf:"bytes,5,rep,name=include_headers,json=includeHeaders,proto3" json:"include_headers,omitempty"` // Request headers sent to the service (empty: all of them)
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ExtAuthz) Reset() {
+	*x = ExtAuthz{}
+	mi := &file_api_proto_mesh_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtAuthz) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtAuthz) ProtoMessage() {}
+
+func (x *ExtAuthz) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtAuthz.ProtoReflect.Descriptor instead.
+func (*ExtAuthz) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ExtAuthz) GetGrpcAddress() string {
+	if x != nil {
+		return x.GrpcAddress
+	}
+	return ""
+}
+
+func (x *ExtAuthz) GetHttpUrl() string {
+	if x != nil {
+		return x.HttpUrl
+	}
+	return ""
+}
+
+func (x *ExtAuthz) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *ExtAuthz) GetFailClosed() bool {
+	if x != nil {
+		return x.FailClosed
+	}
+	return false
+}
+
+func (x *ExtAuthz) GetIncludeHeaders() []string {
+	if x != nil {
+		return x.IncludeHeaders
+	}
+	return nil
+}
+
+// CheckRequest describes the request to authorize
+type CheckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Method        string                 `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Query         string                 `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"` // Raw query string, without "?"
+	Host          string                 `protobuf:"bytes,4,opt,name=host,proto3" json:"host,omitempty"`
+	Headers       []*HeaderValue         `protobuf:"bytes,5,rep,name=headers,proto3" json:"headers,omitempty"`                   // One entry per value
+	Principal     string                 `protobuf:"bytes,6,opt,name=principal,proto3" json:"principal,omitempty"`               // SPIFFE ID of the mesh proxy that sent the request (empty outside the mesh)
+	Subject       string                 `protobuf:"bytes,7,opt,name=subject,proto3" json:"subject,omitempty"`                   // Authenticated subject, on auth_required routes (empty otherwise)
+	Scopes        []string               `protobuf:"bytes,8,rep,name=scopes,proto3" json:"scopes,omitempty"`                     // Scopes of the subject
+	ClientIp      string                 `protobuf:"bytes,9,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"` // Client address (from X-Forwarded-For behind trusted proxies)
+	Route         string                 `protobuf:"bytes,10,opt,name=route,proto3" json:"route,omitempty"`                      // Route name (default: path)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckRequest) Reset() {
+	*x = CheckRequest{}
+	mi := &file_api_proto_mesh_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckRequest) ProtoMessage() {}
+
+func (x *CheckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckRequest.ProtoReflect.Descriptor instead.
+func (*CheckRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *CheckRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *CheckRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *CheckRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *CheckRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *CheckRequest) GetHeaders() []*HeaderValue {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *CheckRequest) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *CheckRequest) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *CheckRequest) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+func (x *CheckRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *CheckRequest) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+// CheckResponse is the decision of the authorization service
+// A response setting Host, a hop-by-hop or a body framing header (or an invalid one) counts as a failed check
+type CheckResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Allowed         bool                   `protobuf:"varint,1,opt,name=allowed,proto3" json:"allowed,omitempty"`
+	RequestHeaders  []*HeaderValue         `protobuf:"bytes,2,rep,name=request_headers,json=requestHeaders,proto3" json:"request_headers,omitempty"`    // Set on the request sent upstream when allowed (values are literal)
+	DeniedResponse  *DirectResponse        `protobuf:"bytes,3,opt,name=denied_response,json=deniedResponse,proto3" json:"denied_response,omitempty"`    // Sent to the client when denied (unset: 403 Forbidden)
+	ResponseHeaders []*HeaderValue         `protobuf:"bytes,4,rep,name=response_headers,json=responseHeaders,proto3" json:"response_headers,omitempty"` // Set on the denied response (e.g., WWW-Authenticate, Location)
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *CheckResponse) Reset() {
+	*x = CheckResponse{}
+	mi := &file_api_proto_mesh_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckResponse) ProtoMessage() {}
+
+func (x *CheckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckResponse.ProtoReflect.Descriptor instead.
+func (*CheckResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *CheckResponse) GetAllowed() bool {
+	if x != nil {
+		return x.Allowed
+	}
+	return false
+}
+
+func (x *CheckResponse) GetRequestHeaders() []*HeaderValue {
+	if x != nil {
+		return x.RequestHeaders
+	}
+	return nil
+}
+
+func (x *CheckResponse) GetDeniedResponse() *DirectResponse {
+	if x != nil {
+		return x.DeniedResponse
+	}
+	return nil
+}
+
+func (x *CheckResponse) GetResponseHeaders() []*HeaderValue {
+	if x != nil {
+		return x.ResponseHeaders
+	}
+	return nil
+}
+
+// IPAccess allows or denies clients by address, behind trusted proxies the address comes from X-Forwarded-For
+// Entries are IPs or CIDR ranges (e.g., "10.0.0.0/8", "2001:db8::/32"). Deny wins, then a non-empty allow list must match
+type IPAccess struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Allow         []string               `protobuf:"bytes,1,rep,name=allow,proto3" json:"allow,omitempty"`
+	Deny          []string               `protobuf:"bytes,2,rep,name=deny,proto3" json:"deny,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IPAccess) Reset() {
+	*x = IPAccess{}
+	mi := &file_api_proto_mesh_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IPAccess) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IPAccess) ProtoMessage() {}
+
+func (x *IPAccess) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IPAccess.ProtoReflect.Descriptor instead.
+func (*IPAccess) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *IPAccess) GetAllow() []string {
+	if x != nil {
+		return x.Allow
+	}
+	return nil
+}
+
+func (x *IPAccess) GetDeny() []string {
+	if x != nil {
+		return x.Deny
+	}
+	return nil
+}
+
+// Redirect answers with a redirect to the request URL with some parts replaced
+type Redirect struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Scheme        string                 `protobuf:"bytes,1,opt,name=scheme,proto3" json:"scheme,omitempty"`                                    // e.g., "https" for HTTP to HTTPS redirects (empty: unchanged)
+	Host          string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`                                        // Host name (empty: unchanged)
+	Port          int32                  `protobuf:"varint,3,opt,name=port,proto3" json:"port,omitempty"`                                       // Port (0: unchanged, or the default one when the scheme changes)
+	Path          string                 `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`                                        // Whole new path (empty: unchanged)
+	PrefixRewrite string                 `protobuf:"bytes,5,opt,name=prefix_rewrite,json=prefixRewrite,proto3" json:"prefix_rewrite,omitempty"` // Replaces the matched path or prefix, like Route.prefix_rewrite (can't be combined with path)
+	StripQuery    bool                   `protobuf:"varint,6,opt,name=strip_query,json=stripQuery,proto3" json:"strip_query,omitempty"`         // Drop the query string
+	Status        int32                  `protobuf:"varint,7,opt,name=status,proto3" json:"status,omitempty"`                                   // 301, 302, 303, 307 or 308 (default: 301)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Redirect) Reset() {
+	*x = Redirect{}
+	mi := &file_api_proto_mesh_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Redirect) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Redirect) ProtoMessage() {}
+
+func (x *Redirect) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Redirect.ProtoReflect.Descriptor instead.
+func (*Redirect) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *Redirect) GetScheme() string {
+	if x != nil {
+		return x.Scheme
+	}
+	return ""
+}
+
+func (x *Redirect) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Redirect) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *Redirect) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Redirect) GetPrefixRewrite() string {
+	if x != nil {
+		return x.PrefixRewrite
+	}
+	return ""
+}
+
+func (x *Redirect) GetStripQuery() bool {
+	if x != nil {
+		return x.StripQuery
+	}
+	return false
+}
+
+func (x *Redirect) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+// DirectResponse is a response sent by the proxy itself
+type DirectResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        int32                  `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`                             // HTTP status (e.g., 503)
+	Body          string                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`                                  // Response body (empty: none)
+	ContentType   string                 `protobuf:"bytes,3,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"` // Content-Type of the body (default: "text/plain; charset=utf-8")
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DirectResponse) Reset() {
+	*x = DirectResponse{}
+	mi := &file_api_proto_mesh_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DirectResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DirectResponse) ProtoMessage() {}
+
+func (x *DirectResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DirectResponse.ProtoReflect.Descriptor instead.
+func (*DirectResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *DirectResponse) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *DirectResponse) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *DirectResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+// HeaderPolicy changes headers, in this order: remove, set, append, add
+// Values can use %TRACE_ID%, %CLIENT_IP%, %ROUTE%, %CLUSTER%, %UPSTREAM_ADDRESS% (%% for a literal %)
+type HeaderPolicy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Set           []*HeaderValue         `protobuf:"bytes,1,rep,name=set,proto3" json:"set,omitempty"`       // Replace any value the header has
+	Append        []*HeaderValue         `protobuf:"bytes,2,rep,name=append,proto3" json:"append,omitempty"` // Add a value, keeping the existing ones
+	Add           []*HeaderValue         `protobuf:"bytes,3,rep,name=add,proto3" json:"add,omitempty"`       // Only when the header is absent
+	Remove        []string               `protobuf:"bytes,4,rep,name=remove,proto3" json:"remove,omitempty"` // Header names to drop
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeaderPolicy) Reset() {
+	*x = HeaderPolicy{}
+	mi := &file_api_proto_mesh_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeaderPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeaderPolicy) ProtoMessage() {}
+
+func (x *HeaderPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeaderPolicy.ProtoReflect.Descriptor instead.
+func (*HeaderPolicy) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *HeaderPolicy) GetSet() []*HeaderValue {
+	if x != nil {
+		return x.Set
+	}
+	return nil
+}
+
+func (x *HeaderPolicy) GetAppend() []*HeaderValue {
+	if x != nil {
+		return x.Append
+	}
+	return nil
+}
+
+func (x *HeaderPolicy) GetAdd() []*HeaderValue {
+	if x != nil {
+		return x.Add
+	}
+	return nil
+}
+
+func (x *HeaderPolicy) GetRemove() []string {
+	if x != nil {
+		return x.Remove
+	}
+	return nil
+}
+
+// HeaderValue is a header name and value
+type HeaderValue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`   // Header name (e.g., "X-Request-Source")
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"` // Value, with optional %VARIABLES%
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeaderValue) Reset() {
+	*x = HeaderValue{}
+	mi := &file_api_proto_mesh_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeaderValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeaderValue) ProtoMessage() {}
+
+func (x *HeaderValue) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeaderValue.ProtoReflect.Descriptor instead.
+func (*HeaderValue) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *HeaderValue) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *HeaderValue) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// RegexRewrite replaces every match of pattern in the path with substitution
+type RegexRewrite struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pattern       string                 `protobuf:"bytes,1,opt,name=pattern,proto3" json:"pattern,omitempty"`           // RE2 regular expression (e.g., "^/v1/(.*)$")
+	Substitution  string                 `protobuf:"bytes,2,opt,name=substitution,proto3" json:"substitution,omitempty"` // Replacement, $1 or ${name} expand to groups (e.g., "/api/$1")
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegexRewrite) Reset() {
+	*x = RegexRewrite{}
+	mi := &file_api_proto_mesh_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegexRewrite) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegexRewrite) ProtoMessage() {}
+
+func (x *RegexRewrite) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegexRewrite.ProtoReflect.Descriptor instead.
+func (*RegexRewrite) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *RegexRewrite) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *RegexRewrite) GetSubstitution() string {
+	if x != nil {
+		return x.Substitution
+	}
+	return ""
+}
+
+// FaultInjection delays or aborts a share of the requests of a route, before they reach the cluster
+// Delay and abort can be combined: the abort then comes after the delay
+type FaultInjection struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Delay         *FaultDelay            `protobuf:"bytes,1,opt,name=delay,proto3" json:"delay,omitempty"`   // Wait before forwarding (unset: no delay)
+	Abort         *FaultAbort            `protobuf:"bytes,2,opt,name=abort,proto3" json:"abort,omitempty"`   // Answer with an error instead of forwarding (unset: no abort)
+	Header        string                 `protobuf:"bytes,3,opt,name=header,proto3" json:"header,omitempty"` // Only requests with this header get faults (empty: all requests)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FaultInjection) Reset() {
+	*x = FaultInjection{}
+	mi := &file_api_proto_mesh_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FaultInjection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FaultInjection) ProtoMessage() {}
+
+func (x *FaultInjection) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FaultInjection.ProtoReflect.Descriptor instead.
+func (*FaultInjection) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *FaultInjection) GetDelay() *FaultDelay {
+	if x != nil {
+		return x.Delay
+	}
+	return nil
+}
+
+func (x *FaultInjection) GetAbort() *FaultAbort {
+	if x != nil {
+		return x.Abort
+	}
+	return nil
+}
+
+func (x *FaultInjection) GetHeader() string {
+	if x != nil {
+		return x.Header
+	}
+	return ""
+}
+
+// FaultDelay holds requests back for a while
+type FaultDelay struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DelayMs       int32                  `protobuf:"varint,1,opt,name=delay_ms,json=delayMs,proto3" json:"delay_ms,omitempty"` // How long to wait
+	Percent       *float64               `protobuf:"fixed64,2,opt,name=percent,proto3,oneof" json:"percent,omitempty"`         // Share of the requests delayed, 0-100 (unset: 100, 0 turns the delay off)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FaultDelay) Reset() {
+	*x = FaultDelay{}
+	mi := &file_api_proto_mesh_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FaultDelay) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FaultDelay) ProtoMessage() {}
+
+func (x *FaultDelay) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FaultDelay.ProtoReflect.Descriptor instead.
+func (*FaultDelay) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *FaultDelay) GetDelayMs() int32 {
+	if x != nil {
+		return x.DelayMs
+	}
+	return 0
+}
+
+func (x *FaultDelay) GetPercent() float64 {
+	if x != nil && x.Percent != nil {
+		return *x.Percent
+	}
+	return 0
+}
+
+// FaultAbort answers requests with an error status
+type FaultAbort struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        int32                  `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`          // HTTP status to answer with (e.g., 503)
+	Percent       *float64               `protobuf:"fixed64,2,opt,name=percent,proto3,oneof" json:"percent,omitempty"` // Share of the requests aborted, 0-100 (unset: 100, 0 turns the abort off)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FaultAbort) Reset() {
+	*x = FaultAbort{}
+	mi := &file_api_proto_mesh_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FaultAbort) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FaultAbort) ProtoMessage() {}
+
+func (x *FaultAbort) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FaultAbort.ProtoReflect.Descriptor instead.
+func (*FaultAbort) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *FaultAbort) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *FaultAbort) GetPercent() float64 {
+	if x != nil && x.Percent != nil {
+		return *x.Percent
+	}
+	return 0
+}
+
+// RequestMirror sends a copy of some requests to a shadow cluster, in the background
+// The shadow response is discarded: the client only ever sees the primary one.
+// Shadow requests carry the original Host with a "-shadow" suffix
+type RequestMirror struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cluster       string                 `protobuf:"bytes,1,opt,name=cluster,proto3" json:"cluster,omitempty"`                                  // Shadow cluster name
+	Percent       *float64               `protobuf:"fixed64,2,opt,name=percent,proto3,oneof" json:"percent,omitempty"`                          // Share of the requests mirrored, 0-100 (unset: 100, 0 turns mirroring off)
+	MaxBodyBytes  int32                  `protobuf:"varint,3,opt,name=max_body_bytes,json=maxBodyBytes,proto3" json:"max_body_bytes,omitempty"` // Requests with a larger body are not mirrored (default: 65536)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestMirror) Reset() {
+	*x = RequestMirror{}
+	mi := &file_api_proto_mesh_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestMirror) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestMirror) ProtoMessage() {}
+
+func (x *RequestMirror) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestMirror.ProtoReflect.Descriptor instead.
+func (*RequestMirror) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *RequestMirror) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *RequestMirror) GetPercent() float64 {
+	if x != nil && x.Percent != nil {
+		return *x.Percent
+	}
+	return 0
+}
+
+func (x *RequestMirror) GetMaxBodyBytes() int32 {
+	if x != nil {
+		return x.MaxBodyBytes
+	}
+	return 0
+}
+
+// TrafficSplit sends a share of the requests of a route to each cluster (e.g., 95/5 for a canary)
+// Weights are relative, they don't need to add up to 100. Shift them gradually with config updates
+type TrafficSplit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Clusters      []*WeightedCluster     `protobuf:"bytes,1,rep,name=clusters,proto3" json:"clusters,omitempty"`                       // Clusters and their weights
+	HashPolicy    *HashPolicy            `protobuf:"bytes,2,opt,name=hash_policy,json=hashPolicy,proto3" json:"hash_policy,omitempty"` // Pin clients to a cluster by hashing this key (unset: weighted random pick per request)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TrafficSplit) Reset() {
+	*x = TrafficSplit{}
+	mi := &file_api_proto_mesh_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TrafficSplit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrafficSplit) ProtoMessage() {}
+
+func (x *TrafficSplit) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrafficSplit.ProtoReflect.Descriptor instead.
+func (*TrafficSplit) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *TrafficSplit) GetClusters() []*WeightedCluster {
+	if x != nil {
+		return x.Clusters
+	}
+	return nil
+}
+
+func (x *TrafficSplit) GetHashPolicy() *HashPolicy {
+	if x != nil {
+		return x.HashPolicy
+	}
+	return nil
+}
+
+// WeightedCluster is one of the clusters of a TrafficSplit
+type WeightedCluster struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cluster       string                 `protobuf:"bytes,1,opt,name=cluster,proto3" json:"cluster,omitempty"` // Cluster name
+	Weight        int32                  `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`  // Relative share of the requests (0: no new requests)
+	Version       string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"` // Version label in metrics (default: cluster name)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WeightedCluster) Reset() {
+	*x = WeightedCluster{}
+	mi := &file_api_proto_mesh_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WeightedCluster) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeightedCluster) ProtoMessage() {}
+
+func (x *WeightedCluster) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeightedCluster.ProtoReflect.Descriptor instead.
+func (*WeightedCluster) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *WeightedCluster) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *WeightedCluster) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *WeightedCluster) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+// KeyValueMatch matches a request header or query parameter
+// With several values (repeated header or parameter), any value matching is enough
+type KeyValueMatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`                          // Header or parameter name (headers are case insensitive)
+	Match         StringMatch            `protobuf:"varint,2,opt,name=match,proto3,enum=mesh.StringMatch" json:"match,omitempty"` // How value is compared (default: STRING_MATCH_EXACT)
+	Value         string                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`                        // Expected value, prefix or regex (unused for STRING_MATCH_PRESENT)
+	Invert        bool                   `protobuf:"varint,4,opt,name=invert,proto3" json:"invert,omitempty"`                     // Match when the condition does not hold (e.g., header absent)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyValueMatch) Reset() {
+	*x = KeyValueMatch{}
+	mi := &file_api_proto_mesh_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyValueMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyValueMatch) ProtoMessage() {}
+
+func (x *KeyValueMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyValueMatch.ProtoReflect.Descriptor instead.
+func (*KeyValueMatch) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *KeyValueMatch) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *KeyValueMatch) GetMatch() StringMatch {
+	if x != nil {
+		return x.Match
+	}
+	return StringMatch_STRING_MATCH_EXACT
+}
+
+func (x *KeyValueMatch) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *KeyValueMatch) GetInvert() bool {
+	if x != nil {
+		return x.Invert
+	}
+	return false
+}
+
+// GlobalRateLimit builds a descriptor from the request and takes a token of the matching quota
+type GlobalRateLimit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Descriptor_   []*DescriptorEntry     `protobuf:"bytes,1,rep,name=descriptor,proto3" json:"descriptor,omitempty"`                    // Entries of the descriptor, in order
+	FailClosed    bool                   `protobuf:"varint,2,opt,name=fail_closed,json=failClosed,proto3" json:"fail_closed,omitempty"` // Reject with 503 when the rate limit service is unreachable (default: let requests through)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GlobalRateLimit) Reset() {
+	*x = GlobalRateLimit{}
+	mi := &file_api_proto_mesh_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GlobalRateLimit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GlobalRateLimit) ProtoMessage() {}
+
+func (x *GlobalRateLimit) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GlobalRateLimit.ProtoReflect.Descriptor instead.
+func (*GlobalRateLimit) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *GlobalRateLimit) GetDescriptor_() []*DescriptorEntry {
+	if x != nil {
+		return x.Descriptor_
+	}
+	return nil
+}
+
+func (x *GlobalRateLimit) GetFailClosed() bool {
+	if x != nil {
+		return x.FailClosed
+	}
+	return false
+}
+
+// DescriptorEntry is one key/value of a rate limit descriptor
+type DescriptorEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`                               // Descriptor key (e.g., "tenant")
+	Source        RateLimitKey           `protobuf:"varint,2,opt,name=source,proto3,enum=mesh.RateLimitKey" json:"source,omitempty"` // Where the value comes from (default: client IP)
+	Header        string                 `protobuf:"bytes,3,opt,name=header,proto3" json:"header,omitempty"`                         // Header name (for RATE_LIMIT_KEY_HEADER)
+	Value         string                 `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`                           // Constant value (when set, source is ignored)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DescriptorEntry) Reset() {
+	*x = DescriptorEntry{}
+	mi := &file_api_proto_mesh_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DescriptorEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescriptorEntry) ProtoMessage() {}
+
+func (x *DescriptorEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescriptorEntry.ProtoReflect.Descriptor instead.
+func (*DescriptorEntry) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *DescriptorEntry) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *DescriptorEntry) GetSource() RateLimitKey {
+	if x != nil {
+		return x.Source
+	}
+	return RateLimitKey_RATE_LIMIT_KEY_CLIENT_IP
+}
+
+func (x *DescriptorEntry) GetHeader() string {
+	if x != nil {
+		return x.Header
+	}
+	return ""
+}
+
+func (x *DescriptorEntry) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// RateLimit is a token bucket kept by each proxy, one bucket per key
+// Requests over the limit get 429 with Retry-After and X-RateLimit-* headers
+type RateLimit struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	RequestsPerSecond float64                `protobuf:"fixed64,1,opt,name=requests_per_second,json=requestsPerSecond,proto3" json:"requests_per_second,omitempty"` // Rate the bucket refills at
+	Burst             int32                  `protobuf:"varint,2,opt,name=burst,proto3" json:"burst,omitempty"`                                                     // Bucket size (default: requests_per_second, at least 1)
+	Key               RateLimitKey           `protobuf:"varint,3,opt,name=key,proto3,enum=mesh.RateLimitKey" json:"key,omitempty"`                                  // What the buckets are keyed on (default: client IP)
+	Header            string                 `protobuf:"bytes,4,opt,name=header,proto3" json:"header,omitempty"`                                                    // Header name (for RATE_LIMIT_KEY_HEADER), requests without it share one bucket
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *RateLimit) Reset() {
+	*x = RateLimit{}
+	mi := &file_api_proto_mesh_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimit) ProtoMessage() {}
+
+func (x *RateLimit) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimit.ProtoReflect.Descriptor instead.
+func (*RateLimit) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *RateLimit) GetRequestsPerSecond() float64 {
+	if x != nil {
+		return x.RequestsPerSecond
+	}
+	return 0
+}
+
+func (x *RateLimit) GetBurst() int32 {
+	if x != nil {
+		return x.Burst
+	}
+	return 0
+}
+
+func (x *RateLimit) GetKey() RateLimitKey {
+	if x != nil {
+		return x.Key
+	}
+	return RateLimitKey_RATE_LIMIT_KEY_CLIENT_IP
+}
+
+func (x *RateLimit) GetHeader() string {
+	if x != nil {
+		return x.Header
+	}
+	return ""
+}
+
+// RetryPolicy retries failed requests on another pick of the load balancer
+// Only idempotent methods are retried, unless retry_non_idempotent is set
+// (connect failures are always safe: the request never reached the endpoint)
+type RetryPolicy struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	MaxAttempts        int32                  `protobuf:"varint,1,opt,name=max_attempts,json=maxAttempts,proto3" json:"max_attempts,omitempty"`                        // Total attempts including the first one, up to 10 (default: 2)
+	RetryOn            []RetryOn              `protobuf:"varint,2,rep,packed,name=retry_on,json=retryOn,proto3,enum=mesh.RetryOn" json:"retry_on,omitempty"`           // Failures worth a retry (default: all of them)
+	PerTryTimeoutMs    int32                  `protobuf:"varint,3,opt,name=per_try_timeout_ms,json=perTryTimeoutMs,proto3" json:"per_try_timeout_ms,omitempty"`        // Timeout of each attempt, within the route timeout (0: only the route timeout)
+	BackoffBaseMs      int32                  `protobuf:"varint,4,opt,name=backoff_base_ms,json=backoffBaseMs,proto3" json:"backoff_base_ms,omitempty"`                // Backoff before the first retry, doubled on each retry and jittered (default: 25)
+	BackoffMaxMs       int32                  `protobuf:"varint,5,opt,name=backoff_max_ms,json=backoffMaxMs,proto3" json:"backoff_max_ms,omitempty"`                   // Longest backoff (default: 10x backoff_base_ms)
+	RetryNonIdempotent bool                   `protobuf:"varint,6,opt,name=retry_non_idempotent,json=retryNonIdempotent,proto3" json:"retry_non_idempotent,omitempty"` // Also retry POST, PATCH... (the endpoint may process them twice)
+	MaxBodyBytes       int32                  `protobuf:"varint,7,opt,name=max_body_bytes,json=maxBodyBytes,proto3" json:"max_body_bytes,omitempty"`                   // Larger request bodies are not buffered, and not retried (default: 65536)
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *RetryPolicy) Reset() {
+	*x = RetryPolicy{}
+	mi := &file_api_proto_mesh_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetryPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetryPolicy) ProtoMessage() {}
+
+func (x *RetryPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetryPolicy.ProtoReflect.Descriptor instead.
+func (*RetryPolicy) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *RetryPolicy) GetMaxAttempts() int32 {
+	if x != nil {
+		return x.MaxAttempts
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetRetryOn() []RetryOn {
+	if x != nil {
+		return x.RetryOn
+	}
+	return nil
+}
+
+func (x *RetryPolicy) GetPerTryTimeoutMs() int32 {
+	if x != nil {
+		return x.PerTryTimeoutMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetBackoffBaseMs() int32 {
+	if x != nil {
+		return x.BackoffBaseMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetBackoffMaxMs() int32 {
+	if x != nil {
+		return x.BackoffMaxMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetRetryNonIdempotent() bool {
+	if x != nil {
+		return x.RetryNonIdempotent
+	}
+	return false
+}
+
+func (x *RetryPolicy) GetMaxBodyBytes() int32 {
+	if x != nil {
+		return x.MaxBodyBytes
+	}
+	return 0
+}
+
+// HashPolicy tells the RING_HASH balancer which request attribute keeps a client on the same endpoint
+type HashPolicy struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Key              HashKey                `protobuf:"varint,1,opt,name=key,proto3,enum=mesh.HashKey" json:"key,omitempty"`                                   // What to hash on
+	Name             string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`                                                    // Header or cookie name (for HASH_KEY_HEADER and HASH_KEY_COOKIE)
+	IssueCookie      bool                   `protobuf:"varint,3,opt,name=issue_cookie,json=issueCookie,proto3" json:"issue_cookie,omitempty"`                  // HASH_KEY_COOKIE only: set the cookie when the request has none
+	CookieTtlSeconds int32                  `protobuf:"varint,4,opt,name=cookie_ttl_seconds,json=cookieTtlSeconds,proto3" json:"cookie_ttl_seconds,omitempty"` // Lifetime of the issued cookie (0: session cookie)
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *HashPolicy) Reset() {
+	*x = HashPolicy{}
+	mi := &file_api_proto_mesh_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HashPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HashPolicy) ProtoMessage() {}
+
+func (x *HashPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HashPolicy.ProtoReflect.Descriptor instead.
+func (*HashPolicy) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *HashPolicy) GetKey() HashKey {
+	if x != nil {
+		return x.Key
+	}
+	return HashKey_HASH_KEY_CLIENT_IP
+}
+
+func (x *HashPolicy) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *HashPolicy) GetIssueCookie() bool {
+	if x != nil {
+		return x.IssueCookie
+	}
+	return false
+}
+
+func (x *HashPolicy) GetCookieTtlSeconds() int32 {
+	if x != nil {
+		return x.CookieTtlSeconds
+	}
+	return 0
+}
+
+// RateLimitRequest asks for tokens of several descriptors at once
+type RateLimitRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProxyId       string                 `protobuf:"bytes,1,opt,name=proxy_id,json=proxyId,proto3" json:"proxy_id,omitempty"` // Who is asking (for logs)
+	Hits          []*RateLimitHit        `protobuf:"bytes,2,rep,name=hits,proto3" json:"hits,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateLimitRequest) Reset() {
+	*x = RateLimitRequest{}
+	mi := &file_api_proto_mesh_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimitRequest) ProtoMessage() {}
+
+func (x *RateLimitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimitRequest.ProtoReflect.Descriptor instead.
+func (*RateLimitRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *RateLimitRequest) GetProxyId() string {
+	if x != nil {
+		return x.ProxyId
+	}
+	return ""
+}
+
+func (x *RateLimitRequest) GetHits() []*RateLimitHit {
+	if x != nil {
+		return x.Hits
+	}
+	return nil
+}
+
+// RateLimitHit asks for a number of tokens of a descriptor
+type RateLimitHit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Descriptor_   []*RateLimitEntry      `protobuf:"bytes,1,rep,name=descriptor,proto3" json:"descriptor,omitempty"`
+	Hits          int32                  `protobuf:"varint,2,opt,name=hits,proto3" json:"hits,omitempty"` // Tokens wanted (requests waiting plus prefetch)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateLimitHit) Reset() {
+	*x = RateLimitHit{}
+	mi := &file_api_proto_mesh_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimitHit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimitHit) ProtoMessage() {}
+
+func (x *RateLimitHit) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimitHit.ProtoReflect.Descriptor instead.
+func (*RateLimitHit) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *RateLimitHit) GetDescriptor_() []*RateLimitEntry {
+	if x != nil {
+		return x.Descriptor_
+	}
+	return nil
+}
+
+func (x *RateLimitHit) GetHits() int32 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+// RateLimitEntry is a key/value of a descriptor sent to the rate limit service
+type RateLimitEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateLimitEntry) Reset() {
+	*x = RateLimitEntry{}
+	mi := &file_api_proto_mesh_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimitEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimitEntry) ProtoMessage() {}
+
+func (x *RateLimitEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimitEntry.ProtoReflect.Descriptor instead.
+func (*RateLimitEntry) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *RateLimitEntry) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *RateLimitEntry) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// RateLimitResponse has one status per hit, in the same order
+type RateLimitResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Statuses      []*RateLimitStatus     `protobuf:"bytes,1,rep,name=statuses,proto3" json:"statuses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateLimitResponse) Reset() {
+	*x = RateLimitResponse{}
+	mi := &file_api_proto_mesh_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimitResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimitResponse) ProtoMessage() {}
+
+func (x *RateLimitResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimitResponse.ProtoReflect.Descriptor instead.
+func (*RateLimitResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *RateLimitResponse) GetStatuses() []*RateLimitStatus {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+// RateLimitStatus tells how many tokens were granted and the state of the quota
+type RateLimitStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Granted       int32                  `protobuf:"varint,1,opt,name=granted,proto3" json:"granted,omitempty"`                                 // Tokens granted, fewer than asked means the quota is exhausted
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`                                     // Quota burst (0: no quota matches, unlimited)
+	Remaining     int32                  `protobuf:"varint,3,opt,name=remaining,proto3" json:"remaining,omitempty"`                             // Tokens left in the quota
+	RetryAfterMs  int32                  `protobuf:"varint,4,opt,name=retry_after_ms,json=retryAfterMs,proto3" json:"retry_after_ms,omitempty"` // Time until the next token
+	ResetMs       int32                  `protobuf:"varint,5,opt,name=reset_ms,json=resetMs,proto3" json:"reset_ms,omitempty"`                  // Time until the quota is full again
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateLimitStatus) Reset() {
+	*x = RateLimitStatus{}
+	mi := &file_api_proto_mesh_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimitStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimitStatus) ProtoMessage() {}
+
+func (x *RateLimitStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimitStatus.ProtoReflect.Descriptor instead.
+func (*RateLimitStatus) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *RateLimitStatus) GetGranted() int32 {
+	if x != nil {
+		return x.Granted
+	}
+	return 0
+}
+
+func (x *RateLimitStatus) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *RateLimitStatus) GetRemaining() int32 {
+	if x != nil {
+		return x.Remaining
+	}
+	return 0
+}
+
+func (x *RateLimitStatus) GetRetryAfterMs() int32 {
+	if x != nil {
+		return x.RetryAfterMs
+	}
+	return 0
+}
+
+func (x *RateLimitStatus) GetResetMs() int32 {
+	if x != nil {
+		return x.ResetMs
+	}
+	return 0
+}
+
+// RateLimitQuota is a global limit, configured on the control plane
+// It applies to descriptors with the same keys, in the same order
+type RateLimitQuota struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Descriptor_       []*RateLimitEntry      `protobuf:"bytes,1,rep,name=descriptor,proto3" json:"descriptor,omitempty"` // An empty value matches any value, each value gets its own bucket
+	RequestsPerSecond float64                `protobuf:"fixed64,2,opt,name=requests_per_second,json=requestsPerSecond,proto3" json:"requests_per_second,omitempty"`
+	Burst             int32                  `protobuf:"varint,3,opt,name=burst,proto3" json:"burst,omitempty"` // Bucket size (default: requests_per_second, at least 1)
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *RateLimitQuota) Reset() {
+	*x = RateLimitQuota{}
+	mi := &file_api_proto_mesh_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateLimitQuota) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateLimitQuota) ProtoMessage() {}
+
+func (x *RateLimitQuota) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_mesh_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateLimitQuota.ProtoReflect.Descriptor instead.
+func (*RateLimitQuota) Descriptor() ([]byte, []int) {
+	return file_api_proto_mesh_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *RateLimitQuota) GetDescriptor_() []*RateLimitEntry {
+	if x != nil {
+		return x.Descriptor_
+	}
+	return nil
+}
+
+func (x *RateLimitQuota) GetRequestsPerSecond() float64 {
+	if x != nil {
+		return x.RequestsPerSecond
+	}
+	return 0
+}
+
+func (x *RateLimitQuota) GetBurst() int32 {
+	if x != nil {
+		return x.Burst
+	}
+	return 0
+}
+
+var File_api_proto_mesh_proto protoreflect.FileDescriptor
+
+const file_api_proto_mesh_proto_rawDesc = "" +
+	"\n" +
+	"\x14api/proto/mesh.proto\x12\x04mesh\"\xc9\x01\n" +
+	"\tProxyInfo\x12\x19\n" +
+	"\bproxy_id\x18\x01 \x01(\tR\aproxyId\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x1f\n" +
+	"\vlisten_addr\x18\x03 \x01(\tR\n" +
+	"listenAddr\x12\x1c\n" +
+	"\tnamespace\x18\x04 \x01(\tR\tnamespace\x12'\n" +
+	"\x0fservice_account\x18\x05 \x01(\tR\x0eserviceAccount\x12\x1f\n" +
+	"\vinstance_id\x18\x06 \x01(\tR\n" +
+	"instanceId\"J\n" +
+	"\x14RegistrationResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"]\n" +
+	"\fHealthReport\x12\x19\n" +
+	"\bproxy_id\x18\x01 \x01(\tR\aproxyId\x122\n" +
+	"\tendpoints\x18\x02 \x03(\v2\x14.mesh.EndpointHealthR\tendpoints\"`\n" +
+	"\x0eEndpointHealth\x12\x18\n" +
+	"\acluster\x18\x01 \x01(\tR\acluster\x12\x1a\n" +
+	"\bendpoint\x18\x02 \x01(\tR\bendpoint\x12\x18\n" +
+	"\ahealthy\x18\x03 \x01(\bR\ahealthy\"\x16\n" +
+	"\x14HealthReportResponse\"\xf4\x03\n" +
+	"\fConfigUpdate\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12#\n" +
+	"\x06routes\x18\x02 \x03(\v2\v.mesh.RouteR\x06routes\x12$\n" +
+	"\x04auth\x18\x03 \x01(\v2\x10.mesh.AuthConfigR\x04auth\x12)\n" +
+	"\bclusters\x18\x04 \x03(\v2\r.mesh.ClusterR\bclusters\x12;\n" +
+	"\x0frequest_headers\x18\x05 \x01(\v2\x12.mesh.HeaderPolicyR\x0erequestHeaders\x12=\n" +
+	"\x10response_headers\x18\x06 \x01(\v2\x12.mesh.HeaderPolicyR\x0fresponseHeaders\x128\n" +
+	"\fcertificates\x18\a \x03(\v2\x14.mesh.TlsCertificateR\fcertificates\x12L\n" +
+	"\x14workload_certificate\x18\b \x01(\v2\x19.mesh.WorkloadCertificateR\x13workloadCertificate\x12P\n" +
+	"\x16authorization_policies\x18\t \x03(\v2\x19.mesh.AuthorizationPolicyR\x15authorizationPolicies\"\x8b\x01\n" +
+	"\x13AuthorizationPolicy\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x121\n" +
+	"\x06action\x18\x02 \x01(\x0e2\x19.mesh.AuthorizationActionR\x06action\x12-\n" +
+	"\x05rules\x18\x03 \x03(\v2\x17.mesh.AuthorizationRuleR\x05rules\"\xb9\x01\n" +
+	"\x11AuthorizationRule\x12\x1e\n" +
+	"\n" +
+	"principals\x18\x01 \x03(\tR\n" +
+	"principals\x12%\n" +
+	"\x0enot_principals\x18\x02 \x03(\tR\rnotPrincipals\x12\x14\n" +
+	"\x05paths\x18\x03 \x03(\tR\x05paths\x12\x18\n" +
+	"\amethods\x18\x04 \x03(\tR\amethods\x12-\n" +
+	"\aheaders\x18\x05 \x03(\v2\x13.mesh.KeyValueMatchR\aheaders\"`\n" +
+	"\x13WorkloadCertificate\x12\x19\n" +
+	"\bcert_pem\x18\x01 \x01(\tR\acertPem\x12\x17\n" +
+	"\akey_pem\x18\x02 \x01(\tR\x06keyPem\x12\x15\n" +
+	"\x06ca_pem\x18\x03 \x01(\tR\x05caPem\"X\n" +
+	"\x0eTlsCertificate\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x19\n" +
+	"\bcert_pem\x18\x02 \x01(\tR\acertPem\x12\x17\n" +
+	"\akey_pem\x18\x03 \x01(\tR\x06keyPem\"\xdb\x02\n" +
+	"\aCluster\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
+	"\tendpoints\x18\x02 \x03(\tR\tendpoints\x124\n" +
+	"\fhealth_check\x18\x03 \x01(\v2\x11.mesh.HealthCheckR\vhealthCheck\x12=\n" +
+	"\x0fcircuit_breaker\x18\x04 \x01(\v2\x14.mesh.CircuitBreakerR\x0ecircuitBreaker\x12C\n" +
+	"\x11outlier_detection\x18\x05 \x01(\v2\x16.mesh.OutlierDetectionR\x10outlierDetection\x124\n" +
+	"\fretry_budget\x18\x06 \x01(\v2\x11.mesh.RetryBudgetR\vretryBudget\x12\x12\n" +
+	"\x04mtls\x18\a \x01(\bR\x04mtls\x12\x1a\n" +
+	"\bidentity\x18\b \x01(\tR\bidentity\"h\n" +
+	"\vRetryBudget\x12%\n" +
+	"\x0ebudget_percent\x18\x01 \x01(\x01R\rbudgetPercent\x122\n" +
+	"\x15min_retry_concurrency\x18\x02 \x01(\x05R\x13minRetryConcurrency\"\xa0\x03\n" +
+	"\x10OutlierDetection\x12:\n" +
+	"\x19consecutive_server_errors\x18\x01 \x01(\x05R\x17consecutiveServerErrors\x12@\n" +
+	"\x1cconsecutive_gateway_failures\x18\x02 \x01(\x05R\x1aconsecutiveGatewayFailures\x12%\n" +
+	"\x0elatency_factor\x18\x03 \x01(\x01R\rlatencyFactor\x120\n" +
+	"\x14latency_min_requests\x18\x04 \x01(\x05R\x12latencyMinRequests\x12\x1f\n" +
+	"\vinterval_ms\x18\x05 \x01(\x05R\n" +
+	"intervalMs\x121\n" +
+	"\x15base_ejection_time_ms\x18\x06 \x01(\x05R\x12baseEjectionTimeMs\x12/\n" +
+	"\x14max_ejection_time_ms\x18\a \x01(\x05R\x11maxEjectionTimeMs\x120\n" +
+	"\x14max_ejection_percent\x18\b \x01(\x05R\x12maxEjectionPercent\"\xcd\x02\n" +
+	"\x0eCircuitBreaker\x12-\n" +
+	"\x12consecutive_errors\x18\x01 \x01(\x05R\x11consecutiveErrors\x12\x1f\n" +
+	"\verror_ratio\x18\x02 \x01(\x01R\n" +
+	"errorRatio\x12\x1b\n" +
+	"\twindow_ms\x18\x03 \x01(\x05R\bwindowMs\x12!\n" +
+	"\fmin_requests\x18\x04 \x01(\x05R\vminRequests\x12(\n" +
+	"\x10open_duration_ms\x18\x05 \x01(\x05R\x0eopenDurationMs\x12,\n" +
+	"\x12half_open_requests\x18\x06 \x01(\x05R\x10halfOpenRequests\x12!\n" +
+	"\fmax_requests\x18\a \x01(\x05R\vmaxRequests\x120\n" +
+	"\x14max_pending_requests\x18\b \x01(\x05R\x12maxPendingRequests\"\x9f\x02\n" +
+	"\vHealthCheck\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1f\n" +
+	"\vinterval_ms\x18\x02 \x01(\x05R\n" +
+	"intervalMs\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x03 \x01(\x05R\ttimeoutMs\x12+\n" +
+	"\x11healthy_threshold\x18\x04 \x01(\x05R\x10healthyThreshold\x12/\n" +
+	"\x13unhealthy_threshold\x18\x05 \x01(\x05R\x12unhealthyThreshold\x12.\n" +
+	"\x13expected_status_min\x18\x06 \x01(\x05R\x11expectedStatusMin\x12.\n" +
+	"\x13expected_status_max\x18\a \x01(\x05R\x11expectedStatusMax\"}\n" +
+	"\n" +
+	"AuthConfig\x12\x12\n" +
+	"\x04jwks\x18\x01 \x01(\tR\x04jwks\x12\x16\n" +
+	"\x06issuer\x18\x02 \x01(\tR\x06issuer\x12\x1a\n" +
+	"\baudience\x18\x03 \x01(\tR\baudience\x12'\n" +
+	"\bapi_keys\x18\x04 \x03(\v2\f.mesh.ApiKeyR\aapiKeys\"L\n" +
+	"\x06ApiKey\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x18\n" +
+	"\asubject\x18\x02 \x01(\tR\asubject\x12\x16\n" +
+	"\x06scopes\x18\x03 \x03(\tR\x06scopes\"\x83\n" +
+	"\n" +
+	"\x05Route\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x18\n" +
+	"\abackend\x18\x02 \x01(\tR\abackend\x12#\n" +
+	"\rauth_required\x18\x03 \x01(\bR\fauthRequired\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x04 \x01(\x05R\ttimeoutMs\x12.\n" +
+	"\n" +
+	"path_match\x18\x05 \x01(\x0e2\x0f.mesh.PathMatchR\tpathMatch\x12\x18\n" +
+	"\acluster\x18\x06 \x01(\tR\acluster\x125\n" +
+	"\tlb_policy\x18\a \x01(\x0e2\x18.mesh.LoadBalancerPolicyR\blbPolicy\x121\n" +
+	"\vhash_policy\x18\b \x01(\v2\x10.mesh.HashPolicyR\n" +
+	"hashPolicy\x124\n" +
+	"\fretry_policy\x18\t \x01(\v2\x11.mesh.RetryPolicyR\vretryPolicy\x12.\n" +
+	"\n" +
+	"rate_limit\x18\n" +
+	" \x01(\v2\x0f.mesh.RateLimitR\trateLimit\x12A\n" +
+	"\x11global_rate_limit\x18\v \x01(\v2\x15.mesh.GlobalRateLimitR\x0fglobalRateLimit\x12\x18\n" +
+	"\amethods\x18\f \x03(\tR\amethods\x12\x14\n" +
+	"\x05hosts\x18\r \x03(\tR\x05hosts\x12-\n" +
+	"\aheaders\x18\x0e \x03(\v2\x13.mesh.KeyValueMatchR\aheaders\x126\n" +
+	"\fquery_params\x18\x0f \x03(\v2\x13.mesh.KeyValueMatchR\vqueryParams\x127\n" +
+	"\rtraffic_split\x18\x10 \x01(\v2\x12.mesh.TrafficSplitR\ftrafficSplit\x12+\n" +
+	"\x06mirror\x18\x11 \x01(\v2\x13.mesh.RequestMirrorR\x06mirror\x12*\n" +
+	"\x05fault\x18\x12 \x01(\v2\x14.mesh.FaultInjectionR\x05fault\x12%\n" +
+	"\x0eprefix_rewrite\x18\x13 \x01(\tR\rprefixRewrite\x127\n" +
+	"\rregex_rewrite\x18\x14 \x01(\v2\x12.mesh.RegexRewriteR\fregexRewrite\x12!\n" +
+	"\fhost_rewrite\x18\x15 \x01(\tR\vhostRewrite\x12*\n" +
+	"\x11auto_host_rewrite\x18\x16 \x01(\bR\x0fautoHostRewrite\x12\x12\n" +
+	"\x04name\x18\x17 \x01(\tR\x04name\x12;\n" +
+	"\x0frequest_headers\x18\x18 \x01(\v2\x12.mesh.HeaderPolicyR\x0erequestHeaders\x12=\n" +
+	"\x10response_headers\x18\x19 \x01(\v2\x12.mesh.HeaderPolicyR\x0fresponseHeaders\x12*\n" +
+	"\bredirect\x18\x1a \x01(\v2\x0e.mesh.RedirectR\bredirect\x12=\n" +
+	"\x0fdirect_response\x18\x1b \x01(\v2\x14.mesh.DirectResponseR\x0edirectResponse\x12+\n" +
+	"\tip_access\x18\x1c \x01(\v2\x0e.mesh.IPAccessR\bipAccess\x12+\n" +
+	"\text_authz\x18\x1d \x01(\v2\x0e.mesh.ExtAuthzR\bextAuthz\"\xb1\x01\n" +
+	"\bExtAuthz\x12!\n" +
+	"\fgrpc_address\x18\x01 \x01(\tR\vgrpcAddress\x12\x19\n" +
+	"\bhttp_url\x18\x02 \x01(\tR\ahttpUrl\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x03 \x01(\x05R\ttimeoutMs\x12\x1f\n" +
+	"\vfail_closed\x18\x04 \x01(\bR\n" +
+	"failClosed\x12'\n" +
+	"\x0finclude_headers\x18\x05 \x03(\tR\x0eincludeHeaders\"\x94\x02\n" +
+	"\fCheckRequest\x12\x16\n" +
+	"\x06method\x18\x01 \x01(\tR\x06method\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x14\n" +
+	"\x05query\x18\x03 \x01(\tR\x05query\x12\x12\n" +
+	"\x04host\x18\x04 \x01(\tR\x04host\x12+\n" +
+	"\aheaders\x18\x05 \x03(\v2\x11.mesh.HeaderValueR\aheaders\x12\x1c\n" +
+	"\tprincipal\x18\x06 \x01(\tR\tprincipal\x12\x18\n" +
+	"\asubject\x18\a \x01(\tR\asubject\x12\x16\n" +
+	"\x06scopes\x18\b \x03(\tR\x06scopes\x12\x1b\n" +
+	"\tclient_ip\x18\t \x01(\tR\bclientIp\x12\x14\n" +
+	"\x05route\x18\n" +
+	" \x01(\tR\x05route\"\xe2\x01\n" +
+	"\rCheckResponse\x12\x18\n" +
+	"\aallowed\x18\x01 \x01(\bR\aallowed\x12:\n" +
+	"\x0frequest_headers\x18\x02 \x03(\v2\x11.mesh.HeaderValueR\x0erequestHeaders\x12=\n" +
+	"\x0fdenied_response\x18\x03 \x01(\v2\x14.mesh.DirectResponseR\x0edeniedResponse\x12<\n" +
+	"\x10response_headers\x18\x04 \x03(\v2\x11.mesh.HeaderValueR\x0fresponseHeaders\"4\n" +
+	"\bIPAccess\x12\x14\n" +
+	"\x05allow\x18\x01 \x03(\tR\x05allow\x12\x12\n" +
+	"\x04deny\x18\x02 \x03(\tR\x04deny\"\xbe\x01\n" +
+	"\bRedirect\x12\x16\n" +
+	"\x06scheme\x18\x01 \x01(\tR\x06scheme\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x12\n" +
+	"\x04port\x18\x03 \x01(\x05R\x04port\x12\x12\n" +
+	"\x04path\x18\x04 \x01(\tR\x04path\x12%\n" +
+	"\x0eprefix_rewrite\x18\x05 \x01(\tR\rprefixRewrite\x12\x1f\n" +
+	"\vstrip_query\x18\x06 \x01(\bR\n" +
+	"stripQuery\x12\x16\n" +
+	"\x06status\x18\a \x01(\x05R\x06status\"_\n" +
+	"\x0eDirectResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\x05R\x06status\x12\x12\n" +
+	"\x04body\x18\x02 \x01(\tR\x04body\x12!\n" +
+	"\fcontent_type\x18\x03 \x01(\tR\vcontentType\"\x9b\x01\n" +
+	"\fHeaderPolicy\x12#\n" +
+	"\x03set\x18\x01 \x03(\v2\x11.mesh.HeaderValueR\x03set\x12)\n" +
+	"\x06append\x18\x02 \x03(\v2\x11.mesh.HeaderValueR\x06append\x12#\n" +
+	"\x03add\x18\x03 \x03(\v2\x11.mesh.HeaderValueR\x03add\x12\x16\n" +
+	"\x06remove\x18\x04 \x03(\tR\x06remove\"7\n" +
+	"\vHeaderValue\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"L\n" +
+	"\fRegexRewrite\x12\x18\n" +
+	"\apattern\x18\x01 \x01(\tR\apattern\x12\"\n" +
+	"\fsubstitution\x18\x02 \x01(\tR\fsubstitution\"x\n" +
+	"\x0eFaultInjection\x12&\n" +
+	"\x05delay\x18\x01 \x01(\v2\x10.mesh.FaultDelayR\x05delay\x12&\n" +
+	"\x05abort\x18\x02 \x01(\v2\x10.mesh.FaultAbortR\x05abort\x12\x16\n" +
+	"\x06header\x18\x03 \x01(\tR\x06header\"R\n" +
+	"\n" +
+	"FaultDelay\x12\x19\n" +
+	"\bdelay_ms\x18\x01 \x01(\x05R\adelayMs\x12\x1d\n" +
+	"\apercent\x18\x02 \x01(\x01H\x00R\apercent\x88\x01\x01B\n" +
+	"\n" +
+	"\b_percent\"O\n" +
+	"\n" +
+	"FaultAbort\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\x05R\x06status\x12\x1d\n" +
+	"\apercent\x18\x02 \x01(\x01H\x00R\apercent\x88\x01\x01B\n" +
+	"\n" +
+	"\b_percent\"z\n" +
+	"\rRequestMirror\x12\x18\n" +
+	"\acluster\x18\x01 \x01(\tR\acluster\x12\x1d\n" +
+	"\apercent\x18\x02 \x01(\x01H\x00R\apercent\x88\x01\x01\x12$\n" +
+	"\x0emax_body_bytes\x18\x03 \x01(\x05R\fmaxBodyBytesB\n" +
+	"\n" +
+	"\b_percent\"t\n" +
+	"\fTrafficSplit\x121\n" +
+	"\bclusters\x18\x01 \x03(\v2\x15.mesh.WeightedClusterR\bclusters\x121\n" +
+	"\vhash_policy\x18\x02 \x01(\v2\x10.mesh.HashPolicyR\n" +
+	"hashPolicy\"]\n" +
+	"\x0fWeightedCluster\x12\x18\n" +
+	"\acluster\x18\x01 \x01(\tR\acluster\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\"z\n" +
+	"\rKeyValueMatch\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12'\n" +
+	"\x05match\x18\x02 \x01(\x0e2\x11.mesh.StringMatchR\x05match\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\tR\x05value\x12\x16\n" +
+	"\x06invert\x18\x04 \x01(\bR\x06invert\"i\n" +
+	"\x0fGlobalRateLimit\x125\n" +
+	"\n" +
+	"descriptor\x18\x01 \x03(\v2\x15.mesh.DescriptorEntryR\n" +
+	"descriptor\x12\x1f\n" +
+	"\vfail_closed\x18\x02 \x01(\bR\n" +
+	"failClosed\"}\n" +
+	"\x0fDescriptorEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12*\n" +
+	"\x06source\x18\x02 \x01(\x0e2\x12.mesh.RateLimitKeyR\x06source\x12\x16\n" +
+	"\x06header\x18\x03 \x01(\tR\x06header\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\tR\x05value\"\x8f\x01\n" +
+	"\tRateLimit\x12.\n" +
+	"\x13requests_per_second\x18\x01 \x01(\x01R\x11requestsPerSecond\x12\x14\n" +
+	"\x05burst\x18\x02 \x01(\x05R\x05burst\x12$\n" +
+	"\x03key\x18\x03 \x01(\x0e2\x12.mesh.RateLimitKeyR\x03key\x12\x16\n" +
+	"\x06header\x18\x04 \x01(\tR\x06header\"\xad\x02\n" +
+	"\vRetryPolicy\x12!\n" +
+	"\fmax_attempts\x18\x01 \x01(\x05R\vmaxAttempts\x12(\n" +
+	"\bretry_on\x18\x02 \x03(\x0e2\r.mesh.RetryOnR\aretryOn\x12+\n" +
+	"\x12per_try_timeout_ms\x18\x03 \x01(\x05R\x0fperTryTimeoutMs\x12&\n" +
+	"\x0fbackoff_base_ms\x18\x04 \x01(\x05R\rbackoffBaseMs\x12$\n" +
+	"\x0ebackoff_max_ms\x18\x05 \x01(\x05R\fbackoffMaxMs\x120\n" +
+	"\x14retry_non_idempotent\x18\x06 \x01(\bR\x12retryNonIdempotent\x12$\n" +
+	"\x0emax_body_bytes\x18\a \x01(\x05R\fmaxBodyBytes\"\x92\x01\n" +
+	"\n" +
+	"HashPolicy\x12\x1f\n" +
+	"\x03key\x18\x01 \x01(\x0e2\r.mesh.HashKeyR\x03key\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12!\n" +
+	"\fissue_cookie\x18\x03 \x01(\bR\vissueCookie\x12,\n" +
+	"\x12cookie_ttl_seconds\x18\x04 \x01(\x05R\x10cookieTtlSeconds\"U\n" +
+	"\x10RateLimitRequest\x12\x19\n" +
+	"\bproxy_id\x18\x01 \x01(\tR\aproxyId\x12&\n" +
+	"\x04hits\x18\x02 \x03(\v2\x12.mesh.RateLimitHitR\x04hits\"X\n" +
+	"\fRateLimitHit\x124\n" +
+	"\n" +
+	"descriptor\x18\x01 \x03(\v2\x14.mesh.RateLimitEntryR\n" +
+	"descriptor\x12\x12\n" +
+	"\x04hits\x18\x02 \x01(\x05R\x04hits\"8\n" +
+	"\x0eRateLimitEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"F\n" +
+	"\x11RateLimitResponse\x121\n" +
+	"\bstatuses\x18\x01 \x03(\v2\x15.mesh.RateLimitStatusR\bstatuses\"\xa0\x01\n" +
+	"\x0fRateLimitStatus\x12\x18\n" +
+	"\agranted\x18\x01 \x01(\x05R\agranted\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x1c\n" +
+	"\tremaining\x18\x03 \x01(\x05R\tremaining\x12$\n" +
+	"\x0eretry_after_ms\x18\x04 \x01(\x05R\fretryAfterMs\x12\x19\n" +
+	"\breset_ms\x18\x05 \x01(\x05R\aresetMs\"\x8c\x01\n" +
+	"\x0eRateLimitQuota\x124\n" +
+	"\n" +
+	"descriptor\x18\x01 \x03(\v2\x14.mesh.RateLimitEntryR\n" +
+	"descriptor\x12.\n" +
+	"\x13requests_per_second\x18\x02 \x01(\x01R\x11requestsPerSecond\x12\x14\n" +
+	"\x05burst\x18\x03 \x01(\x05R\x05burst*T\n" +
+	"\x13AuthorizationAction\x12\x1e\n" +
+	"\x1aAUTHORIZATION_ACTION_ALLOW\x10\x00\x12\x1d\n" +
+	"\x19AUTHORIZATION_ACTION_DENY\x10\x01*p\n" +
+	"\vStringMatch\x12\x16\n" +
+	"\x12STRING_MATCH_EXACT\x10\x00\x12\x17\n" +
+	"\x13STRING_MATCH_PREFIX\x10\x01\x12\x16\n" +
+	"\x12STRING_MATCH_REGEX\x10\x02\x12\x18\n" +
+	"\x14STRING_MATCH_PRESENT\x10\x03*c\n" +
+	"\fRateLimitKey\x12\x1c\n" +
+	"\x18RATE_LIMIT_KEY_CLIENT_IP\x10\x00\x12\x1a\n" +
+	"\x16RATE_LIMIT_KEY_SUBJECT\x10\x01\x12\x19\n" +
+	"\x15RATE_LIMIT_KEY_HEADER\x10\x02*\x95\x01\n" +
+	"\aRetryOn\x12\x1c\n" +
+	"\x18RETRY_ON_CONNECT_FAILURE\x10\x00\x12\x12\n" +
+	"\x0eRETRY_ON_RESET\x10\x01\x12\x18\n" +
+	"\x14RETRY_ON_BAD_GATEWAY\x10\x02\x12 \n" +
+	"\x1cRETRY_ON_SERVICE_UNAVAILABLE\x10\x03\x12\x1c\n" +
+	"\x18RETRY_ON_GATEWAY_TIMEOUT\x10\x04*K\n" +
+	"\aHashKey\x12\x16\n" +
+	"\x12HASH_KEY_CLIENT_IP\x10\x00\x12\x13\n" +
+	"\x0fHASH_KEY_HEADER\x10\x01\x12\x13\n" +
+	"\x0fHASH_KEY_COOKIE\x10\x02*m\n" +
+	"\x12LoadBalancerPolicy\x12\x0f\n" +
+	"\vROUND_ROBIN\x10\x00\x12\n" +
 	"\n" +
-	"timeout_ms\x18\x04 \x01(\x05R\ttimeoutMs2\x82\x01\n" +
+	"\x06RANDOM\x10\x01\x12\x11\n" +
+	"\rLEAST_REQUEST\x10\x02\x12\x18\n" +
+	"\x14POWER_OF_TWO_CHOICES\x10\x03\x12\r\n" +
+	"\tRING_HASH\x10\x04*8\n" +
+	"\tPathMatch\x12\x15\n" +
+	"\x11PATH_MATCH_PREFIX\x10\x00\x12\x14\n" +
+	"\x10PATH_MATCH_EXACT\x10\x012\xc2\x01\n" +
 	"\vMeshControl\x125\n" +
 	"\fStreamConfig\x12\x0f.mesh.ProxyInfo\x1a\x12.mesh.ConfigUpdate0\x01\x12<\n" +
-	"\rRegisterProxy\x12\x0f.mesh.ProxyInfo\x1a\x1a.mesh.RegistrationResponseB)Z'github.com/SimonePesci/gomesh/api/protob\x06proto3"
+	"\rRegisterProxy\x12\x0f.mesh.ProxyInfo\x1a\x1a.mesh.RegistrationResponse\x12>\n" +
+	"\fReportHealth\x12\x12.mesh.HealthReport\x1a\x1a.mesh.HealthReportResponse2V\n" +
+	"\x10RateLimitService\x12B\n" +
+	"\x0fShouldRateLimit\x12\x16.mesh.RateLimitRequest\x1a\x17.mesh.RateLimitResponse2I\n" +
+	"\x15ExternalAuthorization\x120\n" +
+	"\x05Check\x12\x12.mesh.CheckRequest\x1a\x13.mesh.CheckResponseB)Z'github.com/SimonePesci/gomesh/api/protob\x06proto3"
 
 var (
 	file_api_proto_mesh_proto_rawDescOnce sync.Once
@@ -296,24 +4003,134 @@ func file_api_proto_mesh_proto_rawDescGZIP() []byte {
 	return file_api_proto_mesh_proto_rawDescData
 }
 
-var file_api_proto_mesh_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_api_proto_mesh_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_api_proto_mesh_proto_msgTypes = make([]protoimpl.MessageInfo, 45)
 var file_api_proto_mesh_proto_goTypes = []any{
-	(*ProxyInfo)(nil),            // 0: mesh.ProxyInfo
-	(*RegistrationResponse)(nil), // 1: mesh.RegistrationResponse
-	(*ConfigUpdate)(nil),         // 2: mesh.ConfigUpdate
-	(*Route)(nil),                // 3: mesh.Route
+	(AuthorizationAction)(0),     // 0: mesh.AuthorizationAction
+	(StringMatch)(0),             // 1: mesh.StringMatch
+	(RateLimitKey)(0),            // 2: mesh.RateLimitKey
+	(RetryOn)(0),                 // 3: mesh.RetryOn
+	(HashKey)(0),                 // 4: mesh.HashKey
+	(LoadBalancerPolicy)(0),      // 5: mesh.LoadBalancerPolicy
+	(PathMatch)(0),               // 6: mesh.PathMatch
+	(*ProxyInfo)(nil),            // 7: mesh.ProxyInfo
+	(*RegistrationResponse)(nil), // 8: mesh.RegistrationResponse
+	(*HealthReport)(nil),         // 9: mesh.HealthReport
+	(*EndpointHealth)(nil),       // 10: mesh.EndpointHealth
+	(*HealthReportResponse)(nil), // 11: mesh.HealthReportResponse
+	(*ConfigUpdate)(nil),         // 12: mesh.ConfigUpdate
+	(*AuthorizationPolicy)(nil),  // 13: mesh.AuthorizationPolicy
+	(*AuthorizationRule)(nil),    // 14: mesh.AuthorizationRule
+	(*WorkloadCertificate)(nil),  // 15: mesh.WorkloadCertificate
+	(*TlsCertificate)(nil),       // 16: mesh.TlsCertificate
+	(*Cluster)(nil),              // 17: mesh.Cluster
+	(*RetryBudget)(nil),          // 18: mesh.RetryBudget
+	(*OutlierDetection)(nil),     // 19: mesh.OutlierDetection
+	(*CircuitBreaker)(nil),       // 20: mesh.CircuitBreaker
+	(*HealthCheck)(nil),          // 21: mesh.HealthCheck
+	(*AuthConfig)(nil),           // 22: mesh.AuthConfig
+	(*ApiKey)(nil),               // 23: mesh.ApiKey
+	(*Route)(nil),                // 24: mesh.Route
+	(*ExtAuthz)(nil),             // 25: mesh.ExtAuthz
+	(*CheckRequest)(nil),         // 26: mesh.CheckRequest
+	(*CheckResponse)(nil),        // 27: mesh.CheckResponse
+	(*IPAccess)(nil),             // 28: mesh.IPAccess
+	(*Redirect)(nil),             // 29: mesh.Redirect
+	(*DirectResponse)(nil),       // 30: mesh.DirectResponse
+	(*HeaderPolicy)(nil),         // 31: mesh.HeaderPolicy
+	(*HeaderValue)(nil),          // 32: mesh.HeaderValue
+	(*RegexRewrite)(nil),         // 33: mesh.RegexRewrite
+	(*FaultInjection)(nil),       // 34: mesh.FaultInjection
+	(*FaultDelay)(nil),           // 35: mesh.FaultDelay
+	(*FaultAbort)(nil),           // 36: mesh.FaultAbort
+	(*RequestMirror)(nil),        // 37: mesh.RequestMirror
+	(*TrafficSplit)(nil),         // 38: mesh.TrafficSplit
+	(*WeightedCluster)(nil),      // 39: mesh.WeightedCluster
+	(*KeyValueMatch)(nil),        // 40: mesh.KeyValueMatch
+	(*GlobalRateLimit)(nil),      // 41: mesh.GlobalRateLimit
+	(*DescriptorEntry)(nil),      // 42: mesh.DescriptorEntry
+	(*RateLimit)(nil),            // 43: mesh.RateLimit
+	(*RetryPolicy)(nil),          // 44: mesh.RetryPolicy
+	(*HashPolicy)(nil),           // 45: mesh.HashPolicy
+	(*RateLimitRequest)(nil),     // 46: mesh.RateLimitRequest
+	(*RateLimitHit)(nil),         // 47: mesh.RateLimitHit
+	(*RateLimitEntry)(nil),       // 48: mesh.RateLimitEntry
+	(*RateLimitResponse)(nil),    // 49: mesh.RateLimitResponse
+	(*RateLimitStatus)(nil),      // 50: mesh.RateLimitStatus
+	(*RateLimitQuota)(nil),       // 51: mesh.RateLimitQuota
 }
 var file_api_proto_mesh_proto_depIdxs = []int32{
-	3, // 0: mesh.ConfigUpdate.routes:type_name -> mesh.Route
-	0, // 1: mesh.MeshControl.StreamConfig:input_type -> mesh.ProxyInfo
-	0, // 2: mesh.MeshControl.RegisterProxy:input_type -> mesh.ProxyInfo
-	2, // 3: mesh.MeshControl.StreamConfig:output_type -> mesh.ConfigUpdate
-	1, // 4: mesh.MeshControl.RegisterProxy:output_type -> mesh.RegistrationResponse
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	10, // 0: mesh.HealthReport.endpoints:type_name -> mesh.EndpointHealth
+	24, // 1: mesh.ConfigUpdate.routes:type_name -> mesh.Route
+	22, // 2: mesh.ConfigUpdate.auth:type_name -> mesh.AuthConfig
+	17, // 3: mesh.ConfigUpdate.clusters:type_name -> mesh.Cluster
+	31, // 4: mesh.ConfigUpdate.request_headers:type_name -> mesh.HeaderPolicy
+	31, // 5: mesh.ConfigUpdate.response_headers:type_name -> mesh.HeaderPolicy
+	16, // 6: mesh.ConfigUpdate.certificates:type_name -> mesh.TlsCertificate
+	15, // 7: mesh.ConfigUpdate.workload_certificate:type_name -> mesh.WorkloadCertificate
+	13, // 8: mesh.ConfigUpdate.authorization_policies:type_name -> mesh.AuthorizationPolicy
+	0,  // 9: mesh.AuthorizationPolicy.action:type_name -> mesh.AuthorizationAction
+	14, // 10: mesh.AuthorizationPolicy.rules:type_name -> mesh.AuthorizationRule
+	40, // 11: mesh.AuthorizationRule.headers:type_name -> mesh.KeyValueMatch
+	21, // 12: mesh.Cluster.health_check:type_name -> mesh.HealthCheck
+	20, // 13: mesh.Cluster.circuit_breaker:type_name -> mesh.CircuitBreaker
+	19, // 14: mesh.Cluster.outlier_detection:type_name -> mesh.OutlierDetection
+	18, // 15: mesh.Cluster.retry_budget:type_name -> mesh.RetryBudget
+	23, // 16: mesh.AuthConfig.api_keys:type_name -> mesh.ApiKey
+	6,  // 17: mesh.Route.path_match:type_name -> mesh.PathMatch
+	5,  // 18: mesh.Route.lb_policy:type_name -> mesh.LoadBalancerPolicy
+	45, // 19: mesh.Route.hash_policy:type_name -> mesh.HashPolicy
+	44, // 20: mesh.Route.retry_policy:type_name -> mesh.RetryPolicy
+	43, // 21: mesh.Route.rate_limit:type_name -> mesh.RateLimit
+	41, // 22: mesh.Route.global_rate_limit:type_name -> mesh.GlobalRateLimit
+	40, // 23: mesh.Route.headers:type_name -> mesh.KeyValueMatch
+	40, // 24: mesh.Route.query_params:type_name -> mesh.KeyValueMatch
+	38, // 25: mesh.Route.traffic_split:type_name -> mesh.TrafficSplit
+	37, // 26: mesh.Route.mirror:type_name -> mesh.RequestMirror
+	34, // 27: mesh.Route.fault:type_name -> mesh.FaultInjection
+	33, // 28: mesh.Route.regex_rewrite:type_name -> mesh.RegexRewrite
+	31, // 29: mesh.Route.request_headers:type_name -> mesh.HeaderPolicy
+	31, // 30: mesh.Route.response_headers:type_name -> mesh.HeaderPolicy
+	29, // 31: mesh.Route.redirect:type_name -> mesh.Redirect
+	30, // 32: mesh.Route.direct_response:type_name -> mesh.DirectResponse
+	28, // 33: mesh.Route.ip_access:type_name -> mesh.IPAccess
+	25, // 34: mesh.Route.ext_authz:type_name -> mesh.ExtAuthz
+	32, // 35: mesh.CheckRequest.headers:type_name -> mesh.HeaderValue
+	32, // 36: mesh.CheckResponse.request_headers:type_name -> mesh.HeaderValue
+	30, // 37: mesh.CheckResponse.denied_response:type_name -> mesh.DirectResponse
+	32, // 38: mesh.CheckResponse.response_headers:type_name -> mesh.HeaderValue
+	32, // 39: mesh.HeaderPolicy.set:type_name -> mesh.HeaderValue
+	32, // 40: mesh.HeaderPolicy.append:type_name -> mesh.HeaderValue
+	32, // 41: mesh.HeaderPolicy.add:type_name -> mesh.HeaderValue
+	35, // 42: mesh.FaultInjection.delay:type_name -> mesh.FaultDelay
+	36, // 43: mesh.FaultInjection.abort:type_name -> mesh.FaultAbort
+	39, // 44: mesh.TrafficSplit.clusters:type_name -> mesh.WeightedCluster
+	45, // 45: mesh.TrafficSplit.hash_policy:type_name -> mesh.HashPolicy
+	1,  // 46: mesh.KeyValueMatch.match:type_name -> mesh.StringMatch
+	42, // 47: mesh.GlobalRateLimit.descriptor:type_name -> mesh.DescriptorEntry
+	2,  // 48: mesh.DescriptorEntry.source:type_name -> mesh.RateLimitKey
+	2,  // 49: mesh.RateLimit.key:type_name -> mesh.RateLimitKey
+	3,  // 50: mesh.RetryPolicy.retry_on:type_name -> mesh.RetryOn
+	4,  // 51: mesh.HashPolicy.key:type_name -> mesh.HashKey
+	47, // 52: mesh.RateLimitRequest.hits:type_name -> mesh.RateLimitHit
+	48, // 53: mesh.RateLimitHit.descriptor:type_name -> mesh.RateLimitEntry
+	50, // 54: mesh.RateLimitResponse.statuses:type_name -> mesh.RateLimitStatus
+	48, // 55: mesh.RateLimitQuota.descriptor:type_name -> mesh.RateLimitEntry
+	7,  // 56: mesh.MeshControl.StreamConfig:input_type -> mesh.ProxyInfo
+	7,  // 57: mesh.MeshControl.RegisterProxy:input_type -> mesh.ProxyInfo
+	9,  // 58: mesh.MeshControl.ReportHealth:input_type -> mesh.HealthReport
+	46, // 59: mesh.RateLimitService.ShouldRateLimit:input_type -> mesh.RateLimitRequest
+	26, // 60: mesh.ExternalAuthorization.Check:input_type -> mesh.CheckRequest
+	12, // 61: mesh.MeshControl.StreamConfig:output_type -> mesh.ConfigUpdate
+	8,  // 62: mesh.MeshControl.RegisterProxy:output_type -> mesh.RegistrationResponse
+	11, // 63: mesh.MeshControl.ReportHealth:output_type -> mesh.HealthReportResponse
+	49, // 64: mesh.RateLimitService.ShouldRateLimit:output_type -> mesh.RateLimitResponse
+	27, // 65: mesh.ExternalAuthorization.Check:output_type -> mesh.CheckResponse
+	61, // [61:66] is the sub-list for method output_type
+	56, // [56:61] is the sub-list for method input_type
+	56, // [56:56] is the sub-list for extension type_name
+	56, // [56:56] is the sub-list for extension extendee
+	0,  // [0:56] is the sub-list for field type_name
 }
 
 func init() { file_api_proto_mesh_proto_init() }
@@ -321,18 +4138,22 @@ func file_api_proto_mesh_proto_init() {
 	if File_api_proto_mesh_proto != nil {
 		return
 	}
+	file_api_proto_mesh_proto_msgTypes[28].OneofWrappers = []any{}
+	file_api_proto_mesh_proto_msgTypes[29].OneofWrappers = []any{}
+	file_api_proto_mesh_proto_msgTypes[30].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_proto_mesh_proto_rawDesc), len(file_api_proto_mesh_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      7,
+			NumMessages:   45,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   3,
 		},
 		GoTypes:           file_api_proto_mesh_proto_goTypes,
 		DependencyIndexes: file_api_proto_mesh_proto_depIdxs,
+		EnumInfos:         file_api_proto_mesh_proto_enumTypes,
 		MessageInfos:      file_api_proto_mesh_proto_msgTypes,
 	}.Build()
 	File_api_proto_mesh_proto = out.File
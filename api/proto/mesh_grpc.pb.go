@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	MeshControl_StreamConfig_FullMethodName  = "/mesh.MeshControl/StreamConfig"
 	MeshControl_RegisterProxy_FullMethodName = "/mesh.MeshControl/RegisterProxy"
+	MeshControl_ReportHealth_FullMethodName  = "/mesh.MeshControl/ReportHealth"
 )
 
 // MeshControlClient is the client API for MeshControl service.
@@ -34,10 +35,16 @@ type MeshControlClient interface {
 	// StreamConfig establishes a long-lived connection between proxy and control plane
 	// The proxy sends its info, and the control plane streams config updates
 	// This is a SERVER STREAMING RPC - server sends multiple messages
+	// Control Plane -> Proxy: StreamConfig ... Control Plane sends multiple messages
 	StreamConfig(ctx context.Context, in *ProxyInfo, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ConfigUpdate], error)
 	// RegisterProxy allows a proxy to register itself with the control plane
 	// This is a UNARY RPC - single request, single response
+	// Proxy -> Control Plane: RegisterProxy
 	RegisterProxy(ctx context.Context, in *ProxyInfo, opts ...grpc.CallOption) (*RegistrationResponse, error)
+	// ReportHealth sends the proxy's view of its upstream endpoints health
+	// The proxy sends a full report when it connects and every time an endpoint changes state
+	// Proxy -> Control Plane: ReportHealth
+	ReportHealth(ctx context.Context, in *HealthReport, opts ...grpc.CallOption) (*HealthReportResponse, error)
 }
 
 type meshControlClient struct {
@@ -77,6 +84,16 @@ func (c *meshControlClient) RegisterProxy(ctx context.Context, in *ProxyInfo, op
 	return out, nil
 }
 
+func (c *meshControlClient) ReportHealth(ctx context.Context, in *HealthReport, opts ...grpc.CallOption) (*HealthReportResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthReportResponse)
+	err := c.cc.Invoke(ctx, MeshControl_ReportHealth_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MeshControlServer is the server API for MeshControl service.
 // All implementations must embed UnimplementedMeshControlServer
 // for forward compatibility.
@@ -88,10 +105,16 @@ type MeshControlServer interface {
 	// StreamConfig establishes a long-lived connection between proxy and control plane
 	// The proxy sends its info, and the control plane streams config updates
 	// This is a SERVER STREAMING RPC - server sends multiple messages
+	// Control Plane -> Proxy: StreamConfig ... Control Plane sends multiple messages
 	StreamConfig(*ProxyInfo, grpc.ServerStreamingServer[ConfigUpdate]) error
 	// RegisterProxy allows a proxy to register itself with the control plane
 	// This is a UNARY RPC - single request, single response
+	// Proxy -> Control Plane: RegisterProxy
 	RegisterProxy(context.Context, *ProxyInfo) (*RegistrationResponse, error)
+	// ReportHealth sends the proxy's view of its upstream endpoints health
+	// The proxy sends a full report when it connects and every time an endpoint changes state
+	// Proxy -> Control Plane: ReportHealth
+	ReportHealth(context.Context, *HealthReport) (*HealthReportResponse, error)
 	mustEmbedUnimplementedMeshControlServer()
 }
 
@@ -108,6 +131,9 @@ func (UnimplementedMeshControlServer) StreamConfig(*ProxyInfo, grpc.ServerStream
 func (UnimplementedMeshControlServer) RegisterProxy(context.Context, *ProxyInfo) (*RegistrationResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method RegisterProxy not implemented")
 }
+func (UnimplementedMeshControlServer) ReportHealth(context.Context, *HealthReport) (*HealthReportResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ReportHealth not implemented")
+}
 func (UnimplementedMeshControlServer) mustEmbedUnimplementedMeshControlServer() {}
 func (UnimplementedMeshControlServer) testEmbeddedByValue()                     {}
 
@@ -158,6 +184,24 @@ func _MeshControl_RegisterProxy_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MeshControl_ReportHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeshControlServer).ReportHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MeshControl_ReportHealth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeshControlServer).ReportHealth(ctx, req.(*HealthReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MeshControl_ServiceDesc is the grpc.ServiceDesc for MeshControl service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -169,6 +213,10 @@ var MeshControl_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RegisterProxy",
 			Handler:    _MeshControl_RegisterProxy_Handler,
 		},
+		{
+			MethodName: "ReportHealth",
+			Handler:    _MeshControl_ReportHealth_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -179,3 +227,227 @@ var MeshControl_ServiceDesc = grpc.ServiceDesc{
 	},
 	Metadata: "api/proto/mesh.proto",
 }
+
+const (
+	RateLimitService_ShouldRateLimit_FullMethodName = "/mesh.RateLimitService/ShouldRateLimit"
+)
+
+// RateLimitServiceClient is the client API for RateLimitService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RateLimitService hands out the tokens of global quotas, shared by all the proxies
+// Control Plane implements this server (in memory), proxies ask for tokens in batches
+type RateLimitServiceClient interface {
+	// ShouldRateLimit takes tokens for one or more descriptors
+	// Proxy -> Rate limit service: ShouldRateLimit
+	ShouldRateLimit(ctx context.Context, in *RateLimitRequest, opts ...grpc.CallOption) (*RateLimitResponse, error)
+}
+
+type rateLimitServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRateLimitServiceClient(cc grpc.ClientConnInterface) RateLimitServiceClient {
+	return &rateLimitServiceClient{cc}
+}
+
+func (c *rateLimitServiceClient) ShouldRateLimit(ctx context.Context, in *RateLimitRequest, opts ...grpc.CallOption) (*RateLimitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RateLimitResponse)
+	err := c.cc.Invoke(ctx, RateLimitService_ShouldRateLimit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RateLimitServiceServer is the server API for RateLimitService service.
+// All implementations must embed UnimplementedRateLimitServiceServer
+// for forward compatibility.
+//
+// RateLimitService hands out the tokens of global quotas, shared by all the proxies
+// Control Plane implements this server (in memory), proxies ask for tokens in batches
+type RateLimitServiceServer interface {
+	// ShouldRateLimit takes tokens for one or more descriptors
+	// Proxy -> Rate limit service: ShouldRateLimit
+	ShouldRateLimit(context.Context, *RateLimitRequest) (*RateLimitResponse, error)
+	mustEmbedUnimplementedRateLimitServiceServer()
+}
+
+// UnimplementedRateLimitServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRateLimitServiceServer struct{}
+
+func (UnimplementedRateLimitServiceServer) ShouldRateLimit(context.Context, *RateLimitRequest) (*RateLimitResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ShouldRateLimit not implemented")
+}
+func (UnimplementedRateLimitServiceServer) mustEmbedUnimplementedRateLimitServiceServer() {}
+func (UnimplementedRateLimitServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeRateLimitServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RateLimitServiceServer will
+// result in compilation errors.
+type UnsafeRateLimitServiceServer interface {
+	mustEmbedUnimplementedRateLimitServiceServer()
+}
+
+func RegisterRateLimitServiceServer(s grpc.ServiceRegistrar, srv RateLimitServiceServer) {
+	// If the following call panics, it indicates UnimplementedRateLimitServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RateLimitService_ServiceDesc, srv)
+}
+
+func _RateLimitService_ShouldRateLimit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RateLimitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RateLimitServiceServer).ShouldRateLimit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RateLimitService_ShouldRateLimit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RateLimitServiceServer).ShouldRateLimit(ctx, req.(*RateLimitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RateLimitService_ServiceDesc is the grpc.ServiceDesc for RateLimitService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RateLimitService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mesh.RateLimitService",
+	HandlerType: (*RateLimitServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ShouldRateLimit",
+			Handler:    _RateLimitService_ShouldRateLimit_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/proto/mesh.proto",
+}
+
+const (
+	ExternalAuthorization_Check_FullMethodName = "/mesh.ExternalAuthorization/Check"
+)
+
+// ExternalAuthorizationClient is the client API for ExternalAuthorization service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ExternalAuthorization decides which requests may reach the routes that delegate to it (ext_authz)
+// Users implement this server, proxies ask it once per request
+type ExternalAuthorizationClient interface {
+	// Check tells whether the request may go on, and what to add to it or answer instead
+	// Proxy -> External authorization service: Check
+	Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error)
+}
+
+type externalAuthorizationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewExternalAuthorizationClient(cc grpc.ClientConnInterface) ExternalAuthorizationClient {
+	return &externalAuthorizationClient{cc}
+}
+
+func (c *externalAuthorizationClient) Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckResponse)
+	err := c.cc.Invoke(ctx, ExternalAuthorization_Check_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExternalAuthorizationServer is the server API for ExternalAuthorization service.
+// All implementations must embed UnimplementedExternalAuthorizationServer
+// for forward compatibility.
+//
+// ExternalAuthorization decides which requests may reach the routes that delegate to it (ext_authz)
+// Users implement this server, proxies ask it once per request
+type ExternalAuthorizationServer interface {
+	// Check tells whether the request may go on, and what to add to it or answer instead
+	// Proxy -> External authorization service: Check
+	Check(context.Context, *CheckRequest) (*CheckResponse, error)
+	mustEmbedUnimplementedExternalAuthorizationServer()
+}
+
+// UnimplementedExternalAuthorizationServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedExternalAuthorizationServer struct{}
+
+func (UnimplementedExternalAuthorizationServer) Check(context.Context, *CheckRequest) (*CheckResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedExternalAuthorizationServer) mustEmbedUnimplementedExternalAuthorizationServer() {}
+func (UnimplementedExternalAuthorizationServer) testEmbeddedByValue()                               {}
+
+// UnsafeExternalAuthorizationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ExternalAuthorizationServer will
+// result in compilation errors.
+type UnsafeExternalAuthorizationServer interface {
+	mustEmbedUnimplementedExternalAuthorizationServer()
+}
+
+func RegisterExternalAuthorizationServer(s grpc.ServiceRegistrar, srv ExternalAuthorizationServer) {
+	// If the following call panics, it indicates UnimplementedExternalAuthorizationServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ExternalAuthorization_ServiceDesc, srv)
+}
+
+func _ExternalAuthorization_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExternalAuthorizationServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExternalAuthorization_Check_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExternalAuthorizationServer).Check(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ExternalAuthorization_ServiceDesc is the grpc.ServiceDesc for ExternalAuthorization service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ExternalAuthorization_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mesh.ExternalAuthorization",
+	HandlerType: (*ExternalAuthorizationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Check",
+			Handler:    _ExternalAuthorization_Check_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/proto/mesh.proto",
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/SimonePesci/gomesh/pkg/controlplane"
 
 	pb "github.com/SimonePesci/gomesh/api/proto"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
 
@@ -20,6 +25,16 @@ func main() {
 
 	port := flag.Int("port", 9090, "Port the server will listen on for gRPC connections")
 	production := flag.Bool("production", false, "Whether to run in production mode (JSON logging)")
+	configFile := flag.String("config", "", "YAML file with routes, clusters and auth (reloaded on SIGHUP, empty: built-in defaults)")
+	workloadCerts := flag.Bool("workload-certs", false, "Act as a CA and issue workload certificates to the proxies, for mTLS between them")
+	caCert := flag.String("ca-cert", "", "PEM certificate of the CA signing the workload certificates (empty: a CA generated in memory)")
+	caKey := flag.String("ca-key", "", "PEM private key of the CA")
+	trustDomain := flag.String("trust-domain", "gomesh", "Trust domain of the workload identities (spiffe://<trust domain>/ns/<namespace>/sa/<service account>)")
+	workloadCertTTL := flag.Duration("workload-cert-ttl", time.Hour, "Lifetime of the workload certificates, they are renewed after two thirds of it")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate served on the gRPC port (empty: plaintext)")
+	tlsKey := flag.String("tls-key", "", "PEM private key of the gRPC certificate")
+	clientCA := flag.String("client-ca", "", "PEM CAs of the proxy client certificates, whose CN must be the proxy ID (requires -tls-cert)")
+	bootstrapTokens := flag.String("bootstrap-tokens", "", "YAML file mapping each proxy ID to the token it must present (requires -tls-cert)")
 	flag.Parse()
 
 	var logger *zap.Logger
@@ -41,14 +56,54 @@ func main() {
 		zap.Bool("production", *production),
 	)
 
-	// Create the control plane server
+	// Create the control plane server and the global rate limit service it hosts
 	controlPlane := controlplane.NewServer(logger)
+	rateLimits := controlplane.NewRateLimitServer(logger)
+
+	if *configFile != "" {
+		fileConfig, err := controlplane.LoadConfigFile(*configFile)
+		if err == nil {
+			err = rateLimits.SetQuotas(fileConfig.RateLimitQuotas)
+		}
+		if err != nil {
+			logger.Fatal("failed to load config file",
+				zap.String("path", *configFile),
+				zap.Error(err),
+			)
+		}
+		controlPlane.ConfigStore().ReplaceConfig(fileConfig.Update)
+
+		logger.Info("config file loaded",
+			zap.String("path", *configFile),
+			zap.Int("routes", len(fileConfig.Update.Routes)),
+			zap.Int("clusters", len(fileConfig.Update.Clusters)),
+			zap.Int("rate_limit_quotas", len(fileConfig.RateLimitQuotas)),
+		)
+	}
+
+	if *workloadCerts {
+		ca, err := loadCertificateAuthority(*caCert, *caKey, *trustDomain, *workloadCertTTL, logger)
+		if err != nil {
+			logger.Fatal("failed to set up the certificate authority",
+				zap.Error(err),
+			)
+		}
+		controlPlane.SetCertificateAuthority(ca)
+	}
+
+	serverOptions, err := grpcServerOptions(controlPlane, *tlsCert, *tlsKey, *clientCA, *bootstrapTokens, logger)
+	if err != nil {
+		logger.Fatal("failed to set up the gRPC security",
+			zap.Error(err),
+		)
+	}
 
 	// Create the gRPC server
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(serverOptions...)
 
 	// Now register both
 	pb.RegisterMeshControlServer(grpcServer, controlPlane)
+	pb.RegisterRateLimitServiceServer(grpcServer, rateLimits)
 
 	logger.Info("gRPC server registered")
 
@@ -74,26 +129,156 @@ func main() {
 		serverErrors <- grpcServer.Serve(listener)
 	}()
 
-	select {
+	// SIGHUP reloads the config file and pushes it to the proxies (e.g., to tighten rate limits)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
 
-	// Handle errors coming from the grpc server
-	case err := <- serverErrors:
-		if err != nil {
-			logger.Fatal("server error",
-				zap.Error(err),
+	for running := true; running; {
+		select {
+
+		// Handle errors coming from the grpc server
+		case err := <- serverErrors:
+			if err != nil {
+				logger.Fatal("server error",
+					zap.Error(err),
+				)
+			}
+			running = false
+
+		case <- reloadChan:
+			reloadConfig(controlPlane, rateLimits, *configFile, logger)
+
+		// Handle signals (e.g. Ctrl+C)
+		case sig := <- sigChan:
+			logger.Info("received signal",
+				zap.String("signal", sig.String()),
 			)
+
+			logger.Info("shutting down server gracefully...")
+			controlPlane.Stop()
+			grpcServer.GracefulStop()
+			logger.Info("server terminated gracefully")
+			running = false
 		}
+	}
 
-	// Handle signals (e.g. Ctrl+C)
-	case sig := <- sigChan:
-		logger.Info("received signal",
-			zap.String("signal", sig.String()),
-		)
+	logger.Info("control plane terminated successfully")
+}
 
-		logger.Info("shutting down server gracefully...")
-		grpcServer.GracefulStop()
-		logger.Info("server terminated gracefully")
+// TLS and proxy authentication of the gRPC server, from the flags
+func grpcServerOptions(controlPlane *controlplane.Server, certFile string, keyFile string, clientCAFile string, tokensFile string, logger *zap.Logger) ([]grpc.ServerOption, error) {
+	// Dead proxies are noticed, so their stream ends and their ID can be used again
+	options := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time: 30 * time.Second,
+			Timeout: 10 * time.Second,
+		}),
 	}
 
-	logger.Info("control plane terminated successfully")
-}
\ No newline at end of file
+	if certFile == "" {
+		if clientCAFile != "" || tokensFile != "" {
+			return nil, fmt.Errorf("-client-ca and -bootstrap-tokens require -tls-cert")
+		}
+		logger.Warn("control channel is plaintext and proxies are not authenticated, use -tls-cert outside of development")
+		return options, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		caPEM, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the client CA: %w", err)
+		}
+
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in the client CA %s", clientCAFile)
+		}
+
+		// Proxies with a token don't need a certificate, the interceptors reject the calls with neither
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+
+	var tokens map[string]string
+	if tokensFile != "" {
+		tokens, err = controlplane.LoadBootstrapTokens(tokensFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if clientCAFile == "" && tokensFile == "" {
+		logger.Warn("proxies are not authenticated, use -client-ca or -bootstrap-tokens outside of development")
+		return options, nil
+	}
+
+	auth := controlplane.NewProxyAuth(tokens, clientCAFile != "")
+	controlPlane.SetProxyAuth(auth)
+
+	logger.Info("proxy authentication enabled",
+		zap.Bool("client_certificates", clientCAFile != ""),
+		zap.Int("bootstrap_tokens", len(tokens)),
+	)
+	return append(options, auth.ServerOptions()...), nil
+}
+
+// Load the CA from its files, or generate one when none is given
+func loadCertificateAuthority(certFile string, keyFile string, trustDomain string, ttl time.Duration, logger *zap.Logger) (*controlplane.CertificateAuthority, error) {
+	if certFile == "" && keyFile == "" {
+		logger.Warn("no CA configured, generating one in memory: workload certificates are not trusted across controller restarts")
+		return controlplane.GenerateCertificateAuthority(trustDomain, ttl)
+	}
+
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("certificate authority loaded",
+		zap.String("cert", certFile),
+		zap.Duration("workload_cert_ttl", ttl),
+	)
+	return controlplane.NewCertificateAuthority(certPEM, keyPEM, trustDomain, ttl)
+}
+
+// Load the config file again and broadcast it, a broken file keeps the current config
+func reloadConfig(controlPlane *controlplane.Server, rateLimits *controlplane.RateLimitServer, configFile string, logger *zap.Logger) {
+	if configFile == "" {
+		logger.Warn("SIGHUP received but no config file is set, nothing to reload")
+		return
+	}
+
+	fileConfig, err := controlplane.LoadConfigFile(configFile)
+	if err == nil {
+		err = rateLimits.SetQuotas(fileConfig.RateLimitQuotas)
+	}
+	if err != nil {
+		logger.Error("config reload failed, keeping the current config",
+			zap.String("path", configFile),
+			zap.Error(err),
+		)
+		return
+	}
+
+	config := controlPlane.ConfigStore().ReplaceConfig(fileConfig.Update)
+	logger.Info("config file reloaded",
+		zap.String("path", configFile),
+		zap.Int64("version", config.Version),
+	)
+
+	controlPlane.BroadcastConfigUpdate(config)
+}
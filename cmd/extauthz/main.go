@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// This is a stub external authorization service for testing routes with ext_authz
+// It serves the same decision over gRPC and over HTTP (JSON):
+// requests with "Authorization: Bearer <token>" are allowed and get an X-User header, the others get a 401
+
+type stub struct {
+	pb.UnimplementedExternalAuthorizationServer
+	token string
+}
+
+func (s *stub) Check(ctx context.Context, request *pb.CheckRequest) (*pb.CheckResponse, error) {
+	authorization := ""
+	for _, header := range request.Headers {
+		if strings.EqualFold(header.Name, "Authorization") {
+			authorization = header.Value
+		}
+	}
+
+	allowed := authorization == "Bearer "+s.token
+	log.Printf("[EXTAUTHZ] %s %s from %s (principal %q): allowed=%v", request.Method, request.Path, request.ClientIp, request.Principal, allowed)
+
+	if allowed {
+		return &pb.CheckResponse{
+			Allowed: true,
+			RequestHeaders: []*pb.HeaderValue{{Name: "X-User", Value: "stub-user"}},
+		}, nil
+	}
+
+	return &pb.CheckResponse{
+		DeniedResponse: &pb.DirectResponse{
+			Status: http.StatusUnauthorized,
+			Body: `{"error":"missing or invalid token"}`,
+			ContentType: "application/json",
+		},
+		ResponseHeaders: []*pb.HeaderValue{{Name: "WWW-Authenticate", Value: `Bearer realm="extauthz-stub"`}},
+	}, nil
+}
+
+// HTTP flavour: the CheckRequest comes as JSON in the body, the CheckResponse goes back the same way
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := &pb.CheckRequest{}
+	if err := protojson.Unmarshal(data, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, _ := s.Check(r.Context(), request)
+	body, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func main() {
+	grpcAddr := flag.String("grpc", ":9191", "Address of the gRPC service")
+	httpAddr := flag.String("http", ":9192", "Address of the HTTP service")
+	token := flag.String("token", "let-me-in", "Bearer token that is allowed")
+	flag.Parse()
+
+	s := &stub{token: *token}
+
+	listener, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		log.Fatalf("ext authz stub failed: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterExternalAuthorizationServer(grpcServer, s)
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Fatalf("ext authz stub failed: %v", err)
+		}
+	}()
+
+	log.Printf("[EXTAUTHZ] gRPC on %s, HTTP on %s, allowed token %q", *grpcAddr, *httpAddr, *token)
+	log.Printf("[EXTAUTHZ] Try: curl -H 'Authorization: Bearer %s' http://localhost:8000/<route with ext_authz>", *token)
+
+	if err := http.ListenAndServe(*httpAddr, s); err != nil {
+		log.Fatalf("ext authz stub failed: %v", err)
+	}
+}
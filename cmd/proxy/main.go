@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"os/signal"
@@ -42,6 +43,23 @@ func main() {
 		)
 	}
 
+	// Follow the control plane in the background (if configured)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if config.Proxy.ControlPlane.Address != "" {
+		client := proxy.NewControlPlaneClient(config, server.Handler(), logger)
+		go func() {
+			if err := client.Run(ctx); err != nil {
+				logger.Error("Control plane client stopped",
+					zap.Error(err),
+				)
+			}
+		}()
+	} else {
+		logger.Info("No control plane configured, serving the static backend only")
+	}
+
 	signChan := make(chan os.Signal, 1)
 	signal.Notify(signChan, os.Interrupt, syscall.SIGTERM)
 
@@ -63,6 +81,9 @@ func main() {
 			zap.String("signal", sig.String()),
 		)
 
+		// Stop following the control plane before draining requests
+		cancel()
+
 		if err := server.Shutdown(10 * time.Second); err != nil {
 			logger.Warn("Failed to shutdown server gracefully",
 				zap.Error(err),
@@ -0,0 +1,147 @@
+package controlplane
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// ProxyAuth authenticates the proxies calling the control plane
+// A proxy proves its ID with a bootstrap token, or with a client certificate whose CN is the ID
+type ProxyAuth struct {
+	// Proxy ID -> SHA-256 of its token
+	tokens map[string][sha256.Size]byte
+
+	// Client certificates are verified by the TLS layer, only their CN is read here
+	clientCerts bool
+}
+
+type proxyIDKey struct{}
+
+// Load the bootstrap tokens from a YAML file mapping each proxy ID to its token
+//
+//	proxy-1: 6f1c0e...
+//	events-proxy: 92ab4d...
+func LoadBootstrapTokens(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read bootstrap tokens: %w", err)
+	}
+
+	var tokens map[string]string
+	if err := yaml.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("Failed to parse bootstrap tokens: %w", err)
+	}
+
+	for proxyID, token := range tokens {
+		if proxyID == "" || token == "" {
+			return nil, fmt.Errorf("Invalid bootstrap tokens: empty proxy ID or token")
+		}
+	}
+	return tokens, nil
+}
+
+// Accept the given bootstrap tokens and, when clientCerts is set, verified client certificates
+func NewProxyAuth(tokens map[string]string, clientCerts bool) *ProxyAuth {
+	auth := &ProxyAuth{
+		tokens: make(map[string][sha256.Size]byte, len(tokens)),
+		clientCerts: clientCerts,
+	}
+	for proxyID, token := range tokens {
+		auth.tokens[proxyID] = sha256.Sum256([]byte(token))
+	}
+	return auth
+}
+
+// Interceptors rejecting the calls without valid credentials, on every service of the server
+func (a *ProxyAuth) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			proxyID, err := a.authenticate(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(context.WithValue(ctx, proxyIDKey{}, proxyID), req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			proxyID, err := a.authenticate(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authenticatedStream{ServerStream: stream, ctx: context.WithValue(stream.Context(), proxyIDKey{}, proxyID)})
+		}),
+	}
+}
+
+// The context of the stream carries the authenticated proxy ID
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// Find which proxy the caller is, from its client certificate or its token
+func (a *ProxyAuth) authenticate(ctx context.Context) (string, error) {
+	if a.clientCerts {
+		if p, ok := peer.FromContext(ctx); ok {
+			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+				return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, nil
+			}
+		}
+	}
+
+	token := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token, _ = strings.CutPrefix(values[0], "Bearer ")
+		}
+	}
+	if token == "" {
+		return "", status.Error(codes.Unauthenticated, "missing proxy credentials (bootstrap token or client certificate)")
+	}
+
+	// Every token is compared, so the time taken doesn't tell which one was close
+	hash := sha256.Sum256([]byte(token))
+	proxyID := ""
+	for id, expected := range a.tokens {
+		if subtle.ConstantTimeCompare(hash[:], expected[:]) == 1 {
+			proxyID = id
+		}
+	}
+	if proxyID == "" {
+		return "", status.Error(codes.Unauthenticated, "invalid bootstrap token")
+	}
+	return proxyID, nil
+}
+
+// Check that the caller is the proxy it claims to be (always true without authentication)
+func (a *ProxyAuth) authorize(ctx context.Context, proxyID string) error {
+	if a == nil {
+		return nil
+	}
+
+	authenticated, _ := ctx.Value(proxyIDKey{}).(string)
+	if authenticated == proxyID {
+		return nil
+	}
+
+	// Tokens list the known proxies, certificates don't
+	if _, known := a.tokens[proxyID]; !known && !a.clientCerts {
+		return status.Errorf(codes.PermissionDenied, "unknown proxy %s", proxyID)
+	}
+	return status.Errorf(codes.PermissionDenied, "credentials are for proxy %s, not %s", authenticated, proxyID)
+}
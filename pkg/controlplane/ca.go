@@ -0,0 +1,203 @@
+package controlplane
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+// Workload certificates are renewed once this share of their lifetime has passed
+const renewAfter = 2.0 / 3.0
+
+// Certificates start a bit in the past, so proxies with a late clock accept them
+const clockSkew = time.Minute
+
+// Lifetime of the in-memory CA generated when none is configured
+const generatedCALifetime = 365 * 24 * time.Hour
+
+// CertificateAuthority signs the workload certificates of the proxies
+type CertificateAuthority struct {
+	cert *x509.Certificate
+	key crypto.Signer
+
+	// Sent to the proxies as their trust bundle
+	certPEM string
+
+	// Lifetime of the workload certificates
+	ttl time.Duration
+
+	// First part of the workload identities (e.g., "gomesh" in spiffe://gomesh/ns/default/sa/orders)
+	trustDomain string
+}
+
+// Load a CA from its certificate and private key, in PEM
+func NewCertificateAuthority(certPEM []byte, keyPEM []byte, trustDomain string, ttl time.Duration) (*CertificateAuthority, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA certificate: %w", err)
+	}
+
+	if !pair.Leaf.IsCA {
+		return nil, fmt.Errorf("certificate %q is not a CA", pair.Leaf.Subject.CommonName)
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("CA private key can't sign")
+	}
+
+	return newCertificateAuthority(pair.Leaf, key, trustDomain, ttl)
+}
+
+// Generate a self-signed CA that only lives in memory
+// Certificates it issued are not trusted anymore once the controller restarts (proxies get new ones when they reconnect)
+func GenerateCertificateAuthority(trustDomain string, ttl time.Duration) (*CertificateAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{CommonName: "gomesh CA"},
+		NotBefore: now.Add(-clockSkew),
+		NotAfter: now.Add(generatedCALifetime),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCertificateAuthority(cert, key, trustDomain, ttl)
+}
+
+func newCertificateAuthority(cert *x509.Certificate, key crypto.Signer, trustDomain string, ttl time.Duration) (*CertificateAuthority, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("workload certificate TTL must be positive")
+	}
+
+	if trustDomain == "" || strings.ContainsAny(trustDomain, "/:@?#") || strings.ToLower(trustDomain) != trustDomain {
+		return nil, fmt.Errorf("invalid trust domain %q (lowercase, no /, :, @, ? or #)", trustDomain)
+	}
+
+	return &CertificateAuthority{
+		cert: cert,
+		key: key,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		ttl: ttl,
+		trustDomain: trustDomain,
+	}, nil
+}
+
+// Identity of a proxy, as a SPIFFE ID: spiffe://<trust domain>/ns/<namespace>/sa/<service account>
+func (ca *CertificateAuthority) Identity(info *pb.ProxyInfo) (*url.URL, error) {
+	namespace := info.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	serviceAccount := info.ServiceAccount
+	if serviceAccount == "" {
+		serviceAccount = info.ProxyId
+	}
+
+	for _, segment := range []string{namespace, serviceAccount} {
+		if segment == "" || strings.ContainsAny(segment, "/?#%") {
+			return nil, fmt.Errorf("invalid identity segment %q (empty, or contains /, ?, # or %%)", segment)
+		}
+	}
+
+	return &url.URL{
+		Scheme: "spiffe",
+		Host: ca.trustDomain,
+		Path: "/ns/" + namespace + "/sa/" + serviceAccount,
+	}, nil
+}
+
+// Issue a new certificate (and key) for a proxy, valid for the CA TTL
+// It authenticates the proxy both as a client and as a server, its identity is the URI SAN
+func (ca *CertificateAuthority) Issue(info *pb.ProxyInfo) (*pb.WorkloadCertificate, time.Time, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	identity, err := ca.Identity(info)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(ca.ttl)
+
+	// Never outlive the CA
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{CommonName: info.ProxyId},
+		NotBefore: now.Add(-clockSkew),
+		NotAfter: notAfter,
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs: []*url.URL{identity},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to sign certificate for %s: %w", info.ProxyId, err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// Renew well before expiry, so a control plane hiccup doesn't leave the proxy without a valid certificate
+	renewAt := now.Add(time.Duration(float64(notAfter.Sub(now)) * renewAfter))
+
+	return &pb.WorkloadCertificate{
+		CertPem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		KeyPem: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		CaPem: ca.certPEM,
+	}, renewAt, nil
+}
+
+// 128 random bits, as recommended for certificate serial numbers
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
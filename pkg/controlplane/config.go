@@ -1,9 +1,11 @@
 package controlplane
 
 import (
+	"fmt"
 	"sync"
 
 	pb "github.com/SimonePesci/gomesh/api/proto"
+	"google.golang.org/protobuf/proto"
 )
 
 // Routing configuration managed by the control plane
@@ -12,6 +14,12 @@ type ConfigStore struct {
 	mu sync.RWMutex // Protects concurrent access to the config store
 	version int64 // Version number of the current config
 	routes []*pb.Route // List of routing rules: use pointer to avoid copying the whole slice
+	auth *pb.AuthConfig // Credentials proxies use on auth_required routes (nil: proxies use their local ones)
+	clusters []*pb.Cluster // Groups of endpoints routes can point to
+	requestHeaders *pb.HeaderPolicy // Request header changes on every route (nil: none)
+	responseHeaders *pb.HeaderPolicy // Response header changes on every route (nil: none)
+	certificates []*pb.TlsCertificate // Certificates served by the proxies with TLS enabled
+	authorizationPolicies []*pb.AuthorizationPolicy // Who may call what, checked by every proxy
 }
 
 // Create a new config store with default route
@@ -23,11 +31,23 @@ func NewConfigStore() *ConfigStore {
 			// Default route is the test backend we have
 			{
 				Path: "/",
-				Backend: "localhost:3000",
+				Cluster: "backend",
 				AuthRequired: false,
 				TimeoutMs: 5000,
 			},
 		},
+		clusters: []*pb.Cluster{
+			// The test backend exposes /health, so proxies can probe it
+			{
+				Name: "backend",
+				Endpoints: []string{"localhost:3000"},
+				HealthCheck: &pb.HealthCheck{
+					Path: "/health",
+					IntervalMs: 5000,
+					TimeoutMs: 1000,
+				},
+			},
+		},
 	}
 }
 
@@ -39,9 +59,20 @@ func (cs *ConfigStore) GetConfig() *pb.ConfigUpdate {
 	defer cs.mu.RUnlock()
 
 	// Return the current config version and routes (a copy to avoid modifying the original slice)
+	return cs.snapshot()
+}
+
+// Build the ConfigUpdate for the current state (caller must hold the lock)
+func (cs *ConfigStore) snapshot() *pb.ConfigUpdate {
 	return &pb.ConfigUpdate{
 		Version: cs.version,
 		Routes: cs.routes,
+		Auth: cs.auth,
+		Clusters: cs.clusters,
+		RequestHeaders: cs.requestHeaders,
+		ResponseHeaders: cs.responseHeaders,
+		Certificates: cs.certificates,
+		AuthorizationPolicies: cs.authorizationPolicies,
 	}
 }
 
@@ -59,10 +90,7 @@ func (cs * ConfigStore) UpdateConfig(routes []*pb.Route) *pb.ConfigUpdate {
 	cs.routes = routes
 
 	// Return a new struct for simplicity (could have just returned void also)
-	return &pb.ConfigUpdate{
-		Version: cs.version,
-		Routes: cs.routes,
-	}
+	return cs.snapshot()
 }
 
 // Add a new route to the config store
@@ -78,8 +106,144 @@ func (cs *ConfigStore) AddRoute(route *pb.Route) *pb.ConfigUpdate {
 	cs.routes = append(cs.routes, route)
 
 	// Return a new struct for simplicity (could have just returned void also)
-	return &pb.ConfigUpdate{
-		Version: cs.version,
-		Routes: cs.routes,
+	return cs.snapshot()
+}
+
+// Replace the clusters (and their endpoints) known to the proxies
+func (cs *ConfigStore) UpdateClusters(clusters []*pb.Cluster) *pb.ConfigUpdate {
+	// Lock the config store
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Increment version number
+	cs.version++
+
+	cs.clusters = clusters
+
+	return cs.snapshot()
+}
+
+// Set the credentials (JWKS, API keys) distributed to all proxies
+func (cs *ConfigStore) SetAuthConfig(auth *pb.AuthConfig) *pb.ConfigUpdate {
+	// Lock the config store
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Increment version number
+	cs.version++
+
+	cs.auth = auth
+
+	return cs.snapshot()
+}
+
+// Replace routes, clusters, auth, header policies, certificates and authorization policies at once (e.g., when the config file is reloaded)
+func (cs *ConfigStore) ReplaceConfig(update *pb.ConfigUpdate) *pb.ConfigUpdate {
+	// Lock the config store
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Increment version number
+	cs.version++
+
+	cs.routes = update.Routes
+	cs.clusters = update.Clusters
+	cs.auth = update.Auth
+	cs.requestHeaders = update.RequestHeaders
+	cs.responseHeaders = update.ResponseHeaders
+	cs.certificates = update.Certificates
+	cs.authorizationPolicies = update.AuthorizationPolicies
+
+	return cs.snapshot()
+}
+
+// Replace the authorization policies of every proxy
+func (cs *ConfigStore) SetAuthorizationPolicies(policies []*pb.AuthorizationPolicy) *pb.ConfigUpdate {
+	// Lock the config store
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Increment version number
+	cs.version++
+
+	cs.authorizationPolicies = policies
+
+	return cs.snapshot()
+}
+
+// Replace the certificates the proxies serve (e.g., after a renewal)
+func (cs *ConfigStore) SetCertificates(certificates []*pb.TlsCertificate) *pb.ConfigUpdate {
+	// Lock the config store
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Increment version number
+	cs.version++
+
+	cs.certificates = certificates
+
+	return cs.snapshot()
+}
+
+// Set the header policies applied on every route of every proxy
+func (cs *ConfigStore) SetHeaderPolicies(requestHeaders *pb.HeaderPolicy, responseHeaders *pb.HeaderPolicy) *pb.ConfigUpdate {
+	// Lock the config store
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Increment version number
+	cs.version++
+
+	cs.requestHeaders = requestHeaders
+	cs.responseHeaders = responseHeaders
+
+	return cs.snapshot()
+}
+
+// Set the weights of the traffic split of the routes on path (e.g., to move a canary from 5% to 10%)
+// Clusters of the split missing from weights keep their weight
+// Fails without changing anything if no route on path has a split, or a cluster is not part of it
+func (cs *ConfigStore) SetSplitWeights(path string, weights map[string]int32) (*pb.ConfigUpdate, error) {
+	// Lock the config store
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Routes already sent may be read concurrently: change copies
+	routes := make([]*pb.Route, len(cs.routes))
+	copy(routes, cs.routes)
+
+	found := make(map[string]bool, len(weights))
+	changed := false
+
+	for i, route := range routes {
+		if route.Path != path || route.TrafficSplit == nil {
+			continue
+		}
+
+		route = proto.Clone(route).(*pb.Route)
+		for _, weighted := range route.TrafficSplit.Clusters {
+			if weight, ok := weights[weighted.Cluster]; ok {
+				weighted.Weight = weight
+				found[weighted.Cluster] = true
+			}
+		}
+		routes[i] = route
+		changed = true
+	}
+
+	if !changed {
+		return nil, fmt.Errorf("no route on path %q has a traffic split", path)
+	}
+	for cluster := range weights {
+		if !found[cluster] {
+			return nil, fmt.Errorf("cluster %q is not part of the traffic split of path %q", cluster, path)
+		}
 	}
+
+	// Increment version number
+	cs.version++
+
+	cs.routes = routes
+
+	return cs.snapshot(), nil
 }
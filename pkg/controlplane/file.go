@@ -0,0 +1,138 @@
+package controlplane
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig is what the controller config file describes
+type FileConfig struct {
+	// Routes, clusters and auth, sent to the proxies
+	Update *pb.ConfigUpdate
+
+	// Global quotas, served by the rate limit service
+	RateLimitQuotas []*pb.RateLimitQuota
+}
+
+// Load the controller config from a YAML file
+// The file follows the ConfigUpdate message: field names as in mesh.proto, enums by name,
+// plus a rate_limit_quotas list of RateLimitQuota
+// Certificates can point to PEM files (cert_file, key_file) instead of inlining cert_pem and key_pem
+//
+//	routes:
+//	  - path: /api
+//	    cluster: api
+//	    rate_limit: {requests_per_second: 50, key: RATE_LIMIT_KEY_HEADER, header: X-Tenant}
+//	clusters:
+//	  - name: api
+//	    endpoints: ["10.0.0.1:5000", "10.0.0.2:5000"]
+//	rate_limit_quotas:
+//	  - descriptor: [{key: tenant}]
+//	    requests_per_second: 1000
+//	certificates:
+//	  - name: api
+//	    cert_file: /etc/gomesh/tls/api.crt
+//	    key_file: /etc/gomesh/tls/api.key
+func LoadConfigFile(path string) (*FileConfig, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config file: %w", err)
+	}
+
+	var document map[string]any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("Failed to parse config file: %w", err)
+	}
+
+	config := &FileConfig{
+		Update: &pb.ConfigUpdate{},
+	}
+
+	// Quotas are not part of the ConfigUpdate, parse them on their own
+	if quotas, ok := document["rate_limit_quotas"]; ok {
+		delete(document, "rate_limit_quotas")
+
+		list, ok := quotas.([]any)
+		if !ok {
+			return nil, fmt.Errorf("Invalid config file: rate_limit_quotas must be a list")
+		}
+		for i, item := range list {
+			quota := &pb.RateLimitQuota{}
+			if err := unmarshalYAMLValue(item, quota); err != nil {
+				return nil, fmt.Errorf("Invalid rate limit quota %d in config file: %w", i, err)
+			}
+			config.RateLimitQuotas = append(config.RateLimitQuotas, quota)
+		}
+	}
+
+	if certificates, ok := document["certificates"]; ok {
+		if err := readCertificateFiles(certificates); err != nil {
+			return nil, fmt.Errorf("Invalid config file: %w", err)
+		}
+	}
+
+	if err := unmarshalYAMLValue(document, config.Update); err != nil {
+		return nil, fmt.Errorf("Invalid config file: %w", err)
+	}
+
+	if len(config.Update.Routes) == 0 {
+		return nil, fmt.Errorf("Invalid config file: no routes")
+	}
+
+	return config, nil
+}
+
+// Replace the cert_file and key_file of each certificate with the PEM they contain
+func readCertificateFiles(certificates any) error {
+	list, ok := certificates.([]any)
+	if !ok {
+		return fmt.Errorf("certificates must be a list")
+	}
+
+	for i, item := range list {
+		certificate, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("certificate %d must be a map", i)
+		}
+
+		for fileKey, pemKey := range map[string]string{"cert_file": "cert_pem", "key_file": "key_pem"} {
+			file, ok := certificate[fileKey]
+			if !ok {
+				continue
+			}
+			delete(certificate, fileKey)
+
+			path, ok := file.(string)
+			if !ok {
+				return fmt.Errorf("certificate %d: %s must be a string", i, fileKey)
+			}
+			if _, ok := certificate[pemKey]; ok {
+				return fmt.Errorf("certificate %d: %s and %s can't be combined", i, fileKey, pemKey)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("certificate %d: %w", i, err)
+			}
+			certificate[pemKey] = string(data)
+		}
+	}
+
+	return nil
+}
+
+// YAML value -> JSON -> message, so the protobuf JSON mapping does the field and enum work
+func unmarshalYAMLValue(value any, message proto.Message) error {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(jsonData, message)
+}
@@ -0,0 +1,207 @@
+package controlplane
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"go.uber.org/zap"
+)
+
+// RateLimitServer is an in-memory rate limit service
+// Every proxy takes tokens from the same buckets, so a quota holds across proxies
+type RateLimitServer struct {
+	pb.UnimplementedRateLimitServiceServer
+
+	logger *zap.Logger
+
+	mu sync.Mutex
+	quotas []*quota
+}
+
+// A quota and its buckets, one per distinct descriptor
+type quota struct {
+	config *pb.RateLimitQuota
+	rate float64
+	burst float64
+	buckets map[string]*bucket
+	lastSweep time.Time
+}
+
+// How often buckets that are full again get dropped
+const quotaSweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	updated time.Time
+}
+
+// NewRateLimitServer creates a rate limit service without quotas (everything is allowed)
+func NewRateLimitServer(logger *zap.Logger) *RateLimitServer {
+	return &RateLimitServer{
+		logger: logger,
+	}
+}
+
+// SetQuotas replaces the quotas, buckets start full
+func (s *RateLimitServer) SetQuotas(configs []*pb.RateLimitQuota) error {
+
+	quotas := make([]*quota, 0, len(configs))
+	for i, config := range configs {
+		if len(config.Descriptor_) == 0 {
+			return fmt.Errorf("quota %d has an empty descriptor", i)
+		}
+		for _, entry := range config.Descriptor_ {
+			if entry.Key == "" {
+				return fmt.Errorf("quota %d has an entry without key", i)
+			}
+		}
+		if config.RequestsPerSecond <= 0 || math.IsInf(config.RequestsPerSecond, 0) || math.IsNaN(config.RequestsPerSecond) {
+			return fmt.Errorf("quota %d must have a positive requests_per_second", i)
+		}
+		if config.Burst < 0 {
+			return fmt.Errorf("quota %d has a negative burst", i)
+		}
+
+		burst := float64(config.Burst)
+		if burst == 0 {
+			burst = math.Max(1, math.Ceil(config.RequestsPerSecond))
+		}
+
+		quotas = append(quotas, &quota{
+			config: config,
+			rate: config.RequestsPerSecond,
+			burst: burst,
+			buckets: make(map[string]*bucket),
+			lastSweep: time.Now(),
+		})
+	}
+
+	s.mu.Lock()
+	s.quotas = quotas
+	s.mu.Unlock()
+
+	s.logger.Info("rate limit quotas updated",
+		zap.Int("quotas", len(quotas)),
+	)
+
+	return nil
+}
+
+// ShouldRateLimit grants the tokens asked for each descriptor, as far as its quota allows
+func (s *RateLimitServer) ShouldRateLimit(ctx context.Context, req *pb.RateLimitRequest) (*pb.RateLimitResponse, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	resp := &pb.RateLimitResponse{
+		Statuses: make([]*pb.RateLimitStatus, 0, len(req.Hits)),
+	}
+
+	for _, hit := range req.Hits {
+		q := s.match(hit.Descriptor_)
+
+		// No quota for this descriptor: unlimited
+		if q == nil {
+			resp.Statuses = append(resp.Statuses, &pb.RateLimitStatus{Granted: hit.Hits})
+			continue
+		}
+
+		status := q.take(descriptorKey(hit.Descriptor_), int(hit.Hits), now)
+		if status.Granted < hit.Hits {
+			s.logger.Debug("quota exhausted",
+				zap.String("proxy_id", req.ProxyId),
+				zap.String("descriptor", descriptorKey(hit.Descriptor_)),
+				zap.Int32("asked", hit.Hits),
+				zap.Int32("granted", status.Granted),
+			)
+		}
+		resp.Statuses = append(resp.Statuses, status)
+	}
+
+	return resp, nil
+}
+
+// First quota with the same keys as the descriptor, and the same values where it sets one
+// (caller must hold the lock)
+func (s *RateLimitServer) match(descriptor []*pb.RateLimitEntry) *quota {
+	for _, q := range s.quotas {
+		if len(q.config.Descriptor_) != len(descriptor) {
+			continue
+		}
+
+		matches := true
+		for i, entry := range q.config.Descriptor_ {
+			if entry.Key != descriptor[i].Key || (entry.Value != "" && entry.Value != descriptor[i].Value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return q
+		}
+	}
+	return nil
+}
+
+// Take up to hits tokens from the bucket of the descriptor
+func (q *quota) take(key string, hits int, now time.Time) *pb.RateLimitStatus {
+	if hits < 0 {
+		hits = 0
+	}
+
+	b, ok := q.buckets[key]
+	if !ok {
+		b = &bucket{tokens: q.burst, updated: now}
+		q.buckets[key] = b
+	}
+
+	b.tokens = math.Min(q.burst, b.tokens+now.Sub(b.updated).Seconds()*q.rate)
+	b.updated = now
+
+	granted := int(math.Min(float64(hits), math.Floor(b.tokens)))
+	b.tokens -= float64(granted)
+
+	status := &pb.RateLimitStatus{
+		Granted: int32(granted),
+		Limit: int32(q.burst),
+		Remaining: int32(b.tokens),
+		ResetMs: int32(math.Ceil((q.burst - b.tokens) / q.rate * 1000)),
+	}
+	if b.tokens < 1 {
+		status.RetryAfterMs = int32(math.Ceil((1 - b.tokens) / q.rate * 1000))
+	}
+
+	q.sweep(now)
+
+	return status
+}
+
+// Forget the buckets that refilled completely, they are the same as new ones
+func (q *quota) sweep(now time.Time) {
+	if now.Sub(q.lastSweep) < quotaSweepInterval {
+		return
+	}
+	q.lastSweep = now
+
+	for key, b := range q.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*q.rate >= q.burst {
+			delete(q.buckets, key)
+		}
+	}
+}
+
+// Bucket key of a descriptor (e.g., `tenant="acme",path="/api"`), values are quoted so they can't fake another entry
+func descriptorKey(descriptor []*pb.RateLimitEntry) string {
+	parts := make([]string, 0, len(descriptor))
+	for _, entry := range descriptor {
+		parts = append(parts, entry.Key+"="+strconv.Quote(entry.Value))
+	}
+	return strings.Join(parts, ",")
+}
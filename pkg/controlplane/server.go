@@ -4,11 +4,18 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	pb "github.com/SimonePesci/gomesh/api/proto"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+// A proxy that registered but opened no config stream within this time is forgotten
+const registrationTimeout = time.Minute
+
 // Server is the control plane server
 type Server struct {
 	pb.UnimplementedMeshControlServer
@@ -18,13 +25,85 @@ type Server struct {
 
 	mu sync.RWMutex
 	proxies map[string]*ProxyConnection
+
+	// Issues the workload certificates of the proxies (nil: no mTLS in the mesh)
+	ca *CertificateAuthority
+
+	// Checks that callers are the proxy they claim to be (nil: any caller is trusted)
+	auth *ProxyAuth
+
+	// Closed on Stop, releases all the open config streams
+	shutdown chan struct{}
+	stopOnce sync.Once
 }
 
 // Represents a connection to a proxy: info and stream
 type ProxyConnection struct {
 	ProxyInfo *pb.ProxyInfo
 
+	// Last health report sent by the proxy
+	Health []*pb.EndpointHealth
+
+	// Written with both s.mu and sendMu held, so either is enough to read it
 	stream pb.MeshControl_StreamConfigServer
+
+	// When the proxy last registered, entries without a stream expire after registrationTimeout
+	registeredAt time.Time
+
+	// Sends on a stream must not run concurrently, and versions must only go forward
+	sendMu sync.Mutex
+	sentVersion int64
+
+	// Workload certificate of the proxy, attached to every update (nil without a CA)
+	// A renewed certificate is sent even if the proxy already has the config version
+	certificate *pb.WorkloadCertificate
+	sentCertificate *pb.WorkloadCertificate
+}
+
+// Send a config update on the stream, unless the proxy already has this version (and certificate) or a newer one
+func (c *ProxyConnection) send(stream pb.MeshControl_StreamConfigServer, config *pb.ConfigUpdate) (bool, error) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	// A broadcast may still hold the stream a reconnect replaced, the versions tracked here are the new one's
+	if stream != c.stream {
+		return false, nil
+	}
+
+	renewed := c.certificate != c.sentCertificate
+	if config.Version < c.sentVersion || (config.Version == c.sentVersion && !renewed) {
+		return false, nil
+	}
+
+	if c.certificate != nil {
+		config = withWorkloadCertificate(config, c.certificate)
+	}
+
+	if err := stream.Send(config); err != nil {
+		return false, err
+	}
+	c.sentVersion = config.Version
+	c.sentCertificate = c.certificate
+	return true, nil
+}
+
+// Replace the workload certificate, it goes out with the next send
+func (c *ProxyConnection) setCertificate(certificate *pb.WorkloadCertificate) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.certificate = certificate
+}
+
+// Shallow copy of the update with the certificate of one proxy, the shared update is read by every stream
+func withWorkloadCertificate(config *pb.ConfigUpdate, certificate *pb.WorkloadCertificate) *pb.ConfigUpdate {
+	update := &pb.ConfigUpdate{}
+	config.ProtoReflect().Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		update.ProtoReflect().Set(field, value)
+		return true
+	})
+	update.WorkloadCertificate = certificate
+	return update
 }
 
 
@@ -34,10 +113,32 @@ func NewServer(logger *zap.Logger) *Server {
 		logger: logger,
 		configStore: NewConfigStore(),
 		proxies: make(map[string]*ProxyConnection),
+		shutdown: make(chan struct{}),
 	}
 }
 
+// Issue workload certificates to the proxies, so they can use mTLS between them
+// Must be called before serving
+func (s *Server) SetCertificateAuthority(ca *CertificateAuthority) {
+	s.ca = ca
+}
+
+// Require proxies to prove their ID (the auth interceptors must be installed on the gRPC server)
+// Must be called before serving
+func (s *Server) SetProxyAuth(auth *ProxyAuth) {
+	s.auth = auth
+}
+
+// Stop ends all config streams, so proxies notice and reconnect
+// Must be called before grpc GracefulStop, which otherwise waits for the streams forever
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.shutdown)
+	})
+}
+
 // Context is used to keep track of the context of the request (required by the grpc server)
+// A proxy ID can only be used by one proxy at a time: another one registering while it is connected is rejected
 func (s *Server) RegisterProxy(ctx context.Context, info *pb.ProxyInfo) (*pb.RegistrationResponse, error) {
 	s.logger.Info("proxy registering",
 		zap.String("proxy_id", info.ProxyId),
@@ -45,11 +146,38 @@ func (s *Server) RegisterProxy(ctx context.Context, info *pb.ProxyInfo) (*pb.Reg
 		zap.String("listen_addr", info.ListenAddr),
 	)
 
-	// Add the proxy to the map
+	if info.ProxyId == "" {
+		return nil, status.Error(codes.InvalidArgument, "proxy ID is empty")
+	}
+
+	if err := s.auth.authorize(ctx, info.ProxyId); err != nil {
+		s.logger.Warn("proxy registration rejected",
+			zap.String("proxy_id", info.ProxyId),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	// Add the proxy to the map, or update it (a reconnect of the same proxy keeps its stream until the new one opens)
+	now := time.Now()
 	s.mu.Lock()
-	s.proxies[info.ProxyId] = &ProxyConnection{
-		ProxyInfo: info,
+	s.forgetStaleRegistrations(now)
+	conn, ok := s.proxies[info.ProxyId]
+	if ok && conn.stream != nil && conn.ProxyInfo.InstanceId != info.InstanceId {
+		s.mu.Unlock()
+
+		s.logger.Warn("proxy registration rejected: ID already in use",
+			zap.String("proxy_id", info.ProxyId),
+			zap.String("connected_addr", conn.ProxyInfo.ListenAddr),
+		)
+		return nil, status.Errorf(codes.AlreadyExists, "proxy %s is already connected", info.ProxyId)
+	}
+	if !ok {
+		conn = &ProxyConnection{}
+		s.proxies[info.ProxyId] = conn
 	}
+	conn.ProxyInfo = info
+	conn.registeredAt = now
 	s.mu.Unlock()
 
 	return &pb.RegistrationResponse{
@@ -58,6 +186,20 @@ func (s *Server) RegisterProxy(ctx context.Context, info *pb.ProxyInfo) (*pb.Reg
 	}, nil
 }
 
+// Drop the proxies that registered but never opened their config stream
+// Must be called with s.mu held
+func (s *Server) forgetStaleRegistrations(now time.Time) {
+	for proxyID, conn := range s.proxies {
+		if conn.stream == nil && now.Sub(conn.registeredAt) > registrationTimeout {
+			delete(s.proxies, proxyID)
+
+			s.logger.Info("forgot proxy that never opened its config stream",
+				zap.String("proxy_id", proxyID),
+			)
+		}
+	}
+}
+
 // StreamConfig is used to stream the config to the proxy
 // Long-lived stream: server sends multiple messages
 func (s *Server) StreamConfig(info *pb.ProxyInfo, stream pb.MeshControl_StreamConfigServer) error {
@@ -66,18 +208,33 @@ func (s *Server) StreamConfig(info *pb.ProxyInfo, stream pb.MeshControl_StreamCo
 		zap.String("version", info.Version),
 	)
 
+	if err := s.auth.authorize(stream.Context(), info.ProxyId); err != nil {
+		return err
+	}
+
 	// Store the stream to send updates later
+	// Keep the existing entry, a health report may already be attached to it
 	s.mu.Lock()
-	s.proxies[info.ProxyId] = &ProxyConnection{
-		ProxyInfo: info,
-		stream: stream,
+	conn, ok := s.proxies[info.ProxyId]
+	if !ok || conn.ProxyInfo.InstanceId != info.InstanceId {
+		s.mu.Unlock()
+		return status.Errorf(codes.NotFound, "proxy %s is not registered", info.ProxyId)
 	}
+	conn.ProxyInfo = info
+	conn.sendMu.Lock()
+	conn.stream = stream
+	conn.sentVersion = 0
+	conn.certificate = nil
+	conn.sentCertificate = nil
+	conn.sendMu.Unlock()
 	s.mu.Unlock()
 
-	// Remove proxy when connection closes
+	// Remove proxy when connection closes, unless a reconnect already replaced this stream
 	defer func() {
 		s.mu.Lock()
-		delete(s.proxies, info.ProxyId)
+		if s.proxies[info.ProxyId] == conn && conn.stream == stream {
+			delete(s.proxies, info.ProxyId)
+		}
 		s.mu.Unlock()
 
 		s.logger.Info("proxy disconnected",
@@ -85,6 +242,20 @@ func (s *Server) StreamConfig(info *pb.ProxyInfo, stream pb.MeshControl_StreamCo
 		)
 	}()
 
+	// The certificate goes out with the initial config, and again every time it is renewed
+	var renewTimer *time.Timer
+	var renew <-chan time.Time
+	if s.ca != nil {
+		renewAt, err := s.issueCertificate(conn, info)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to issue workload certificate: %v", err)
+		}
+
+		renewTimer = time.NewTimer(time.Until(renewAt))
+		defer renewTimer.Stop()
+		renew = renewTimer.C
+	}
+
 	// Send the initial config
 	config := s.configStore.GetConfig()
 	s.logger.Info("sending initial config to proxy",
@@ -93,7 +264,7 @@ func (s *Server) StreamConfig(info *pb.ProxyInfo, stream pb.MeshControl_StreamCo
 		zap.Int("num_routes", len(config.Routes)),
 	)
 
-	if err := stream.Send(config); err != nil {
+	if _, err := conn.send(stream, config); err != nil {
 		s.logger.Error("failed to send initial config to proxy",
 			zap.String("proxy_id", info.ProxyId),
 			zap.Error(err),
@@ -101,52 +272,145 @@ func (s *Server) StreamConfig(info *pb.ProxyInfo, stream pb.MeshControl_StreamCo
 		return err
 	}
 
-	// We keep the connection alive
-	// TODO: add the logic to handle config updates
-	<- stream.Context().Done()
+	// We keep the connection alive until the proxy leaves or the control plane stops
+	// Later updates are sent by BroadcastConfigUpdate
+	for {
+		select {
+		case <- stream.Context().Done():
+			return nil
+		case <- s.shutdown:
+			return nil
+
+		case <- renew:
+			renewAt, err := s.issueCertificate(conn, info)
+			if err != nil {
+				// The current certificate is still valid for a while, try again soon
+				s.logger.Error("failed to renew workload certificate",
+					zap.String("proxy_id", info.ProxyId),
+					zap.Error(err),
+				)
+				renewAt = time.Now().Add(time.Minute)
+			} else if _, err := conn.send(stream, s.configStore.GetConfig()); err != nil {
+				return err
+			}
+			renewTimer.Reset(time.Until(renewAt))
+		}
+	}
+}
+
+// Issue a new workload certificate for the proxy, returns when it must be renewed
+func (s *Server) issueCertificate(conn *ProxyConnection, info *pb.ProxyInfo) (time.Time, error) {
+	certificate, renewAt, err := s.ca.Issue(info)
+	if err != nil {
+		return time.Time{}, err
+	}
+	conn.setCertificate(certificate)
+
+	identity, _ := s.ca.Identity(info)
+	s.logger.Info("issued workload certificate",
+		zap.String("proxy_id", info.ProxyId),
+		zap.Stringer("identity", identity),
+		zap.Time("renew_at", renewAt),
+	)
+	return renewAt, nil
+}
+
+// ReportHealth stores the endpoint health seen by a proxy
+// Each report is complete, so it replaces the previous one
+func (s *Server) ReportHealth(ctx context.Context, report *pb.HealthReport) (*pb.HealthReportResponse, error) {
+
+	if err := s.auth.authorize(ctx, report.ProxyId); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	conn, ok := s.proxies[report.ProxyId]
+	if ok {
+		conn.Health = report.Endpoints
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "proxy %s is not registered", report.ProxyId)
+	}
+
+	unhealthy := 0
+	for _, endpoint := range report.Endpoints {
+		if !endpoint.Healthy {
+			unhealthy++
+			s.logger.Warn("proxy reports unhealthy endpoint",
+				zap.String("proxy_id", report.ProxyId),
+				zap.String("cluster", endpoint.Cluster),
+				zap.String("endpoint", endpoint.Endpoint),
+			)
+		}
+	}
 
-	return nil
+	s.logger.Info("health report received",
+		zap.String("proxy_id", report.ProxyId),
+		zap.Int("endpoints", len(report.Endpoints)),
+		zap.Int("unhealthy", unhealthy),
+	)
+
+	return &pb.HealthReportResponse{}, nil
 }
 
 // Broadcast update to all proxies
 // Should be triggered by an admin when changing the configuration
 func (s *Server) BroadcastConfigUpdate(config *pb.ConfigUpdate) {
-	// No write lock, we just read the proxies map
+	// Only the list of streams is taken under the lock: a slow proxy must not hold up the others,
+	// nor the proxies registering meanwhile
+	type target struct {
+		proxyID string
+		conn *ProxyConnection
+		stream pb.MeshControl_StreamConfigServer
+	}
+
 	s.mu.RLock()
-	defer s.mu.RUnlock()
+	targets := make([]target, 0, len(s.proxies))
+	for proxyID, conn := range s.proxies {
+		if conn.stream != nil {
+			targets = append(targets, target{proxyID: proxyID, conn: conn, stream: conn.stream})
+		}
+	}
+	s.mu.RUnlock()
 
 	s.logger.Info("broadcasting config update to proxies",
 		zap.Int64("version", config.Version),
-		zap.Int("proxy_count", len(s.proxies)),
+		zap.Int("proxy_count", len(targets)),
 	)
 
 	// For each proxy, send the config update
-	for proxyID, conn := range s.proxies {
-		if conn.stream != nil {
-			if err := conn.stream.Send(config); err != nil {
-				s.logger.Error("failed to send config update to proxy",
-					zap.String("proxy_id", proxyID),
-					zap.Error(err),
-				)
-			} else {
-				s.logger.Info("sent config update to proxy",
-					zap.String("proxy_id", proxyID),
-					zap.Int64("version", config.Version),
-				)
-			}
+	for _, t := range targets {
+		if sent, err := t.conn.send(t.stream, config); err != nil {
+			s.logger.Error("failed to send config update to proxy",
+				zap.String("proxy_id", t.proxyID),
+				zap.Error(err),
+			)
+		} else if sent {
+			s.logger.Info("sent config update to proxy",
+				zap.String("proxy_id", t.proxyID),
+				zap.Int64("version", config.Version),
+			)
 		}
 	}
+}
 
+// ConfigStore returns the config served to the proxies
+func (s *Server) ConfigStore() *ConfigStore {
+	return s.configStore
 }
 
-// GetConnectedProxies returns a list of all connected proxies
+// GetConnectedProxies returns a list of all connected proxies (the ones with an open config stream)
 func (s *Server) GetConnectedProxies() []*pb.ProxyInfo {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	proxies := make([]*pb.ProxyInfo, 0, len(s.proxies))
 	for _, conn := range s.proxies {
-		proxies = append(proxies, conn.ProxyInfo)
+		if conn.stream != nil {
+			proxies = append(proxies, conn.ProxyInfo)
+		}
 	}
 
 	return proxies
@@ -0,0 +1,76 @@
+package controlplane
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"go.uber.org/zap"
+)
+
+// ShiftTraffic moves the traffic split of the routes on path to the target weights in steps,
+// pushing each step to the proxies and waiting interval between them (e.g., 5% -> 50% of a canary in 10 steps)
+// Stops where it is when ctx is done. The weights of the first route with a split on path are the starting point
+func (s *Server) ShiftTraffic(ctx context.Context, path string, target map[string]int32, steps int, interval time.Duration) error {
+	if steps < 1 {
+		steps = 1
+	}
+
+	start := splitWeights(s.configStore.GetConfig(), path)
+	if start == nil {
+		return fmt.Errorf("no route on path %q has a traffic split", path)
+	}
+
+	for step := 1; step <= steps; step++ {
+
+		// Linear interpolation, the last step lands exactly on the target
+		weights := make(map[string]int32, len(target))
+		for cluster, to := range target {
+			from := start[cluster]
+			weights[cluster] = from + int32(int64(to-from)*int64(step)/int64(steps))
+		}
+
+		config, err := s.configStore.SetSplitWeights(path, weights)
+		if err != nil {
+			return err
+		}
+
+		s.logger.Info("traffic split shifted",
+			zap.String("path", path),
+			zap.Int("step", step),
+			zap.Int("steps", steps),
+			zap.Any("weights", weights),
+			zap.Int64("version", config.Version),
+		)
+		s.BroadcastConfigUpdate(config)
+
+		if step == steps {
+			break
+		}
+
+		select {
+		case <- time.After(interval):
+		case <- ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Weights of the first traffic split on path, by cluster (nil if there is none)
+func splitWeights(config *pb.ConfigUpdate, path string) map[string]int32 {
+	for _, route := range config.Routes {
+		if route.Path != path || route.TrafficSplit == nil {
+			continue
+		}
+
+		weights := make(map[string]int32, len(route.TrafficSplit.Clusters))
+		for _, weighted := range route.TrafficSplit.Clusters {
+			weights[weighted.Cluster] = weighted.Weight
+		}
+		return weights
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+// Default Content-Type of direct responses
+const defaultDirectResponseContentType = "text/plain; charset=utf-8"
+
+// Whether the route answers at the proxy instead of forwarding
+func localAction(routeConfig *pb.Route) bool {
+	return routeConfig.Redirect != nil || routeConfig.DirectResponse != nil
+}
+
+// Check the redirect or direct response of a route
+func validateLocalAction(routeConfig *pb.Route) error {
+	if routeConfig.Redirect != nil && routeConfig.DirectResponse != nil {
+		return fmt.Errorf("redirect and direct_response can't be combined")
+	}
+
+	// Nothing is forwarded, so these could never apply
+	if routeConfig.TrafficSplit != nil || routeConfig.Mirror != nil || routeConfig.RetryPolicy != nil {
+		return fmt.Errorf("traffic_split, mirror and retry_policy need a backend, not a redirect or direct_response")
+	}
+
+	if redirect := routeConfig.Redirect; redirect != nil {
+		switch redirect.Status {
+		case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return fmt.Errorf("redirect status %d must be 301, 302, 303, 307 or 308", redirect.Status)
+		}
+
+		if redirect.Scheme != "" && redirect.Scheme != "http" && redirect.Scheme != "https" {
+			return fmt.Errorf("redirect scheme %q must be http or https", redirect.Scheme)
+		}
+
+		if redirect.Port < 0 || redirect.Port > 65535 {
+			return fmt.Errorf("redirect port %d is out of range", redirect.Port)
+		}
+
+		if redirect.Path != "" && redirect.PrefixRewrite != "" {
+			return fmt.Errorf("redirect path and prefix_rewrite can't be combined")
+		}
+
+		for _, path := range []string{redirect.Path, redirect.PrefixRewrite} {
+			if path != "" && !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("redirect path %q must start with /", path)
+			}
+		}
+	}
+
+	if response := routeConfig.DirectResponse; response != nil {
+		if response.Status < 200 || response.Status > 599 {
+			return fmt.Errorf("direct_response status %d must be between 200 and 599", response.Status)
+		}
+	}
+
+	return nil
+}
+
+// Answer the request with the route redirect or direct response
+func (h *Handler) respondLocally(w http.ResponseWriter, r *http.Request, rt *route) {
+
+	applyHeaderPolicies(rt.responseHeaders, w.Header(), newHeaderVars(r, rt, nil))
+
+	if redirect := rt.config.Redirect; redirect != nil {
+		status := int(redirect.Status)
+		if status == 0 {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, redirectURL(r, rt.config, redirect), status)
+		return
+	}
+
+	response := rt.config.DirectResponse
+
+	contentType := response.ContentType
+	if contentType == "" {
+		contentType = defaultDirectResponseContentType
+	}
+	if response.Body != "" {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(response.Body)))
+	}
+
+	w.WriteHeader(int(response.Status))
+	if r.Method != http.MethodHead {
+		w.Write([]byte(response.Body))
+	}
+}
+
+// Scheme the client used: behind a trusted proxy the one it forwarded, otherwise the one of the listener
+// Without this, a TLS-terminating load balancer would make every request look like http
+func requestScheme(r *http.Request) string {
+	if client := clientFromContext(r.Context()); client != nil && client.trustedPeer {
+		// The first proxy of a chain puts the scheme the client used first
+		proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		if proto = strings.ToLower(strings.TrimSpace(proto)); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Where the redirect sends the client: the request URL with the parts the redirect sets replaced
+func redirectURL(r *http.Request, routeConfig *pb.Route, redirect *pb.Redirect) string {
+
+	scheme := requestScheme(r)
+
+	hostname, port := r.Host, ""
+	if h, p, err := net.SplitHostPort(r.Host); err == nil {
+		hostname, port = h, p
+	}
+
+	// The old port means nothing on another scheme
+	if redirect.Scheme != "" && redirect.Scheme != scheme {
+		port = ""
+	}
+	if redirect.Scheme != "" {
+		scheme = redirect.Scheme
+	}
+	if redirect.Host != "" {
+		hostname = redirect.Host
+	}
+	if redirect.Port != 0 {
+		port = strconv.Itoa(int(redirect.Port))
+	}
+
+	// JoinHostPort brackets IPv6 addresses
+	host := net.JoinHostPort(strings.Trim(hostname, "[]"), port)
+	if port == "" {
+		host = strings.TrimSuffix(host, ":")
+	}
+
+	path := r.URL.EscapedPath()
+	if redirect.Path != "" {
+		path = redirect.Path
+	} else if redirect.PrefixRewrite != "" {
+		rewrite := &urlRewrite{matched: routeConfig.Path, prefix: redirect.PrefixRewrite, prefixSet: true}
+		path = (&url.URL{Path: rewrite.rewritePath(r.URL.Path)}).EscapedPath()
+	}
+
+	location := scheme + "://" + host + path
+	if r.URL.RawQuery != "" && !redirect.StripQuery {
+		location += "?" + r.URL.RawQuery
+	}
+	return location
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// Headers used to pass the verified identity to the backend
+// They are always removed from incoming requests, so clients can't forge them
+const (
+	SubjectHeader = "X-Auth-Subject"
+	ScopesHeader = "X-Auth-Scopes"
+)
+
+// Header clients use to send a static API key
+const APIKeyHeader = "X-API-Key"
+
+// Returned by an Authenticator when the request has no credentials of its kind
+var errNoCredentials = errors.New("no credentials")
+
+// Identity is who made the request, as verified by an Authenticator
+type Identity struct {
+	Subject string
+	Scopes []string
+}
+
+// Authenticator verifies one kind of credential (JWT, API key...)
+type Authenticator interface {
+	// Authenticate returns errNoCredentials if the request doesn't carry this kind of credential,
+	// any other error means the credential is there but invalid
+	Authenticate(r *http.Request) (*Identity, error)
+
+	// Scheme used in the WWW-Authenticate challenge (e.g., "Bearer")
+	Scheme() string
+}
+
+// APIKeyAuthenticator maps static keys to identities
+type APIKeyAuthenticator struct {
+	// Indexed by the SHA-256 of the key, so the secret itself is never kept in memory as a map key
+	keys map[[sha256.Size]byte]*Identity
+}
+
+// Build an API key authenticator from the configured keys
+func NewAPIKeyAuthenticator(apiKeys []*pb.ApiKey) (*APIKeyAuthenticator, error) {
+
+	authenticator := &APIKeyAuthenticator{
+		keys: make(map[[sha256.Size]byte]*Identity, len(apiKeys)),
+	}
+
+	for _, apiKey := range apiKeys {
+		if apiKey.Key == "" {
+			return nil, fmt.Errorf("API key for subject %q is empty", apiKey.Subject)
+		}
+
+		digest := sha256.Sum256([]byte(apiKey.Key))
+		if _, exists := authenticator.keys[digest]; exists {
+			return nil, fmt.Errorf("duplicate API key for subject %q", apiKey.Subject)
+		}
+
+		authenticator.keys[digest] = &Identity{
+			Subject: apiKey.Subject,
+			Scopes: apiKey.Scopes,
+		}
+	}
+
+	return authenticator, nil
+}
+
+// Scheme used in the WWW-Authenticate challenge
+func (a *APIKeyAuthenticator) Scheme() string {
+	return "ApiKey"
+}
+
+// Authenticate looks up the key sent in the X-API-Key header
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, errNoCredentials
+	}
+
+	identity, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, errors.New("unknown API key")
+	}
+
+	// The backend gets the identity, not the secret
+	r.Header.Del(APIKeyHeader)
+
+	return identity, nil
+}
+
+// Build the authenticators described by an AuthConfig (JWT first, then API keys)
+func newAuthenticators(auth *pb.AuthConfig) ([]Authenticator, error) {
+
+	var authenticators []Authenticator
+	if auth == nil {
+		return authenticators, nil
+	}
+
+	if auth.Jwks != "" {
+		jwtAuthenticator, err := NewJWTAuthenticator([]byte(auth.Jwks), auth.Issuer, auth.Audience)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+
+	if len(auth.ApiKeys) > 0 {
+		apiKeyAuthenticator, err := NewAPIKeyAuthenticator(auth.ApiKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, apiKeyAuthenticator)
+	}
+
+	return authenticators, nil
+}
+
+// Turn the auth section of the YAML file into an AuthConfig, reading the JWKS file
+// Used until (and unless) the control plane sends its own AuthConfig
+func loadLocalAuth(config *AuthConfig) (*pb.AuthConfig, error) {
+
+	auth := &pb.AuthConfig{
+		Issuer: config.Issuer,
+		Audience: config.Audience,
+	}
+
+	if config.JWKSFile != "" {
+		jwks, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read JWKS file: %w", err)
+		}
+		auth.Jwks = string(jwks)
+	}
+
+	for _, apiKey := range config.APIKeys {
+		auth.ApiKeys = append(auth.ApiKeys, &pb.ApiKey{
+			Key: apiKey.Key,
+			Subject: apiKey.Subject,
+			Scopes: apiKey.Scopes,
+		})
+	}
+
+	return auth, nil
+}
+
+// Middleware to authenticate requests on routes flagged with auth_required
+// Verified claims are forwarded to the backend in the X-Auth-* headers
+func AuthMiddleware(logger *logging.Logger, metrics *Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// Identity headers only come from us
+		r.Header.Del(SubjectHeader)
+		r.Header.Del(ScopesHeader)
+
+		rt := routeFromContext(r.Context())
+		if rt == nil || !rt.config.AuthRequired {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// First authenticator that finds its credential decides
+		var failed Authenticator
+		var authErr error = errNoCredentials
+		for _, authenticator := range rt.authenticators {
+			identity, err := authenticator.Authenticate(r)
+			if errors.Is(err, errNoCredentials) {
+				continue
+			}
+			if err != nil {
+				failed, authErr = authenticator, err
+				break
+			}
+
+			r.Header.Set(SubjectHeader, identity.Subject)
+			if len(identity.Scopes) > 0 {
+				r.Header.Set(ScopesHeader, strings.Join(identity.Scopes, " "))
+			}
+
+			next.ServeHTTP(w, r.WithContext(withIdentity(r.Context(), identity)))
+			return
+		}
+
+		logger.Warn("request rejected: authentication failed",
+			zap.String("path", r.URL.Path),
+			zap.String("reason", authErr.Error()),
+			zap.String("trace_id", tracing.GetTraceID(r)),
+		)
+		metrics.RecordError(rt.service(), "unauthorized")
+
+		// One challenge per accepted scheme, flagging the one that failed
+		for _, authenticator := range rt.authenticators {
+			challenge := fmt.Sprintf(`%s realm="gomesh"`, authenticator.Scheme())
+			if authenticator == failed {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Add("WWW-Authenticate", challenge)
+		}
+
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+func TestNewAPIKeyAuthenticator(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []*pb.ApiKey
+		wantErr bool
+	}{
+		{name: "valid", keys: []*pb.ApiKey{{Key: "k1", Subject: "a"}, {Key: "k2", Subject: "b"}}},
+		{name: "empty key", keys: []*pb.ApiKey{{Key: "", Subject: "a"}}, wantErr: true},
+		{name: "duplicate key", keys: []*pb.ApiKey{{Key: "k1", Subject: "a"}, {Key: "k1", Subject: "b"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAPIKeyAuthenticator(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAPIKeyAuthenticator: error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticatorKeepsOnlyDigests(t *testing.T) {
+	authenticator, err := NewAPIKeyAuthenticator([]*pb.ApiKey{{Key: "super-secret", Subject: "ci"}})
+	if err != nil {
+		t.Fatalf("NewAPIKeyAuthenticator: %v", err)
+	}
+
+	for digest := range authenticator.keys {
+		if bytes.Contains(digest[:], []byte("super-secret")) {
+			t.Fatal("the API key is kept in clear")
+		}
+	}
+}
+
+func TestAPIKeyAuthenticatorAuthenticate(t *testing.T) {
+	authenticator, err := NewAPIKeyAuthenticator([]*pb.ApiKey{{Key: "k1", Subject: "ci", Scopes: []string{"deploy"}}})
+	if err != nil {
+		t.Fatalf("NewAPIKeyAuthenticator: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key string
+		wantErr bool
+		wantNoCredentials bool
+	}{
+		{name: "known key", key: "k1"},
+		{name: "unknown key", key: "k2", wantErr: true},
+		{name: "no key", wantErr: true, wantNoCredentials: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.key != "" {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+
+			identity, err := authenticator.Authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate: error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantNoCredentials && err != errNoCredentials {
+				t.Fatalf("Authenticate: error = %v, want errNoCredentials", err)
+			}
+			if err != nil {
+				return
+			}
+
+			if identity.Subject != "ci" || !reflect.DeepEqual(identity.Scopes, []string{"deploy"}) {
+				t.Errorf("identity = %+v", identity)
+			}
+			if r.Header.Get(APIKeyHeader) != "" {
+				t.Error("the API key was forwarded to the backend")
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	keys := newJWTTestKeys(t)
+
+	jwtAuthenticator, err := NewJWTAuthenticator(keys.jwks, "", "")
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+	apiKeyAuthenticator, err := NewAPIKeyAuthenticator([]*pb.ApiKey{{Key: "k1", Subject: "ci"}})
+	if err != nil {
+		t.Fatalf("NewAPIKeyAuthenticator: %v", err)
+	}
+
+	protected := &route{
+		config: &pb.Route{Path: "/private", AuthRequired: true},
+		authenticators: []Authenticator{jwtAuthenticator, apiKeyAuthenticator},
+	}
+	public := &route{config: &pb.Route{Path: "/public"}}
+
+	token := signTestToken(t, map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "scope": "read write"}, testHMACSecret, nil)
+
+	tests := []struct {
+		name string
+		rt *route
+		header map[string]string
+		wantStatus int
+		wantSubject string
+		wantScopes string
+		wantChallenges []string
+	}{
+		{
+			name: "spoofed identity on a public route",
+			rt: public,
+			header: map[string]string{SubjectHeader: "admin", ScopesHeader: "all"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "spoofed identity next to a valid token",
+			rt: protected,
+			header: map[string]string{"Authorization": "Bearer " + token, SubjectHeader: "admin", ScopesHeader: "all"},
+			wantStatus: http.StatusOK,
+			wantSubject: "alice",
+			wantScopes: "read write",
+		},
+		{
+			name: "spoofed identity without credentials",
+			rt: protected,
+			header: map[string]string{SubjectHeader: "admin"},
+			wantStatus: http.StatusUnauthorized,
+			wantChallenges: []string{`Bearer realm="gomesh"`, `ApiKey realm="gomesh"`},
+		},
+		{
+			name: "API key",
+			rt: protected,
+			header: map[string]string{APIKeyHeader: "k1"},
+			wantStatus: http.StatusOK,
+			wantSubject: "ci",
+		},
+		{
+			name: "invalid token",
+			rt: protected,
+			header: map[string]string{"Authorization": "Bearer " + token + "x"},
+			wantStatus: http.StatusUnauthorized,
+			wantChallenges: []string{`Bearer realm="gomesh", error="invalid_token"`, `ApiKey realm="gomesh"`},
+		},
+		{
+			name: "unknown API key",
+			rt: protected,
+			header: map[string]string{APIKeyHeader: "k2"},
+			wantStatus: http.StatusUnauthorized,
+			wantChallenges: []string{`Bearer realm="gomesh"`, `ApiKey realm="gomesh", error="invalid_token"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upstream http.Header
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstream = r.Header.Clone()
+			})
+
+			r := httptest.NewRequest("GET", tt.rt.config.Path, nil)
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+			r = r.WithContext(withRoute(r.Context(), tt.rt))
+
+			w := httptest.NewRecorder()
+			AuthMiddleware(newTestLogger(t), testMetrics, next).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Values("WWW-Authenticate"); !reflect.DeepEqual(got, tt.wantChallenges) {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.wantChallenges)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if got := upstream.Get(SubjectHeader); got != tt.wantSubject {
+				t.Errorf("%s = %q, want %q", SubjectHeader, got, tt.wantSubject)
+			}
+			if got := upstream.Get(ScopesHeader); got != tt.wantScopes {
+				t.Errorf("%s = %q, want %q", ScopesHeader, got, tt.wantScopes)
+			}
+		})
+	}
+}
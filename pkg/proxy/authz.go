@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// authorizer decides which requests may reach the routes, from the authorization policies of the control plane
+type authorizer struct {
+	deny []*authzPolicy
+	allow []*authzPolicy
+}
+
+type authzPolicy struct {
+	name string
+	rules []*authzRule
+}
+
+// A rule with every condition compiled, empty ones match every request
+type authzRule struct {
+	principals []string
+	notPrincipals []string
+	paths []string
+	methods map[string]bool
+	headers []*valueMatcher
+}
+
+// Build the authorizer (nil when there are no policies: everything is allowed)
+func newAuthorizer(configs []*pb.AuthorizationPolicy) (*authorizer, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	a := &authorizer{}
+	for i, config := range configs {
+		name := config.Name
+		if name == "" {
+			name = fmt.Sprintf("policy-%d", i)
+		}
+
+		policy := &authzPolicy{name: name}
+		for j, ruleConfig := range config.Rules {
+			rule, err := newAuthzRule(ruleConfig)
+			if err != nil {
+				return nil, fmt.Errorf("policy %q rule %d: %w", name, j, err)
+			}
+			policy.rules = append(policy.rules, rule)
+		}
+
+		switch config.Action {
+		case pb.AuthorizationAction_AUTHORIZATION_ACTION_ALLOW:
+			a.allow = append(a.allow, policy)
+		case pb.AuthorizationAction_AUTHORIZATION_ACTION_DENY:
+			a.deny = append(a.deny, policy)
+		default:
+			return nil, fmt.Errorf("policy %q has an unknown action %v", name, config.Action)
+		}
+	}
+
+	return a, nil
+}
+
+func newAuthzRule(config *pb.AuthorizationRule) (*authzRule, error) {
+	rule := &authzRule{}
+
+	for _, list := range []struct {
+		field string
+		patterns []string
+		target *[]string
+	}{
+		{"principals", config.Principals, &rule.principals},
+		{"not_principals", config.NotPrincipals, &rule.notPrincipals},
+		{"paths", config.Paths, &rule.paths},
+	} {
+		for _, pattern := range list.patterns {
+			if pattern == "" || strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				return nil, fmt.Errorf("invalid %s pattern %q (\"*\" only at the end)", list.field, pattern)
+			}
+			*list.target = append(*list.target, pattern)
+		}
+	}
+
+	if len(config.Methods) > 0 {
+		rule.methods = make(map[string]bool, len(config.Methods))
+		for _, method := range config.Methods {
+			if method == "" {
+				return nil, fmt.Errorf("empty method")
+			}
+			rule.methods[strings.ToUpper(method)] = true
+		}
+	}
+
+	for _, headerConfig := range config.Headers {
+		header, err := newValueMatcher(headerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("header %q: %w", headerConfig.Name, err)
+		}
+		rule.headers = append(rule.headers, header)
+	}
+
+	return rule, nil
+}
+
+// Check the request of a client with the given identity (empty without mTLS)
+// Returns the policy that decided, "" when there is none (or no ALLOW policy matched)
+func (a *authorizer) authorize(r *http.Request, identity string) (bool, string) {
+	for _, policy := range a.deny {
+		if policy.matches(r, identity) {
+			return false, policy.name
+		}
+	}
+
+	if len(a.allow) == 0 {
+		return true, ""
+	}
+
+	for _, policy := range a.allow {
+		if policy.matches(r, identity) {
+			return true, policy.name
+		}
+	}
+	return false, ""
+}
+
+func (p *authzPolicy) matches(r *http.Request, identity string) bool {
+	for _, rule := range p.rules {
+		if rule.matches(r, identity) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *authzRule) matches(r *http.Request, identity string) bool {
+	if len(rule.principals) > 0 && !matchesAnyPattern(rule.principals, identity) {
+		return false
+	}
+
+	if matchesAnyPattern(rule.notPrincipals, identity) {
+		return false
+	}
+
+	if len(rule.paths) > 0 && !matchesAnyPattern(rule.paths, r.URL.Path) {
+		return false
+	}
+
+	if rule.methods != nil && !rule.methods[r.Method] {
+		return false
+	}
+
+	for _, header := range rule.headers {
+		if !header.matches(r.Header.Values(header.config.Name)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// "*" matches any non-empty value, a trailing "*" a prefix, anything else the exact value
+func matchesAnyPattern(patterns []string, value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(value, prefix) {
+				return true
+			}
+		} else if value == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Identity of the mesh proxy that sent the request: the SPIFFE ID of its certificate
+// Empty for clients that didn't come through the mesh mTLS listener.
+// The listener only lets in certificates of the mesh CA with a SPIFFE ID of our trust domain, and proxies
+// only send to mesh clusters presenting the identity the cluster expects, so a principal can't be borrowed
+// by another workload of the mesh
+func peerIdentity(r *http.Request) string {
+	if r.TLS == nil || r.TLS.ServerName != meshServerName || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return spiffeID(r.TLS.PeerCertificates[0])
+}
+
+// Check the authorization policies before anything else runs for the request
+func authzMiddleware(logger *logging.Logger, metrics *Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rt := routeFromContext(r.Context())
+		if rt == nil || rt.authz == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity := peerIdentity(r)
+		if allowed, policy := rt.authz.authorize(r, identity); !allowed {
+			if policy == "" {
+				policy = "no matching allow policy"
+			}
+
+			logger.Warn("request denied by authorization policy",
+				zap.String("policy", policy),
+				zap.String("identity", identity),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("trace_id", tracing.GetTraceID(r)),
+			)
+			metrics.RecordError(rt.service(), "authz_denied")
+
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
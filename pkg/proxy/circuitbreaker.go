@@ -0,0 +1,365 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Defaults for the fields left empty in a CircuitBreaker
+const (
+	defaultBreakerWindow = 10 * time.Second
+	defaultBreakerMinRequests = 20
+	defaultBreakerOpenDuration = 30 * time.Second
+	defaultBreakerHalfOpenRequests = 1
+)
+
+// Number of buckets in the rolling window, each one covers window/breakerBuckets
+const breakerBuckets = 10
+
+// Returned when the breaker doesn't let a request through
+var (
+	errCircuitOpen = errors.New("circuit breaker is open")
+	errCircuitOverflow = errors.New("too many concurrent requests")
+)
+
+// What a request tells the breaker about the cluster once it is done
+type breakerOutcome int
+
+const (
+	// Nothing: the client went away, or the proxy answered without the cluster (e.g., no endpoint)
+	outcomeUnknown breakerOutcome = iota
+	outcomeSuccess
+	outcomeUpstreamFailure
+)
+
+// State of a circuit breaker
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker guards a cluster
+// closed: everything goes through, errors are counted
+// open: everything is rejected until open_duration has passed
+// half-open: a few trial requests go through, they decide between closed and open
+type circuitBreaker struct {
+	cluster string
+	consecutiveErrors int
+	errorRatio float64
+	minRequests int
+	openDuration time.Duration
+	halfOpenRequests int
+
+	// Concurrency limit: one slot per request in flight (nil: unlimited)
+	slots chan struct{}
+	maxPending int64
+	pending atomic.Int64
+
+	mu sync.Mutex
+	state breakerState
+	openedAt time.Time
+	consecutive int
+	window rollingWindow
+	halfOpenInFlight int
+	halfOpenSuccesses int
+
+	// Clock of the window and the open duration (time.Now, except in tests)
+	now func() time.Time
+
+	// Set when the cluster starts serving
+	logger *logging.Logger
+	metrics *Metrics
+}
+
+// Build a circuit breaker (nil when the config doesn't enable anything)
+func newCircuitBreaker(cluster string, config *pb.CircuitBreaker) (*circuitBreaker, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	if config.ConsecutiveErrors < 0 || config.WindowMs < 0 || config.MinRequests < 0 || config.OpenDurationMs < 0 ||
+		config.HalfOpenRequests < 0 || config.MaxRequests < 0 || config.MaxPendingRequests < 0 {
+		return nil, fmt.Errorf("thresholds, durations and limits must not be negative")
+	}
+
+	if config.ErrorRatio < 0 || config.ErrorRatio > 1 {
+		return nil, fmt.Errorf("error_ratio %v must be between 0 and 1", config.ErrorRatio)
+	}
+
+	if config.MaxPendingRequests > 0 && config.MaxRequests == 0 {
+		return nil, fmt.Errorf("max_pending_requests needs max_requests")
+	}
+
+	if config.ConsecutiveErrors == 0 && config.ErrorRatio == 0 && config.MaxRequests == 0 {
+		return nil, nil
+	}
+
+	breaker := &circuitBreaker{
+		cluster: cluster,
+		consecutiveErrors: int(config.ConsecutiveErrors),
+		errorRatio: config.ErrorRatio,
+		minRequests: intOrDefault(config.MinRequests, defaultBreakerMinRequests),
+		openDuration: durationOrDefault(config.OpenDurationMs, defaultBreakerOpenDuration),
+		halfOpenRequests: intOrDefault(config.HalfOpenRequests, defaultBreakerHalfOpenRequests),
+		maxPending: int64(config.MaxPendingRequests),
+		now: time.Now,
+		window: rollingWindow{
+			bucketSize: durationOrDefault(config.WindowMs, defaultBreakerWindow) / breakerBuckets,
+		},
+	}
+
+	if config.MaxRequests > 0 {
+		breaker.slots = make(chan struct{}, config.MaxRequests)
+	}
+
+	return breaker, nil
+}
+
+// Ask to send a request to the cluster
+// On success the returned function must be called once the request is done, telling how the cluster answered
+func (cb *circuitBreaker) acquire(ctx context.Context) (func(outcome breakerOutcome), error) {
+
+	trial, err := cb.admit()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cb.takeSlot(ctx); err != nil {
+		cb.cancelTrial(trial)
+		return nil, err
+	}
+
+	return func(outcome breakerOutcome) {
+		if cb.slots != nil {
+			<- cb.slots
+		}
+		if outcome == outcomeUnknown {
+			cb.cancelTrial(trial)
+			return
+		}
+		cb.record(trial, outcome == outcomeUpstreamFailure)
+	}, nil
+}
+
+// State right now, open breakers past their open duration still read as open
+func (cb *circuitBreaker) currentState() breakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// Check the state, returns whether the request is a half-open trial
+func (cb *circuitBreaker) admit() (bool, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == breakerOpen && cb.now().Sub(cb.openedAt) >= cb.openDuration {
+		cb.setState(breakerHalfOpen)
+	}
+
+	switch cb.state {
+	case breakerOpen:
+		return false, errCircuitOpen
+
+	case breakerHalfOpen:
+		if cb.halfOpenInFlight >= cb.halfOpenRequests {
+			return false, errCircuitOpen
+		}
+		cb.halfOpenInFlight++
+		return true, nil
+
+	default:
+		return false, nil
+	}
+}
+
+// Wait for a concurrency slot, if the cluster has a limit
+func (cb *circuitBreaker) takeSlot(ctx context.Context) error {
+	if cb.slots == nil {
+		return nil
+	}
+
+	// Fast path: a slot is free
+	select {
+	case cb.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	// All slots are taken, wait only if the pending queue has room
+	if cb.pending.Add(1) > cb.maxPending {
+		cb.pending.Add(-1)
+		return errCircuitOverflow
+	}
+	defer cb.pending.Add(-1)
+
+	select {
+	case cb.slots <- struct{}{}:
+		return nil
+	case <- ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// A trial request that says nothing about the cluster gives its place back
+func (cb *circuitBreaker) cancelTrial(trial bool) {
+	if !trial {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == breakerHalfOpen {
+		cb.halfOpenInFlight--
+	}
+}
+
+// Count the outcome of a request and move between states
+func (cb *circuitBreaker) record(trial bool, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case breakerHalfOpen:
+		// Requests admitted before the breaker opened don't count as trials
+		if !trial {
+			return
+		}
+		cb.halfOpenInFlight--
+
+		if failed {
+			cb.setState(breakerOpen)
+			return
+		}
+
+		cb.halfOpenSuccesses++
+		if cb.halfOpenSuccesses >= cb.halfOpenRequests {
+			cb.setState(breakerClosed)
+		}
+
+	case breakerClosed:
+		now := cb.now()
+		cb.window.add(now, failed)
+
+		if failed {
+			cb.consecutive++
+		} else {
+			cb.consecutive = 0
+		}
+
+		if cb.consecutiveErrors > 0 && cb.consecutive >= cb.consecutiveErrors {
+			cb.setState(breakerOpen)
+			return
+		}
+
+		if cb.errorRatio > 0 {
+			requests, errors := cb.window.totals(now)
+			if requests >= cb.minRequests && float64(errors)/float64(requests) >= cb.errorRatio {
+				cb.setState(breakerOpen)
+			}
+		}
+	}
+}
+
+// Change state, resetting the counters of the new one (caller must hold the lock)
+func (cb *circuitBreaker) setState(state breakerState) {
+	from := cb.state
+	cb.state = state
+
+	switch state {
+	case breakerOpen:
+		cb.openedAt = cb.now()
+	case breakerHalfOpen:
+		cb.halfOpenInFlight = 0
+		cb.halfOpenSuccesses = 0
+	case breakerClosed:
+		cb.consecutive = 0
+		cb.window.reset()
+	}
+
+	if cb.metrics != nil {
+		cb.metrics.RecordCircuitBreakerTransition(cb.cluster, from.String(), state.String())
+	}
+
+	if cb.logger != nil {
+		log := cb.logger.Info
+		if state == breakerOpen {
+			log = cb.logger.Warn
+		}
+		log("circuit breaker state changed",
+			zap.String("cluster", cb.cluster),
+			zap.String("from", from.String()),
+			zap.String("to", state.String()),
+		)
+	}
+}
+
+// Requests and errors over the last window, split in buckets
+type rollingWindow struct {
+	bucketSize time.Duration
+	buckets [breakerBuckets]windowBucket
+}
+
+type windowBucket struct {
+	// Which slice of time the counts belong to (time / bucketSize)
+	epoch int64
+	requests int
+	errors int
+}
+
+func (w *rollingWindow) add(now time.Time, failed bool) {
+	epoch := now.UnixNano() / int64(w.bucketSize)
+	bucket := &w.buckets[epoch%breakerBuckets]
+
+	// The bucket still holds an older slice of time, start over
+	if bucket.epoch != epoch {
+		*bucket = windowBucket{epoch: epoch}
+	}
+
+	bucket.requests++
+	if failed {
+		bucket.errors++
+	}
+}
+
+func (w *rollingWindow) totals(now time.Time) (int, int) {
+	epoch := now.UnixNano() / int64(w.bucketSize)
+
+	requests, errors := 0, 0
+	for _, bucket := range w.buckets {
+		if epoch-bucket.epoch < breakerBuckets {
+			requests += bucket.requests
+			errors += bucket.errors
+		}
+	}
+	return requests, errors
+}
+
+func (w *rollingWindow) reset() {
+	w.buckets = [breakerBuckets]windowBucket{}
+}
@@ -0,0 +1,325 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+func newTestBreaker(t *testing.T, config *pb.CircuitBreaker) (*circuitBreaker, *fakeClock) {
+	t.Helper()
+
+	breaker, err := newCircuitBreaker("backend", config)
+	if err != nil {
+		t.Fatalf("newCircuitBreaker: %v", err)
+	}
+	if breaker == nil {
+		t.Fatal("newCircuitBreaker returned no breaker")
+	}
+
+	clock := newFakeClock()
+	breaker.now = clock.Now
+	return breaker, clock
+}
+
+// Send one request through the breaker, failing the test if it is rejected
+func mustPass(t *testing.T, cb *circuitBreaker, failed bool) {
+	t.Helper()
+
+	release, err := cb.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v (state %v)", err, cb.currentState())
+	}
+	outcome := outcomeSuccess
+	if failed {
+		outcome = outcomeUpstreamFailure
+	}
+	release(outcome)
+}
+
+func expectState(t *testing.T, cb *circuitBreaker, want breakerState) {
+	t.Helper()
+
+	if got := cb.currentState(); got != want {
+		t.Fatalf("state = %v, want %v", got, want)
+	}
+}
+
+func TestNewCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name string
+		config *pb.CircuitBreaker
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "unset", config: nil, wantNil: true},
+		{name: "nothing enabled", config: &pb.CircuitBreaker{MinRequests: 5}, wantNil: true},
+		{name: "consecutive errors", config: &pb.CircuitBreaker{ConsecutiveErrors: 5}},
+		{name: "concurrency only", config: &pb.CircuitBreaker{MaxRequests: 10}},
+		{name: "error ratio above 1", config: &pb.CircuitBreaker{ErrorRatio: 1.5}, wantErr: true},
+		{name: "negative threshold", config: &pb.CircuitBreaker{ConsecutiveErrors: -1}, wantErr: true},
+		{name: "pending without max requests", config: &pb.CircuitBreaker{ConsecutiveErrors: 5, MaxPendingRequests: 3}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker, err := newCircuitBreaker("backend", tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newCircuitBreaker: error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (breaker == nil) != tt.wantNil {
+				t.Fatalf("newCircuitBreaker: breaker = %v, wantNil %v", breaker, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerConsecutiveErrors(t *testing.T) {
+	cb, clock := newTestBreaker(t, &pb.CircuitBreaker{ConsecutiveErrors: 3, OpenDurationMs: 30000})
+
+	// A success in between starts the count over
+	mustPass(t, cb, true)
+	mustPass(t, cb, true)
+	mustPass(t, cb, false)
+	mustPass(t, cb, true)
+	mustPass(t, cb, true)
+	expectState(t, cb, breakerClosed)
+
+	mustPass(t, cb, true)
+	expectState(t, cb, breakerOpen)
+
+	if _, err := cb.acquire(context.Background()); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("acquire while open: error = %v, want errCircuitOpen", err)
+	}
+
+	clock.Advance(29 * time.Second)
+	if _, err := cb.acquire(context.Background()); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("acquire before open_duration: error = %v, want errCircuitOpen", err)
+	}
+
+	// Open duration over: one trial goes through, the next one waits for it
+	clock.Advance(time.Second)
+	release, err := cb.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("trial acquire: %v", err)
+	}
+	expectState(t, cb, breakerHalfOpen)
+
+	if _, err := cb.acquire(context.Background()); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("second trial: error = %v, want errCircuitOpen", err)
+	}
+
+	release(outcomeSuccess)
+	expectState(t, cb, breakerClosed)
+	mustPass(t, cb, false)
+}
+
+func TestCircuitBreakerHalfOpenFailureReopens(t *testing.T) {
+	cb, clock := newTestBreaker(t, &pb.CircuitBreaker{ConsecutiveErrors: 1, OpenDurationMs: 10000, HalfOpenRequests: 2})
+
+	mustPass(t, cb, true)
+	expectState(t, cb, breakerOpen)
+
+	clock.Advance(10 * time.Second)
+	mustPass(t, cb, false)
+	expectState(t, cb, breakerHalfOpen)
+
+	// The second trial fails: open again for a full open_duration
+	mustPass(t, cb, true)
+	expectState(t, cb, breakerOpen)
+
+	clock.Advance(9 * time.Second)
+	if _, err := cb.acquire(context.Background()); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("acquire after reopening: error = %v, want errCircuitOpen", err)
+	}
+
+	clock.Advance(time.Second)
+	mustPass(t, cb, false)
+	mustPass(t, cb, false)
+	expectState(t, cb, breakerClosed)
+}
+
+func TestCircuitBreakerIgnoresRequestsFromBeforeHalfOpen(t *testing.T) {
+	cb, clock := newTestBreaker(t, &pb.CircuitBreaker{ConsecutiveErrors: 1, OpenDurationMs: 10000})
+
+	slow, err := cb.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	mustPass(t, cb, true)
+	clock.Advance(10 * time.Second)
+
+	trial, err := cb.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("trial acquire: %v", err)
+	}
+
+	// Admitted while closed, so it says nothing about the recovery
+	slow(outcomeUpstreamFailure)
+	expectState(t, cb, breakerHalfOpen)
+
+	trial(outcomeSuccess)
+	expectState(t, cb, breakerClosed)
+}
+
+func TestCircuitBreakerErrorRatio(t *testing.T) {
+	cb, clock := newTestBreaker(t, &pb.CircuitBreaker{ErrorRatio: 0.5, MinRequests: 4, WindowMs: 10000})
+
+	// Not enough requests to judge
+	for i := 0; i < 3; i++ {
+		mustPass(t, cb, true)
+	}
+	expectState(t, cb, breakerClosed)
+
+	// The window moved on, the old errors are forgotten
+	clock.Advance(11 * time.Second)
+	mustPass(t, cb, true)
+	mustPass(t, cb, false)
+	mustPass(t, cb, false)
+	expectState(t, cb, breakerClosed)
+
+	mustPass(t, cb, true)
+	expectState(t, cb, breakerOpen)
+}
+
+func TestRollingWindow(t *testing.T) {
+	clock := newFakeClock()
+	window := rollingWindow{bucketSize: time.Second}
+
+	window.add(clock.Now(), true)
+	window.add(clock.Now(), false)
+
+	clock.Advance(5 * time.Second)
+	window.add(clock.Now(), true)
+
+	if requests, errors := window.totals(clock.Now()); requests != 3 || errors != 2 {
+		t.Fatalf("totals = %d requests, %d errors, want 3 and 2", requests, errors)
+	}
+
+	// The first bucket is now more than a window old
+	clock.Advance(5 * time.Second)
+	if requests, errors := window.totals(clock.Now()); requests != 1 || errors != 1 {
+		t.Fatalf("totals = %d requests, %d errors, want 1 and 1", requests, errors)
+	}
+
+	// A bucket reused for a later slice of time starts empty
+	window.add(clock.Now(), false)
+	if requests, errors := window.totals(clock.Now()); requests != 2 || errors != 1 {
+		t.Fatalf("totals = %d requests, %d errors, want 2 and 1", requests, errors)
+	}
+
+	clock.Advance(20 * time.Second)
+	if requests, errors := window.totals(clock.Now()); requests != 0 || errors != 0 {
+		t.Fatalf("totals = %d requests, %d errors, want 0 and 0", requests, errors)
+	}
+}
+
+func TestCircuitBreakerConcurrencyLimit(t *testing.T) {
+	cb, _ := newTestBreaker(t, &pb.CircuitBreaker{MaxRequests: 1})
+
+	release, err := cb.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	if _, err := cb.acquire(context.Background()); !errors.Is(err, errCircuitOverflow) {
+		t.Fatalf("acquire over the limit: error = %v, want errCircuitOverflow", err)
+	}
+
+	release(outcomeSuccess)
+	mustPass(t, cb, false)
+}
+
+func TestCircuitBreakerPendingRequests(t *testing.T) {
+	cb, _ := newTestBreaker(t, &pb.CircuitBreaker{MaxRequests: 1, MaxPendingRequests: 1})
+
+	release, err := cb.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// The one waiting gets the slot once it is released
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := cb.acquire(context.Background())
+		if err == nil {
+			release(outcomeSuccess)
+		}
+		acquired <- err
+	}()
+
+	for cb.pending.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := cb.acquire(context.Background()); !errors.Is(err, errCircuitOverflow) {
+		t.Fatalf("acquire with a full queue: error = %v, want errCircuitOverflow", err)
+	}
+
+	release(outcomeSuccess)
+	if err := <-acquired; err != nil {
+		t.Fatalf("pending acquire: %v", err)
+	}
+
+	// A waiter whose request goes away gives up
+	release, _ = cb.acquire(context.Background())
+	defer release(outcomeSuccess)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cb.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire with a cancelled context: error = %v, want context.Canceled", err)
+	}
+}
+
+func TestCircuitBreakerTrialWithoutSlotIsGivenBack(t *testing.T) {
+	cb, clock := newTestBreaker(t, &pb.CircuitBreaker{ConsecutiveErrors: 1, OpenDurationMs: 1000, MaxRequests: 1})
+
+	mustPass(t, cb, true)
+	clock.Advance(time.Second)
+
+	// Hold the only slot, as a request admitted before the breaker opened would
+	cb.slots <- struct{}{}
+	if _, err := cb.acquire(context.Background()); !errors.Is(err, errCircuitOverflow) {
+		t.Fatalf("trial without a slot: error = %v, want errCircuitOverflow", err)
+	}
+	<-cb.slots
+
+	// The place of the trial is free again
+	mustPass(t, cb, false)
+	expectState(t, cb, breakerClosed)
+}
+
+func TestCircuitBreakerUnknownOutcome(t *testing.T) {
+	cb, clock := newTestBreaker(t, &pb.CircuitBreaker{ConsecutiveErrors: 2, OpenDurationMs: 1000})
+
+	// Clients going away between two failures neither trip nor reset the breaker
+	mustPass(t, cb, true)
+	for i := 0; i < 5; i++ {
+		release, err := cb.acquire(context.Background())
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		release(outcomeUnknown)
+	}
+	expectState(t, cb, breakerClosed)
+
+	mustPass(t, cb, true)
+	expectState(t, cb, breakerOpen)
+
+	// A trial that says nothing leaves the breaker half-open, with its place free again
+	clock.Advance(time.Second)
+	release, err := cb.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("trial acquire: %v", err)
+	}
+	release(outcomeUnknown)
+	expectState(t, cb, breakerHalfOpen)
+
+	mustPass(t, cb, false)
+	expectState(t, cb, breakerClosed)
+}
@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+)
+
+// Endpoint is a single upstream instance of a cluster
+type Endpoint struct {
+	address string
+	url *url.URL
+
+	// Part of a mesh cluster: reached over mTLS, presenting this SPIFFE ID
+	mtls bool
+	identity string
+
+	// Requests currently being forwarded to this endpoint (used by the load balancers)
+	activeRequests atomic.Int64
+
+	// Set by the active health checker, endpoints start healthy
+	unhealthy atomic.Bool
+
+	// Set by the outlier detector: unix nanoseconds until which the endpoint is out of load balancing
+	ejectedUntil atomic.Int64
+}
+
+// Address of the endpoint as configured (e.g., "10.0.0.1:5000")
+func (e *Endpoint) Address() string {
+	return e.address
+}
+
+// Healthy reports the last state seen by the active health checker
+func (e *Endpoint) Healthy() bool {
+	return !e.unhealthy.Load()
+}
+
+// Ejected reports whether the outlier detector took the endpoint out of load balancing
+func (e *Endpoint) Ejected(now time.Time) bool {
+	return now.UnixNano() < e.ejectedUntil.Load()
+}
+
+// Available tells the load balancers whether the endpoint can take traffic
+func (e *Endpoint) Available() bool {
+	return e.Healthy() && !e.Ejected(time.Now())
+}
+
+// ActiveRequests returns how many requests are in flight to this endpoint
+func (e *Endpoint) ActiveRequests() int64 {
+	return e.activeRequests.Load()
+}
+
+// Cluster is a named group of endpoints serving the same service
+type Cluster struct {
+	name string
+	config *pb.Cluster
+	endpoints []*Endpoint
+
+	// Running while the cluster is in the live route table (nil without health checks)
+	healthChecker *healthChecker
+
+	// Shared by all the routes of the cluster (nil when disabled)
+	breaker *circuitBreaker
+
+	// Passive health checking on real traffic (nil when disabled)
+	outlier *outlierDetector
+
+	// Caps the retries of all the routes sending traffic to the cluster
+	retryBudget *retryBudget
+}
+
+// Build a cluster from its control plane definition
+func newCluster(config *pb.Cluster) (*Cluster, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("cluster name is empty")
+	}
+
+	if len(config.Endpoints) == 0 {
+		return nil, fmt.Errorf("cluster %q has no endpoints", config.Name)
+	}
+
+	if err := validateHealthCheck(config.HealthCheck); err != nil {
+		return nil, fmt.Errorf("cluster %q has an invalid health check: %w", config.Name, err)
+	}
+
+	if err := validateUpstreamIdentity(config.Mtls, config.Identity); err != nil {
+		return nil, fmt.Errorf("cluster %q: %w", config.Name, err)
+	}
+
+	breaker, err := newCircuitBreaker(config.Name, config.CircuitBreaker)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %q has an invalid circuit breaker: %w", config.Name, err)
+	}
+
+	retryBudget, err := newRetryBudget(config.RetryBudget)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %q has an invalid retry budget: %w", config.Name, err)
+	}
+
+	cluster := &Cluster{
+		name: config.Name,
+		config: config,
+		endpoints: make([]*Endpoint, 0, len(config.Endpoints)),
+		breaker: breaker,
+		retryBudget: retryBudget,
+	}
+
+	for _, address := range config.Endpoints {
+		endpointURL, err := parseEndpointURL(address, config.Mtls)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %q has an invalid endpoint: %w", config.Name, err)
+		}
+
+		cluster.endpoints = append(cluster.endpoints, &Endpoint{
+			address: address,
+			url: endpointURL,
+			mtls: config.Mtls,
+			identity: config.Identity,
+		})
+	}
+
+	cluster.outlier, err = newOutlierDetector(cluster, config.OutlierDetection)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %q has an invalid outlier detection: %w", config.Name, err)
+	}
+
+	return cluster, nil
+}
+
+// Name of the cluster, also used as the service label in metrics
+func (c *Cluster) Name() string {
+	return c.name
+}
+
+// Get the cluster ready to serve: wire logs and metrics, start the health checks
+// Called once, before the cluster is put in the live route table
+func (c *Cluster) start(logger *logging.Logger, metrics *Metrics, onHealthChange func(), transport http.RoundTripper) {
+	if c.breaker != nil {
+		c.breaker.logger = logger
+		c.breaker.metrics = metrics
+	}
+
+	if c.outlier != nil {
+		c.outlier.start(logger, metrics)
+	}
+
+	c.startHealthChecks(logger, metrics, onHealthChange, transport)
+}
+
+// Stop a cluster that left the live route table and drop its gauges
+func (c *Cluster) stop(metrics *Metrics) {
+	c.stopHealthChecks()
+
+	if c.outlier != nil {
+		c.outlier.stop()
+	}
+
+	if c.breaker != nil {
+		metrics.DeleteCircuitBreakerState(c.name)
+	}
+
+	for _, endpoint := range c.endpoints {
+		metrics.DeleteEndpointHealth(c.name, endpoint.address)
+		metrics.DeleteEndpointEjected(c.name, endpoint.address)
+	}
+}
+
+// Export the endpoint health and ejections and the circuit breaker state
+func (c *Cluster) exportState(metrics *Metrics) {
+	for _, endpoint := range c.endpoints {
+		metrics.SetEndpointHealth(c.name, endpoint.address, endpoint.Healthy())
+	}
+
+	if c.outlier != nil {
+		c.outlier.exportState(metrics)
+	}
+
+	if c.breaker != nil {
+		metrics.SetCircuitBreakerState(c.name, c.breaker.currentState().String())
+	}
+}
+
+// Health of every endpoint, as reported to the control plane
+func (c *Cluster) endpointHealth() []*pb.EndpointHealth {
+	health := make([]*pb.EndpointHealth, 0, len(c.endpoints))
+	for _, endpoint := range c.endpoints {
+		health = append(health, &pb.EndpointHealth{
+			Cluster: c.name,
+			Endpoint: endpoint.address,
+			Healthy: endpoint.Healthy(),
+		})
+	}
+	return health
+}
@@ -17,6 +17,11 @@ type ProxyConfig struct {
 	ListenPort int `yaml:"listen_port"`
 	Backend BackendConfig `yaml:"backend"`
 	Timeout TimeoutConfig `yaml:"timeout"`
+	ControlPlane ControlPlaneConfig `yaml:"control_plane"`
+	Auth AuthConfig `yaml:"auth"`
+	RateLimitService RateLimitServiceConfig `yaml:"rate_limit_service"`
+	TLS TLSConfig `yaml:"tls"`
+	AccessControl AccessControlConfig `yaml:"access_control"`
 }
 
 type BackendConfig struct {
@@ -30,6 +35,82 @@ type TimeoutConfig struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout"`
 }
 
+// Where to find the control plane and how to reconnect to it
+// If Address is empty the proxy only serves the static backend above
+type ControlPlaneConfig struct {
+	Address string `yaml:"address"`
+	ProxyID string `yaml:"proxy_id"`
+	// Identity of the workload behind the proxy (empty: "default" and the proxy ID)
+	Namespace string `yaml:"namespace"`
+	ServiceAccount string `yaml:"service_account"`
+	ReconnectBackoff time.Duration `yaml:"reconnect_backoff"`
+	MaxReconnectBackoff time.Duration `yaml:"max_reconnect_backoff"`
+	TLS ControlPlaneTLSConfig `yaml:"tls"`
+	// File holding the bootstrap token of this proxy, sent on every call (requires TLS)
+	TokenFile string `yaml:"token_file"`
+}
+
+// TLS of the control channel
+// The client certificate is optional: it authenticates the proxy when the control plane checks them (CN = proxy ID)
+type ControlPlaneTLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CAs of the control plane certificate (empty: the system ones)
+	CAFile string `yaml:"ca_file"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile string `yaml:"key_file"`
+	// Name expected in the control plane certificate (empty: the host of the address)
+	ServerName string `yaml:"server_name"`
+}
+
+// Where to find the global rate limit service and how to use it
+// The address defaults to the control plane one, which hosts the service
+type RateLimitServiceConfig struct {
+	Address string `yaml:"address"`
+	Timeout time.Duration `yaml:"timeout"`
+	BatchWindow time.Duration `yaml:"batch_window"`
+	Prefetch int `yaml:"prefetch"`
+	LeaseTTL time.Duration `yaml:"lease_ttl"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+}
+
+// TLS termination on the listener
+// Certificates come from these files and from the control plane, the one matching the client SNI is served
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Certificates []CertificateFileConfig `yaml:"certificates"`
+	MinVersion string `yaml:"min_version"`
+	CipherSuites []string `yaml:"cipher_suites"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// Clients accepted by the listener, by IP or CIDR range, before any route is matched
+// Behind the trusted proxies, the client address is read from X-Forwarded-For
+type AccessControlConfig struct {
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	Allow []string `yaml:"allow"`
+	Deny []string `yaml:"deny"`
+}
+
+type CertificateFileConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile string `yaml:"key_file"`
+}
+
+// Local credentials for auth_required routes
+// Replaced by the control plane as soon as it sends its own auth config
+type AuthConfig struct {
+	JWKSFile string `yaml:"jwks_file"`
+	Issuer string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	APIKeys []APIKeyConfig `yaml:"api_keys"`
+}
+
+type APIKeyConfig struct {
+	Key string `yaml:"key"`
+	Subject string `yaml:"subject"`
+	Scopes []string `yaml:"scopes"`
+}
+
 // Load Configuration from YAML
 func LoadConfig (filepath string) (*Config, error) {
 
@@ -66,6 +147,99 @@ func (c *Config) Validate() (error) {
 		return fmt.Errorf("invalid Backend Port: %d (must be 1-65535)", c.Proxy.Backend.Port)
 	}
 
+	// Control plane is optional, fill in sensible defaults when it is configured
+	if c.Proxy.ControlPlane.Address != "" {
+		if c.Proxy.ControlPlane.ProxyID == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return fmt.Errorf("control_plane.proxy_id is empty and hostname is unavailable: %w", err)
+			}
+			c.Proxy.ControlPlane.ProxyID = hostname
+		}
+
+		if c.Proxy.ControlPlane.ReconnectBackoff <= 0 {
+			c.Proxy.ControlPlane.ReconnectBackoff = time.Second
+		}
+
+		if c.Proxy.ControlPlane.MaxReconnectBackoff <= 0 {
+			c.Proxy.ControlPlane.MaxReconnectBackoff = 30 * time.Second
+		}
+
+		if c.Proxy.ControlPlane.MaxReconnectBackoff < c.Proxy.ControlPlane.ReconnectBackoff {
+			c.Proxy.ControlPlane.MaxReconnectBackoff = c.Proxy.ControlPlane.ReconnectBackoff
+		}
+
+		// A token sent in plaintext could be replayed by anyone on the path
+		if c.Proxy.ControlPlane.TokenFile != "" && !c.Proxy.ControlPlane.TLS.Enabled {
+			return fmt.Errorf("control_plane.token_file requires control_plane.tls.enabled")
+		}
+
+		if cpTLS := c.Proxy.ControlPlane.TLS; (cpTLS.CertFile == "") != (cpTLS.KeyFile == "") {
+			return fmt.Errorf("control_plane.tls needs both a cert_file and a key_file, or neither")
+		}
+	}
+
+	// Global rate limiting is used when the service has an address (its own or the control plane one)
+	rls := &c.Proxy.RateLimitService
+	if rls.Address == "" {
+		rls.Address = c.Proxy.ControlPlane.Address
+	}
+
+	if rls.Address != "" {
+		if rls.Timeout <= 0 {
+			rls.Timeout = 100 * time.Millisecond
+		}
+
+		if rls.BatchWindow <= 0 {
+			rls.BatchWindow = 2 * time.Millisecond
+		}
+
+		if rls.Prefetch < 0 {
+			return fmt.Errorf("invalid rate_limit_service.prefetch: %d (must not be negative)", rls.Prefetch)
+		}
+
+		if rls.LeaseTTL <= 0 {
+			rls.LeaseTTL = time.Second
+		}
+
+		if rls.RetryInterval <= 0 {
+			rls.RetryInterval = time.Second
+		}
+	}
+
+	if _, err := parsePrefixes(c.Proxy.AccessControl.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid access_control.trusted_proxies: %w", err)
+	}
+
+	if _, err := newIPAccessList(c.Proxy.AccessControl.Allow, c.Proxy.AccessControl.Deny); err != nil {
+		return fmt.Errorf("invalid access_control: %w", err)
+	}
+
+	// Certificates may all come from the control plane, so none are required here
+	if tlsConfig := &c.Proxy.TLS; tlsConfig.Enabled {
+		if tlsConfig.MinVersion == "" {
+			tlsConfig.MinVersion = "1.2"
+		}
+
+		if _, err := tlsVersion(tlsConfig.MinVersion); err != nil {
+			return fmt.Errorf("invalid tls.min_version: %w", err)
+		}
+
+		if _, err := cipherSuites(tlsConfig.CipherSuites); err != nil {
+			return fmt.Errorf("invalid tls.cipher_suites: %w", err)
+		}
+
+		for i, cert := range tlsConfig.Certificates {
+			if cert.CertFile == "" || cert.KeyFile == "" {
+				return fmt.Errorf("tls.certificates[%d] needs a cert_file and a key_file", i)
+			}
+		}
+
+		if tlsConfig.ReloadInterval <= 0 {
+			tlsConfig.ReloadInterval = 10 * time.Second
+		}
+	}
+
 	return nil
 }
 
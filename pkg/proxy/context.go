@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+)
+
+// Unexported key type, so no other package can collide with our context values
+type contextKey int
+
+const (
+	routeKey contextKey = iota
+	identityKey
+	endpointKey
+	hashKeyKey
+	attemptKey
+	requestLabelsKey
+	clientKey
+	breakerOutcomeKey
+	affinityCookieKey
+)
+
+// Store the matched route in the request context
+func withRoute(ctx context.Context, rt *route) context.Context {
+	return context.WithValue(ctx, routeKey, rt)
+}
+
+// Get the route matched for this request (nil if none)
+func routeFromContext(ctx context.Context) *route {
+	rt, _ := ctx.Value(routeKey).(*route)
+	return rt
+}
+
+// Store the authenticated identity in the request context
+func withIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+// IdentityFromContext returns who made the request (nil if it was not authenticated)
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey).(*Identity)
+	return identity
+}
+
+// Store who sent the request, resolved behind the trusted proxies
+func withClient(ctx context.Context, client *clientInfo) context.Context {
+	return context.WithValue(ctx, clientKey, client)
+}
+
+// Get who sent the request (nil when it was not resolved, e.g. outside the listener)
+func clientFromContext(ctx context.Context) *clientInfo {
+	client, _ := ctx.Value(clientKey).(*clientInfo)
+	return client
+}
+
+// Store the endpoint picked by the load balancer
+func withEndpoint(ctx context.Context, endpoint *Endpoint) context.Context {
+	return context.WithValue(ctx, endpointKey, endpoint)
+}
+
+// Get the endpoint this request is forwarded to (nil if none was picked yet)
+func endpointFromContext(ctx context.Context) *Endpoint {
+	endpoint, _ := ctx.Value(endpointKey).(*Endpoint)
+	return endpoint
+}
+
+// Store the consistent hashing key of the request
+func withHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKeyKey, key)
+}
+
+// Get the consistent hashing key (false if the request has none)
+func hashKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(hashKeyKey).(string)
+	return key, ok
+}
+
+// Store the state of the current retry attempt
+func withAttempt(ctx context.Context, state *attemptState) context.Context {
+	return context.WithValue(ctx, attemptKey, state)
+}
+
+// Get the state of the current attempt (nil when the route doesn't retry)
+func attemptFromContext(ctx context.Context) *attemptState {
+	state, _ := ctx.Value(attemptKey).(*attemptState)
+	return state
+}
+
+// Store the metric labels the handler fills in
+func withRequestLabels(ctx context.Context, labels *requestLabels) context.Context {
+	return context.WithValue(ctx, requestLabelsKey, labels)
+}
+
+// Get the metric labels of the request (nil when metrics are not recorded)
+func requestLabelsFromContext(ctx context.Context) *requestLabels {
+	labels, _ := ctx.Value(requestLabelsKey).(*requestLabels)
+	return labels
+}
+
+// Store where the request reports to the circuit breaker what the cluster answered
+func withBreakerOutcome(ctx context.Context, outcome *breakerOutcome) context.Context {
+	return context.WithValue(ctx, breakerOutcomeKey, outcome)
+}
+
+// Get where to report the answer of the cluster (nil when the cluster has no circuit breaker)
+func breakerOutcomeFromContext(ctx context.Context) *breakerOutcome {
+	outcome, _ := ctx.Value(breakerOutcomeKey).(*breakerOutcome)
+	return outcome
+}
+
+// Store the affinity cookie issued for this request, set on the response once the request is let through
+func withAffinityCookie(ctx context.Context, cookie *http.Cookie) context.Context {
+	return context.WithValue(ctx, affinityCookieKey, cookie)
+}
+
+// Get the affinity cookie issued for this request (nil if none)
+func affinityCookieFromContext(ctx context.Context) *http.Cookie {
+	cookie, _ := ctx.Value(affinityCookieKey).(*http.Cookie)
+	return cookie
+}
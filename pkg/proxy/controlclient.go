@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"os"
+	"strings"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Version reported to the control plane when registering
+const Version = "0.1.0"
+
+// How long a single RegisterProxy call may take
+const registerTimeout = 5 * time.Second
+
+// ControlPlaneClient registers the proxy with the control plane
+// and applies every config update it streams back to the handler
+type ControlPlaneClient struct {
+	config *ControlPlaneConfig
+	info *pb.ProxyInfo
+	handler *Handler
+	logger *logging.Logger
+}
+
+// Create a new control plane client for the given handler
+func NewControlPlaneClient(config *Config, handler *Handler, logger *logging.Logger) *ControlPlaneClient {
+	return &ControlPlaneClient{
+		config: &config.Proxy.ControlPlane,
+		info: &pb.ProxyInfo{
+			ProxyId: config.Proxy.ControlPlane.ProxyID,
+			Version: Version,
+			ListenAddr: fmt.Sprintf("0.0.0.0:%d", config.Proxy.ListenPort),
+			Namespace: config.Proxy.ControlPlane.Namespace,
+			ServiceAccount: config.Proxy.ControlPlane.ServiceAccount,
+			InstanceId: newInstanceID(),
+		},
+		handler: handler,
+		logger: logger.With(zap.String("control_plane", config.Proxy.ControlPlane.Address)),
+	}
+}
+
+// Run keeps the proxy connected to the control plane until ctx is cancelled
+// When the stream drops it reconnects with exponential backoff,
+// meanwhile the last good config keeps serving traffic
+func (c *ControlPlaneClient) Run(ctx context.Context) error {
+
+	dialOptions, err := controlPlaneDialOptions(c.config)
+	if err != nil {
+		return err
+	}
+
+	// The connection is lazy: it dials on first use and redials by itself
+	conn, err := grpc.NewClient(c.config.Address, dialOptions...)
+	if err != nil {
+		return fmt.Errorf("Failed to create control plane client: %w", err)
+	}
+	defer conn.Close()
+
+	client := pb.NewMeshControlClient(conn)
+	backoff := c.config.ReconnectBackoff
+
+	for {
+		received, err := c.connectAndStream(ctx, client)
+
+		// Shutting down, not an error
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// A healthy session resets the backoff, a failing one keeps growing it
+		if received > 0 {
+			backoff = c.config.ReconnectBackoff
+		}
+
+		wait := jitter(backoff)
+		c.logger.Warn("control plane stream lost, reconnecting",
+			zap.Error(err),
+			zap.Int64("serving_version", c.handler.ConfigVersion()),
+			zap.Duration("retry_in", wait),
+		)
+
+		select {
+		case <- ctx.Done():
+			return nil
+		case <- time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > c.config.MaxReconnectBackoff {
+			backoff = c.config.MaxReconnectBackoff
+		}
+	}
+}
+
+// Register the proxy, then apply config updates until the stream breaks
+// Returns how many updates were received in this session
+func (c *ControlPlaneClient) connectAndStream(ctx context.Context, client pb.MeshControlClient) (int, error) {
+
+	registerCtx, cancel := context.WithTimeout(ctx, registerTimeout)
+	resp, err := client.RegisterProxy(registerCtx, c.info)
+	cancel()
+	switch status.Code(err) {
+	case codes.AlreadyExists, codes.PermissionDenied, codes.Unauthenticated:
+		// Retrying only helps once the other proxy leaves or the credentials are fixed, keep trying anyway
+		c.logger.Error("control plane refused this proxy, check proxy_id and credentials",
+			zap.String("proxy_id", c.info.ProxyId),
+			zap.Error(err),
+		)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("register failed: %w", err)
+	}
+	if !resp.Success {
+		return 0, fmt.Errorf("registration rejected: %s", resp.Message)
+	}
+
+	c.logger.Info("registered with control plane",
+		zap.String("proxy_id", c.info.ProxyId),
+		zap.String("message", resp.Message),
+	)
+
+	// Everything started for this session ends with it
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.StreamConfig(ctx, c.info)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open config stream: %w", err)
+	}
+
+	go c.reportHealth(ctx, client)
+
+	received := 0
+	for {
+		update, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return received, fmt.Errorf("control plane closed the config stream")
+		}
+		if err != nil {
+			return received, err
+		}
+		received++
+
+		// A bad update is logged and skipped, the previous table stays in place
+		if err := c.handler.ApplyConfig(update); err != nil {
+			c.logger.Error("failed to apply config update",
+				zap.Int64("version", update.Version),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// Send the endpoint health to the control plane: once now, then on every change
+// A failed report is only logged, the next change (or session) sends the full state again
+func (c *ControlPlaneClient) reportHealth(ctx context.Context, client pb.MeshControlClient) {
+	for {
+		reportCtx, cancel := context.WithTimeout(ctx, registerTimeout)
+		_, err := client.ReportHealth(reportCtx, &pb.HealthReport{
+			ProxyId: c.info.ProxyId,
+			Endpoints: c.handler.EndpointHealth(),
+		})
+		cancel()
+
+		if err != nil && ctx.Err() == nil {
+			c.logger.Warn("failed to report endpoint health",
+				zap.Error(err),
+			)
+		}
+
+		select {
+		case <- ctx.Done():
+			return
+		case <- c.handler.HealthChanges():
+		}
+	}
+}
+
+// Spread reconnections so that many proxies don't hit the control plane at once
+// Returns a duration between 50% and 100% of d
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(mathrand.Int63n(int64(d/2)+1))
+}
+
+// Random ID of this proxy process, so the control plane can tell a reconnect from a second proxy with the same ID
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Credentials for the control plane connection, shared by the services it hosts
+func controlPlaneDialOptions(config *ControlPlaneConfig) ([]grpc.DialOption, error) {
+	if !config.TLS.Enabled {
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: config.TLS.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config.TLS.CAFile != "" {
+		caPEM, err := os.ReadFile(config.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read control plane CA: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in control plane CA %s", config.TLS.CAFile)
+		}
+	}
+
+	if config.TLS.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(config.TLS.CertFile, config.TLS.KeyFile); err != nil {
+			return nil, fmt.Errorf("Failed to load control plane client certificate: %w", err)
+		}
+
+		// Read again on every handshake, so a renewed certificate is used on the next reconnect
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(config.TLS.CertFile, config.TLS.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			return &cert, nil
+		}
+	}
+
+	options := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+
+	if config.TokenFile != "" {
+		data, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read bootstrap token: %w", err)
+		}
+
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return nil, fmt.Errorf("bootstrap token file %s is empty", config.TokenFile)
+		}
+		options = append(options, grpc.WithPerRPCCredentials(bootstrapToken(token)))
+	}
+
+	return options, nil
+}
+
+// bootstrapToken sends the token of the proxy with every call, as a bearer token
+type bootstrapToken string
+
+func (t bootstrapToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bootstrapToken) RequireTransportSecurity() bool {
+	return true
+}
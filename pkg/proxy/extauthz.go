@@ -0,0 +1,383 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// How long a route waits for the decision when it sets no timeout
+const defaultExtAuthzTimeout = 200 * time.Millisecond
+
+// Biggest CheckResponse read from an HTTP authorization service
+const maxExtAuthzResponseSize = 1 << 20
+
+// Headers the authorization service can't set: Host, the hop-by-hop ones and the body framing ones
+var extAuthzReservedHeaders = map[string]bool{
+	"Host": true,
+	"Connection": true,
+	"Keep-Alive": true,
+	"Proxy-Connection": true,
+	"Proxy-Authenticate": true,
+	"Proxy-Authorization": true,
+	"Te": true,
+	"Trailer": true,
+	"Transfer-Encoding": true,
+	"Upgrade": true,
+	"Content-Length": true,
+}
+
+// extAuthzPolicy is the validated ext_authz config of a route
+type extAuthzPolicy struct {
+	config *pb.ExtAuthz
+	timeout time.Duration
+
+	// Canonical names of the headers sent to the service (nil: all of them)
+	headers map[string]bool
+}
+
+// Check the ext_authz config of a route (nil when the route has none)
+func newExtAuthzPolicy(config *pb.ExtAuthz) (*extAuthzPolicy, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	if (config.GrpcAddress == "") == (config.HttpUrl == "") {
+		return nil, fmt.Errorf("exactly one of grpc_address and http_url must be set")
+	}
+
+	if config.HttpUrl != "" {
+		u, err := url.Parse(config.HttpUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("http_url %q must be an http:// or https:// URL", config.HttpUrl)
+		}
+	}
+
+	if config.TimeoutMs < 0 {
+		return nil, fmt.Errorf("negative timeout_ms %d", config.TimeoutMs)
+	}
+
+	policy := &extAuthzPolicy{
+		config: config,
+		timeout: defaultExtAuthzTimeout,
+	}
+	if config.TimeoutMs > 0 {
+		policy.timeout = time.Duration(config.TimeoutMs) * time.Millisecond
+	}
+
+	if len(config.IncludeHeaders) > 0 {
+		policy.headers = make(map[string]bool, len(config.IncludeHeaders))
+		for _, name := range config.IncludeHeaders {
+			if name == "" {
+				return nil, fmt.Errorf("empty name in include_headers")
+			}
+			policy.headers[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+
+	return policy, nil
+}
+
+// extAuthzClient asks the authorization services for decisions
+// gRPC connections are shared by every route and config version using the same address,
+// until no route uses the address anymore
+type extAuthzClient struct {
+	httpClient *http.Client
+
+	mu sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func newExtAuthzClient() *extAuthzClient {
+	return &extAuthzClient{
+		httpClient: &http.Client{},
+		conns: make(map[string]*grpc.ClientConn),
+	}
+}
+
+func (c *extAuthzClient) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	c.conns = make(map[string]*grpc.ClientConn)
+}
+
+// Close the connections to the addresses no route uses anymore
+func (c *extAuthzClient) retain(addresses map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for address, conn := range c.conns {
+		if !addresses[address] {
+			conn.Close()
+			delete(c.conns, address)
+		}
+	}
+}
+
+// Ask the service of the policy, an error means it couldn't decide
+func (c *extAuthzClient) check(ctx context.Context, policy *extAuthzPolicy, request *pb.CheckRequest) (*pb.CheckResponse, error) {
+	var response *pb.CheckResponse
+	var err error
+
+	if policy.config.GrpcAddress != "" {
+		var conn *grpc.ClientConn
+		if conn, err = c.conn(policy.config.GrpcAddress); err != nil {
+			return nil, err
+		}
+		response, err = pb.NewExternalAuthorizationClient(conn).Check(ctx, request)
+	} else {
+		response, err = c.checkHTTP(ctx, policy.config.HttpUrl, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCheckResponse(response); err != nil {
+		return nil, fmt.Errorf("invalid CheckResponse: %w", err)
+	}
+	return response, nil
+}
+
+// The headers of the answer end up on the request and the response as they are, check them first
+func validateCheckResponse(response *pb.CheckResponse) error {
+	for _, headers := range [][]*pb.HeaderValue{response.RequestHeaders, response.ResponseHeaders} {
+		for _, header := range headers {
+			if !validHeaderName(header.Name) {
+				return fmt.Errorf("invalid header name %q", header.Name)
+			}
+			if extAuthzReservedHeaders[http.CanonicalHeaderKey(header.Name)] {
+				return fmt.Errorf("header %q can't be set by the authorization service", header.Name)
+			}
+			if !validHeaderValue(header.Value) {
+				return fmt.Errorf("invalid value for header %q", header.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// A token of RFC 9110: letters, digits and !#$%&'*+-.^_`|~
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// No control characters but tab, so the value can't end the header or start another one
+func validHeaderValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c < ' ' && c != '\t' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// The connection is lazy: nothing is dialed until the first check
+func (c *extAuthzClient) conn(address string) (*grpc.ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, ok := c.conns[address]; ok {
+		return conn, nil
+	}
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	c.conns[address] = conn
+	return conn, nil
+}
+
+// Same messages as the gRPC service, in JSON with the field names of mesh.proto
+func (c *extAuthzClient) checkHTTP(ctx context.Context, target string, request *pb.CheckRequest) (*pb.CheckResponse, error) {
+	body, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// A denial is a CheckResponse too, any other status means the service failed
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxExtAuthzResponseSize))
+		return nil, fmt.Errorf("authorization service answered %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxExtAuthzResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.CheckResponse{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, response); err != nil {
+		return nil, fmt.Errorf("invalid CheckResponse: %w", err)
+	}
+	return response, nil
+}
+
+// What the service gets to see of the request
+func newCheckRequest(r *http.Request, rt *route, policy *extAuthzPolicy) *pb.CheckRequest {
+	request := &pb.CheckRequest{
+		Method: r.Method,
+		Path: r.URL.Path,
+		Query: r.URL.RawQuery,
+		Host: r.Host,
+		Principal: peerIdentity(r),
+		Route: rt.config.Name,
+	}
+	if request.Route == "" {
+		request.Route = rt.config.Path
+	}
+
+	if addr := clientAddr(r); addr.IsValid() {
+		request.ClientIp = addr.String()
+	}
+
+	if identity := IdentityFromContext(r.Context()); identity != nil {
+		request.Subject = identity.Subject
+		request.Scopes = identity.Scopes
+	}
+
+	// Sorted, so the service sees the same request the same way every time
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		if policy.headers == nil || policy.headers[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range r.Header[name] {
+			request.Headers = append(request.Headers, &pb.HeaderValue{Name: name, Value: value})
+		}
+	}
+
+	return request
+}
+
+// Ask the external authorization service of the route before going on
+// Allowed requests get the headers it returns, denied ones its response (or a 403)
+// When it can't decide, the route lets the request through or answers 503
+func extAuthzMiddleware(logger *logging.Logger, metrics *Metrics, client *extAuthzClient, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rt := routeFromContext(r.Context())
+		if rt == nil || rt.extAuthz == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy := rt.extAuthz
+
+		ctx, cancel := context.WithTimeout(r.Context(), policy.timeout)
+		response, err := client.check(ctx, policy, newCheckRequest(r, rt, policy))
+		cancel()
+
+		if err != nil {
+			logger.Warn("external authorization failed",
+				zap.String("path", r.URL.Path),
+				zap.Bool("fail_closed", policy.config.FailClosed),
+				zap.Error(err),
+				zap.String("trace_id", tracing.GetTraceID(r)),
+			)
+			metrics.RecordError(rt.service(), "ext_authz_unavailable")
+
+			if !policy.config.FailClosed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		if response.Allowed {
+			for _, header := range response.RequestHeaders {
+				r.Header.Set(header.Name, header.Value)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger.Warn("request denied by external authorization",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("trace_id", tracing.GetTraceID(r)),
+		)
+		metrics.RecordError(rt.service(), "ext_authz_denied")
+
+		writeDeniedResponse(w, r, response)
+	})
+}
+
+// Send the denial chosen by the authorization service
+func writeDeniedResponse(w http.ResponseWriter, r *http.Request, response *pb.CheckResponse) {
+	for _, header := range response.ResponseHeaders {
+		w.Header().Set(header.Name, header.Value)
+	}
+
+	denied := response.DeniedResponse
+	if denied == nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Not something to trust blindly: a broken status becomes a plain 403
+	status := int(denied.Status)
+	if status < 200 || status > 599 {
+		status = http.StatusForbidden
+	}
+
+	if denied.Body != "" {
+		contentType := denied.ContentType
+		if contentType == "" {
+			contentType = defaultDirectResponseContentType
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(denied.Body)))
+	}
+
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write([]byte(denied.Body))
+	}
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+func TestValidateCheckResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		response *pb.CheckResponse
+		wantErr bool
+	}{
+		{name: "plain header", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "X-User", Value: "alice"}}}},
+		{name: "tab in value", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "X-User", Value: "a\tb"}}}},
+		{name: "Host", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "host", Value: "internal"}}}, wantErr: true},
+		{name: "hop-by-hop", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "Connection", Value: "X-User"}}}, wantErr: true},
+		{name: "framing", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "Transfer-Encoding", Value: "chunked"}}}, wantErr: true},
+		{name: "empty name", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "", Value: "x"}}}, wantErr: true},
+		{name: "space in name", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "X User", Value: "x"}}}, wantErr: true},
+		{name: "colon in name", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "X-User:", Value: "x"}}}, wantErr: true},
+		{name: "newline in value", response: &pb.CheckResponse{Allowed: true, RequestHeaders: []*pb.HeaderValue{{Name: "X-User", Value: "alice\r\nX-Admin: true"}}}, wantErr: true},
+		{name: "bad denied response header", response: &pb.CheckResponse{ResponseHeaders: []*pb.HeaderValue{{Name: "Location", Value: "/\nSet-Cookie: a=b"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCheckResponse(tt.response); (err != nil) != tt.wantErr {
+				t.Fatalf("validateCheckResponse: error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtAuthzRefusesReservedHeaders(t *testing.T) {
+	var upstreamHost string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHost = r.Host
+	}))
+	defer backend.Close()
+
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := protojson.Marshal(&pb.CheckResponse{
+			Allowed: true,
+			RequestHeaders: []*pb.HeaderValue{{Name: "Host", Value: "internal.example.com"}},
+		})
+		w.Write(body)
+	}))
+	defer service.Close()
+
+	h := newTestHandler(t, &pb.ConfigUpdate{
+		Routes: []*pb.Route{{
+			Path: "/",
+			Backend: backend.URL,
+			ExtAuthz: &pb.ExtAuthz{HttpUrl: service.URL, FailClosed: true},
+		}},
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	if upstreamHost != "" {
+		t.Fatalf("request reached the backend with Host %q", upstreamHost)
+	}
+}
+
+func TestExtAuthzClientRetain(t *testing.T) {
+	client := newExtAuthzClient()
+	defer client.close()
+
+	for _, address := range []string{"authz-a:9191", "authz-b:9191"} {
+		if _, err := client.conn(address); err != nil {
+			t.Fatalf("conn(%q): %v", address, err)
+		}
+	}
+
+	client.retain(map[string]bool{"authz-b:9191": true})
+
+	if _, ok := client.conns["authz-a:9191"]; ok {
+		t.Error("connection to an address no route uses is still open")
+	}
+	if _, ok := client.conns["authz-b:9191"]; !ok {
+		t.Error("connection to an address still in use was closed")
+	}
+}
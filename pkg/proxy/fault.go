@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// Check the fault injection of a route
+func validateFaultInjection(config *pb.FaultInjection) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.Delay == nil && config.Abort == nil {
+		return fmt.Errorf("needs a delay or an abort")
+	}
+
+	if delay := config.Delay; delay != nil {
+		if delay.DelayMs <= 0 {
+			return fmt.Errorf("delay_ms must be positive")
+		}
+		if err := validatePercent(delay.GetPercent()); err != nil {
+			return fmt.Errorf("delay %w", err)
+		}
+	}
+
+	if abort := config.Abort; abort != nil {
+		if abort.Status < 200 || abort.Status > 599 {
+			return fmt.Errorf("abort status %d must be between 200 and 599", abort.Status)
+		}
+		if err := validatePercent(abort.GetPercent()); err != nil {
+			return fmt.Errorf("abort %w", err)
+		}
+	}
+
+	return nil
+}
+
+func validatePercent(percent float64) error {
+	if percent < 0 || percent > 100 || math.IsNaN(percent) {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// Share of the requests a fault applies to: unset is every request, so 0 can turn it off live
+func percentOrAll(percent *float64) float64 {
+	if percent == nil {
+		return 100
+	}
+	return *percent
+}
+
+// Whether this request is part of the given share
+func sampled(percent float64) bool {
+	return percent >= 100 || rand.Float64()*100 < percent
+}
+
+// Delay or abort requests as the route fault injection says
+// Faults come after authentication and rate limiting, so clients see them like a failing cluster
+func faultMiddleware(logger *logging.Logger, metrics *Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rt := routeFromContext(r.Context())
+		if rt == nil || rt.config.Fault == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fault := rt.config.Fault
+
+		if fault.Header != "" && r.Header.Get(fault.Header) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if delay := fault.Delay; delay != nil && sampled(percentOrAll(delay.Percent)) {
+			logger.Debug("injecting delay",
+				zap.String("path", r.URL.Path),
+				zap.Int32("delay_ms", delay.DelayMs),
+				zap.String("trace_id", tracing.GetTraceID(r)),
+			)
+			metrics.RecordError(rt.service(), "fault_delay")
+
+			timer := time.NewTimer(time.Duration(delay.DelayMs) * time.Millisecond)
+			select {
+			case <- timer.C:
+			case <- r.Context().Done():
+				// The client went away, nobody is waiting for an answer
+				timer.Stop()
+				return
+			}
+		}
+
+		if abort := fault.Abort; abort != nil && sampled(percentOrAll(abort.Percent)) {
+			logger.Debug("injecting abort",
+				zap.String("path", r.URL.Path),
+				zap.Int32("status", abort.Status),
+				zap.String("trace_id", tracing.GetTraceID(r)),
+			)
+			metrics.RecordError(rt.service(), "fault_abort")
+
+			http.Error(w, "Fault Injected", int(abort.Status))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
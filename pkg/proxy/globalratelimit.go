@@ -0,0 +1,391 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// How many times a request waits for a refill before giving up (other requests may take the tokens)
+const globalRateLimitRefills = 3
+
+// Prefetch never asks more than this share of the quota, so one proxy can't hold most of a small quota
+const maxPrefetchPercent = 10
+
+// How often leases with nothing left to remember get dropped
+const leaseSweepInterval = time.Minute
+
+// Returned when the rate limit service could not be asked
+var errRateLimitUnavailable = errors.New("rate limit service unavailable")
+
+// globalRateLimiter takes tokens of the global quotas from the rate limit service
+// Tokens are leased in batches and spent locally, so most requests never wait for the service.
+// Refills for all descriptors are collected for a short window and sent in one call
+type globalRateLimiter struct {
+	conn *grpc.ClientConn
+	client pb.RateLimitServiceClient
+	proxyID string
+	config *RateLimitServiceConfig
+	logger *logging.Logger
+
+	mu sync.Mutex
+	leases map[string]*quotaLease
+	pending []*quotaLease
+	lastSweep time.Time
+
+	// After a failed call the service is not asked again until this time
+	unavailableUntil time.Time
+}
+
+// Tokens of one descriptor held by this proxy
+type quotaLease struct {
+	descriptor []*pb.RateLimitEntry
+
+	tokens int
+	expires time.Time
+
+	// The quota is exhausted: reject locally until then
+	overUntil time.Time
+
+	// Last state of the quota, for the X-RateLimit-* headers
+	limit int
+	remaining int
+	reset time.Duration
+
+	// Requests waiting for the refill in flight (nil: no refill in flight)
+	waiters int
+	refill chan struct{}
+}
+
+// Outcome of a global rate limit check
+type globalRateLimitResult struct {
+	allowed bool
+	limit int
+	remaining int
+	retryAfter time.Duration
+	reset time.Duration
+}
+
+// Connect to the rate limit service (the connection is lazy, nothing is dialed yet)
+func newGlobalRateLimiter(config *RateLimitServiceConfig, proxyID string, dialOptions []grpc.DialOption, logger *logging.Logger) (*globalRateLimiter, error) {
+
+	conn, err := grpc.NewClient(config.Address, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create rate limit service client: %w", err)
+	}
+
+	return &globalRateLimiter{
+		conn: conn,
+		client: pb.NewRateLimitServiceClient(conn),
+		proxyID: proxyID,
+		config: config,
+		logger: logger.With(zap.String("rate_limit_service", config.Address)),
+		leases: make(map[string]*quotaLease),
+		lastSweep: time.Now(),
+	}, nil
+}
+
+func (g *globalRateLimiter) close() error {
+	return g.conn.Close()
+}
+
+// Take a token for the descriptor, asking the service for more when the lease is spent
+// Returns errRateLimitUnavailable when the service can't tell, the caller decides to fail open or closed
+func (g *globalRateLimiter) take(ctx context.Context, descriptor []*pb.RateLimitEntry) (globalRateLimitResult, error) {
+
+	key := descriptorKey(descriptor)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sweep(time.Now())
+
+	for refills := 0; ; refills++ {
+		now := time.Now()
+
+		lease, ok := g.leases[key]
+		if !ok {
+			lease = &quotaLease{descriptor: descriptor}
+			g.leases[key] = lease
+		}
+
+		// Spend a leased token
+		if lease.tokens > 0 && now.Before(lease.expires) {
+			lease.tokens--
+			return lease.result(true, now), nil
+		}
+
+		// The service said the quota is exhausted, no need to ask again yet
+		if now.Before(lease.overUntil) {
+			return lease.result(false, now), nil
+		}
+
+		if now.Before(g.unavailableUntil) {
+			return globalRateLimitResult{}, errRateLimitUnavailable
+		}
+
+		// Other requests took the refilled tokens every time
+		if refills == globalRateLimitRefills {
+			return lease.result(false, now), nil
+		}
+
+		// Join the refill in flight, or ask for one
+		if lease.refill == nil {
+			lease.refill = make(chan struct{})
+			g.pending = append(g.pending, lease)
+			if len(g.pending) == 1 {
+				time.AfterFunc(g.config.BatchWindow, g.flush)
+			}
+		}
+		lease.waiters++
+		refill := lease.refill
+
+		g.mu.Unlock()
+		select {
+		case <- refill:
+		case <- ctx.Done():
+		}
+		g.mu.Lock()
+
+		if ctx.Err() != nil {
+			// Gave up before the refill came back, don't ask tokens for this request
+			if lease.refill == refill {
+				lease.waiters--
+			}
+			return globalRateLimitResult{}, errRateLimitUnavailable
+		}
+	}
+}
+
+// Forget the leases with nothing left to remember, so the map doesn't keep every client (caller must hold the lock)
+func (g *globalRateLimiter) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < leaseSweepInterval {
+		return
+	}
+	g.lastSweep = now
+
+	for key, lease := range g.leases {
+		if lease.refill == nil && !now.Before(lease.expires) && !now.Before(lease.overUntil) {
+			delete(g.leases, key)
+		}
+	}
+}
+
+// Headers and decision from the last known quota state (caller must hold the lock)
+func (l *quotaLease) result(allowed bool, now time.Time) globalRateLimitResult {
+	result := globalRateLimitResult{
+		allowed: allowed,
+		limit: l.limit,
+		remaining: l.remaining + l.tokens,
+		reset: l.reset,
+	}
+	if !allowed {
+		result.retryAfter = l.overUntil.Sub(now)
+		if result.retryAfter <= 0 {
+			result.retryAfter = time.Second
+		}
+	}
+	return result
+}
+
+// Tokens to ask on top of the waiting requests (caller must hold the lock)
+// Unspent tokens are dropped when the lease expires, so the share of a small quota is capped
+func (g *globalRateLimiter) prefetch(lease *quotaLease) int {
+
+	// Never refilled: the limit of the quota is not known yet
+	if lease.expires.IsZero() {
+		return 0
+	}
+
+	// No quota for the descriptor, the service grants everything
+	if lease.limit == 0 {
+		return g.config.Prefetch
+	}
+
+	return min(g.config.Prefetch, lease.limit*maxPrefetchPercent/100)
+}
+
+// Send all the pending refills in one call
+func (g *globalRateLimiter) flush() {
+
+	g.mu.Lock()
+	batch := g.pending
+	g.pending = nil
+
+	req := &pb.RateLimitRequest{
+		ProxyId: g.proxyID,
+		Hits: make([]*pb.RateLimitHit, 0, len(batch)),
+	}
+	needed := make([]int, 0, len(batch))
+	for _, lease := range batch {
+		req.Hits = append(req.Hits, &pb.RateLimitHit{
+			Descriptor_: lease.descriptor,
+			Hits: int32(lease.waiters + g.prefetch(lease)),
+		})
+		needed = append(needed, lease.waiters)
+	}
+	g.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.config.Timeout)
+	resp, err := g.client.ShouldRateLimit(ctx, req)
+	cancel()
+
+	if err == nil && len(resp.Statuses) != len(req.Hits) {
+		err = fmt.Errorf("got %d statuses for %d descriptors", len(resp.Statuses), len(req.Hits))
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+
+	if err != nil {
+		g.unavailableUntil = now.Add(g.config.RetryInterval)
+		g.logger.Warn("rate limit service call failed",
+			zap.Error(err),
+			zap.Int("descriptors", len(batch)),
+			zap.Duration("retry_in", g.config.RetryInterval),
+		)
+	}
+
+	for i, lease := range batch {
+		if err == nil {
+			status := resp.Statuses[i]
+
+			// Leftovers of an expired lease are gone, they may have been given to others by now
+			if !now.Before(lease.expires) {
+				lease.tokens = 0
+			}
+			lease.tokens += int(status.Granted)
+			lease.expires = now.Add(g.config.LeaseTTL)
+			lease.limit = int(status.Limit)
+			lease.remaining = int(status.Remaining)
+			lease.reset = time.Duration(status.ResetMs) * time.Millisecond
+
+			// Not even a token for each waiting request: the quota is exhausted
+			// Falling short of the prefetch only means it is nearly spent
+			if int(status.Granted) < needed[i] {
+				lease.overUntil = now.Add(time.Duration(status.RetryAfterMs) * time.Millisecond)
+			}
+		}
+
+		lease.waiters = 0
+		close(lease.refill)
+		lease.refill = nil
+	}
+}
+
+// Entries of the descriptor for this request
+func globalDescriptor(config *pb.GlobalRateLimit, r *http.Request) []*pb.RateLimitEntry {
+	descriptor := make([]*pb.RateLimitEntry, 0, len(config.Descriptor_))
+	for _, entry := range config.Descriptor_ {
+		value := entry.Value
+		if value == "" {
+			value = requestKey(r, entry.Source, entry.Header)
+		}
+		descriptor = append(descriptor, &pb.RateLimitEntry{Key: entry.Key, Value: value})
+	}
+	return descriptor
+}
+
+// Take a token of the route global quota, answering 429 when it is exhausted
+// When the service can't be asked, the route decides: let the request through or answer 503
+func globalRateLimitMiddleware(logger *logging.Logger, metrics *Metrics, limiter *globalRateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rt := routeFromContext(r.Context())
+		if rt == nil || rt.config.GlobalRateLimit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		config := rt.config.GlobalRateLimit
+
+		// No service configured is the same as an unreachable one
+		result, err := globalRateLimitResult{}, errRateLimitUnavailable
+		if limiter != nil {
+			result, err = limiter.take(r.Context(), globalDescriptor(config, r))
+		}
+
+		if err != nil {
+			metrics.RecordError(rt.service(), "rate_limit_unavailable")
+
+			if !config.FailClosed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger.Warn("request rejected: rate limit service unavailable",
+				zap.String("path", r.URL.Path),
+				zap.String("trace_id", tracing.GetTraceID(r)),
+			)
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		// Quotas with a limit tell the client where it stands (they replace the local limit headers)
+		if result.limit > 0 {
+			w.Header().Set(RateLimitLimitHeader, strconv.Itoa(result.limit))
+			w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(result.remaining))
+			w.Header().Set(RateLimitResetHeader, strconv.Itoa(ceilSeconds(result.reset)))
+		}
+
+		if result.allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger.Warn("request rejected: global rate limit exceeded",
+			zap.String("path", r.URL.Path),
+			zap.String("trace_id", tracing.GetTraceID(r)),
+		)
+		metrics.RecordError(rt.service(), "rate_limited")
+
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.retryAfter)))
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	})
+}
+
+// Check the global rate limit of a route
+func validateGlobalRateLimit(config *pb.GlobalRateLimit, authRequired bool) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.Descriptor_) == 0 {
+		return fmt.Errorf("descriptor is empty")
+	}
+
+	for _, entry := range config.Descriptor_ {
+		if entry.Key == "" {
+			return fmt.Errorf("descriptor entry without key")
+		}
+		if entry.Value != "" {
+			continue
+		}
+		if err := validateRateLimitKey(entry.Source, entry.Header, authRequired); err != nil {
+			return fmt.Errorf("descriptor entry %q: %w", entry.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// Lease key of a descriptor, values are quoted so they can't fake another entry
+func descriptorKey(descriptor []*pb.RateLimitEntry) string {
+	parts := make([]string, 0, len(descriptor))
+	for _, entry := range descriptor {
+		parts = append(parts, entry.Key+"="+strconv.Quote(entry.Value))
+	}
+	return strings.Join(parts, ",")
+}
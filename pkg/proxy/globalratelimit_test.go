@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+// Rate limit service granting from a single quota, remembering what it was asked
+type fakeRateLimitService struct {
+	mu sync.Mutex
+	limit int32
+	remaining int32
+	asked []int32
+}
+
+func (s *fakeRateLimitService) ShouldRateLimit(ctx context.Context, in *pb.RateLimitRequest, opts ...grpc.CallOption) (*pb.RateLimitResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp := &pb.RateLimitResponse{}
+	for _, hit := range in.Hits {
+		s.asked = append(s.asked, hit.Hits)
+
+		granted := min(hit.Hits, s.remaining)
+		s.remaining -= granted
+		resp.Statuses = append(resp.Statuses, &pb.RateLimitStatus{
+			Granted: granted,
+			Limit: s.limit,
+			Remaining: s.remaining,
+			RetryAfterMs: 1000,
+		})
+	}
+	return resp, nil
+}
+
+func newTestGlobalRateLimiter(t *testing.T, service *fakeRateLimitService, prefetch int) *globalRateLimiter {
+	t.Helper()
+
+	return &globalRateLimiter{
+		client: service,
+		config: &RateLimitServiceConfig{
+			Timeout: time.Second,
+			BatchWindow: time.Millisecond,
+			Prefetch: prefetch,
+			LeaseTTL: time.Minute,
+			RetryInterval: time.Second,
+		},
+		logger: newTestLogger(t),
+		leases: make(map[string]*quotaLease),
+	}
+}
+
+func TestGlobalRateLimiterPrefetch(t *testing.T) {
+	tests := []struct {
+		name string
+		limit int32
+		wantAsked []int32
+	}{
+		// The first refill doesn't know the quota yet, the later ones ask a share of it
+		{name: "large quota", limit: 1000, wantAsked: []int32{1, 11}},
+		{name: "small quota", limit: 20, wantAsked: []int32{1, 3}},
+		{name: "tiny quota", limit: 5, wantAsked: []int32{1, 1}},
+	}
+
+	descriptor := []*pb.RateLimitEntry{{Key: "tenant", Value: "acme"}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeRateLimitService{limit: tt.limit, remaining: tt.limit}
+			limiter := newTestGlobalRateLimiter(t, service, 10)
+
+			for i := 0; i < 2; i++ {
+				result, err := limiter.take(context.Background(), descriptor)
+				if err != nil || !result.allowed {
+					t.Fatalf("take %d: allowed = %v, error = %v", i, result.allowed, err)
+				}
+			}
+
+			service.mu.Lock()
+			defer service.mu.Unlock()
+			if len(service.asked) != len(tt.wantAsked) {
+				t.Fatalf("asked %v, want %v", service.asked, tt.wantAsked)
+			}
+			for i := range tt.wantAsked {
+				if service.asked[i] != tt.wantAsked[i] {
+					t.Fatalf("asked %v, want %v", service.asked, tt.wantAsked)
+				}
+			}
+		})
+	}
+}
+
+func TestGlobalRateLimiterShortPrefetchIsNotOverQuota(t *testing.T) {
+	service := &fakeRateLimitService{limit: 1000, remaining: 3}
+	limiter := newTestGlobalRateLimiter(t, service, 10)
+	descriptor := []*pb.RateLimitEntry{{Key: "tenant", Value: "acme"}}
+
+	// The second refill asks 11 and gets 2: enough for the request, only the prefetch goes short
+	for i := 0; i < 3; i++ {
+		result, err := limiter.take(context.Background(), descriptor)
+		if err != nil || !result.allowed {
+			t.Fatalf("take %d: allowed = %v, error = %v", i, result.allowed, err)
+		}
+	}
+
+	// Nothing left: now the quota is exhausted
+	result, err := limiter.take(context.Background(), descriptor)
+	if err != nil {
+		t.Fatalf("take: %v", err)
+	}
+	if result.allowed || result.retryAfter <= 0 {
+		t.Fatalf("take on an exhausted quota: allowed = %v, retry after %v", result.allowed, result.retryAfter)
+	}
+}
@@ -1,74 +1,618 @@
 package proxy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	pb "github.com/SimonePesci/gomesh/api/proto"
 	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
-// Proxy struct, a reverse proxy reference and a config reference
+// Status of requests whose client went away before the answer (nginx's "Client Closed Request")
+// Nobody reads it, it keeps these requests apart from upstream failures in logs and metrics
+const statusClientClosedRequest = 499
+
+// Proxy struct, a config reference and the live routing table
 type Handler struct {
 	config *Config
+	logger *logging.Logger
+	metrics *Metrics
+
+	// Credentials from the YAML file, used when the control plane sends none
+	localAuth *pb.AuthConfig
+
+	// Swapped atomically on every config update, readers never block
+	routes atomic.Pointer[RouteTable]
+
+	// Serializes config updates (the initial one and the control plane ones)
+	applyMu sync.Mutex
+
+	// Signalled (without blocking) when an endpoint changes health
+	healthChanged chan struct{}
+
+	// Per-route middlewares, ending with the actual forwarding
+	pipeline http.Handler
+
+	// Shared by all routes: the target endpoint is read from the request context
 	reverseProxy *httputil.ReverseProxy
+
+	// Client of the global rate limit service (nil when no address is configured)
+	globalLimiter *globalRateLimiter
+
+	// Sends the shadow copies of mirrored routes
+	mirrors *mirrorSender
+
+	// Certificates of the listener (nil when TLS is disabled)
+	certificates *certStore
+
+	// Certificate issued by the control plane, for mTLS with the mesh clusters and the other proxies
+	workload *workloadIdentity
+
+	// Connections to the mesh clusters, shared by forwarding, mirroring and health checks
+	meshTransport *meshTransport
+
+	// Picks the mesh transport for the endpoints of mesh clusters
+	transport *upstreamTransport
+
+	// Clients accepted by the listener (nil: all of them)
+	listenerAccess *ipAccessList
+
+	// Proxies in front of the listener, whose X-Forwarded-For tells the client address
+	trustedProxies trustedProxies
+
+	// Asks the external authorization services of the routes with ext_authz
+	extAuthz *extAuthzClient
 }
 
 // Builds a new Handler
-func NewHandler(config *Config, logger *logging.Logger) (*Handler, error) {
+func NewHandler(config *Config, logger *logging.Logger, metrics *Metrics) (*Handler, error) {
 
-	// Parse the Backend URL from Config file
-	rawBackendURL := config.GetBackendURL()
-	backendURL, err := url.Parse(rawBackendURL)
+	localAuth, err := loadLocalAuth(&config.Proxy.Auth)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse backend URL, is it written correctly?")
+		return nil, fmt.Errorf("Failed to load auth configuration: %w", err)
 	}
 
+	workload := &workloadIdentity{}
+	meshTransport := newMeshTransport(workload)
+
+	trusted, err := parsePrefixes(config.Proxy.AccessControl.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid trusted proxies: %w", err)
+	}
+
+	listenerAccess, err := newIPAccessList(config.Proxy.AccessControl.Allow, config.Proxy.AccessControl.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid listener access control: %w", err)
+	}
+
+	handler := &Handler{
+		config: config,
+		logger: logger,
+		metrics: metrics,
+		localAuth: localAuth,
+		reverseProxy: newReverseProxy(logger, metrics),
+		mirrors: newMirrorSender(logger, metrics, meshTransport),
+		healthChanged: make(chan struct{}, 1),
+		workload: workload,
+		meshTransport: meshTransport,
+		transport: newUpstreamTransport(http.DefaultTransport, meshTransport),
+		listenerAccess: listenerAccess,
+		trustedProxies: trusted,
+		extAuthz: newExtAuthzClient(),
+	}
+	handler.reverseProxy.Transport = handler.transport
+
+	if config.Proxy.RateLimitService.Address != "" {
+		// The control plane wants the same credentials for the rate limit service it hosts
+		dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		if config.Proxy.RateLimitService.Address == config.Proxy.ControlPlane.Address {
+			dialOptions, err = controlPlaneDialOptions(&config.Proxy.ControlPlane)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		handler.globalLimiter, err = newGlobalRateLimiter(&config.Proxy.RateLimitService, config.Proxy.ControlPlane.ProxyID, dialOptions, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Proxy.TLS.Enabled {
+		handler.certificates, err = newCertStore(&config.Proxy.TLS, logger)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load TLS certificates: %w", err)
+		}
+		handler.certificates.start()
+	}
+
+	// These run after routing, so they can read the matched route from the request context
+	// IP access -> Authorization -> Auth -> External authorization -> Rate limit -> Global rate limit -> Fault injection -> Forward
+	handler.pipeline = Chain(
+		http.HandlerFunc(handler.forward),
+		func(h http.Handler) http.Handler { return ipAccessMiddleware(logger, metrics, h)},
+		func(h http.Handler) http.Handler { return authzMiddleware(logger, metrics, h)},
+		func(h http.Handler) http.Handler { return AuthMiddleware(logger, metrics, h)},
+		func(h http.Handler) http.Handler { return extAuthzMiddleware(logger, metrics, handler.extAuthz, h)},
+		func(h http.Handler) http.Handler { return RateLimitMiddleware(logger, metrics, h)},
+		func(h http.Handler) http.Handler { return globalRateLimitMiddleware(logger, metrics, handler.globalLimiter, h)},
+		func(h http.Handler) http.Handler { return faultMiddleware(logger, metrics, h)},
+	)
+
+	// Until the control plane sends its routes, forward everything to the backend from the Config file
+	initialConfig := &pb.ConfigUpdate{
+		Version: 0,
+		Routes: []*pb.Route{
+			{
+				Path: "/",
+				Backend: config.GetBackendURL(),
+			},
+		},
+	}
+
+	if err := handler.ApplyConfig(initialConfig); err != nil {
+		return nil, fmt.Errorf("Failed to parse backend URL, is it written correctly? %w", err)
+	}
+
+	return handler, nil
+
+}
+
+// ApplyConfig replaces the routing table with the one described by the update
+// If the update is invalid, the current table keeps serving traffic
+func (h *Handler) ApplyConfig(update *pb.ConfigUpdate) error {
+
+	h.applyMu.Lock()
+	defer h.applyMu.Unlock()
+
+	previous := h.routes.Load()
+
+	table, err := newRouteTable(update, h.localAuth, previous)
+	if err != nil {
+		return fmt.Errorf("Rejected config version %d: %w", update.Version, err)
+	}
+
+	// Parsed before anything changes, so a broken certificate rejects the whole update
+	var certificates []*servedCert
+	if h.certificates != nil {
+		certificates, err = parsePushedCertificates(update.Certificates)
+		if err != nil {
+			return fmt.Errorf("Rejected config version %d: %w", update.Version, err)
+		}
+	} else if len(update.Certificates) > 0 {
+		h.logger.Warn("ignoring the certificates of the config update, TLS is disabled",
+			zap.Int64("version", update.Version),
+		)
+	}
+
+	workloadCert, err := parseWorkloadCertificate(update.WorkloadCertificate)
+	if err != nil {
+		return fmt.Errorf("Rejected config version %d: %w", update.Version, err)
+	}
+
+	// The new certificate must be in place before mesh clusters get traffic
+	if workloadCert != nil {
+		h.workload.set(workloadCert)
+
+		// Pooled connections keep the old certificate, new ones pick up the renewed one
+		h.meshTransport.CloseIdleConnections()
+
+		h.logger.Info("workload certificate updated",
+			zap.String("identity", workloadCert.identity()),
+			zap.Time("expires_at", workloadCert.cert.Leaf.NotAfter),
+		)
+	}
+
+	// Start the new (or changed) clusters before they get traffic
+	for name, cluster := range table.clusters {
+		if previous == nil || previous.clusters[name] != cluster {
+			cluster.start(h.logger, h.metrics, h.notifyHealthChanged, h.transport)
+		}
+	}
+
+	h.routes.Store(table)
+
+	if h.certificates != nil {
+		h.certificates.setPushed(certificates)
+	}
+
+	// Stop the clusters that are gone (or were replaced)
+	if previous != nil {
+		for name, cluster := range previous.clusters {
+			if table.clusters[name] != cluster {
+				cluster.stop(h.metrics)
+			}
+		}
+	}
+
+	// Close the connections to the authorization services no route asks anymore
+	h.extAuthz.retain(table.extAuthzAddresses())
+
+	// Connections to identities no mesh cluster expects anymore are not needed
+	identities := make(map[string]bool)
+	for _, cluster := range table.clusters {
+		if cluster.config.Mtls {
+			identities[cluster.config.Identity] = true
+		}
+	}
+	h.meshTransport.retain(identities)
+
+	// A replaced cluster shares its labels with its new version: export after stopping
+	for _, cluster := range table.clusters {
+		cluster.exportState(h.metrics)
+	}
+
+	// The set of endpoints may have changed
+	h.notifyHealthChanged()
+
+	h.logger.Info("routing table updated",
+		zap.Int64("version", table.version),
+		zap.Int("exact_routes", table.exactRoutes()),
+		zap.Int("prefix_routes", len(table.prefixes)),
+		zap.Int("clusters", len(table.clusters)),
+	)
+
+	return nil
+}
+
+// Close releases the connections the handler holds (in-flight requests must be done)
+func (h *Handler) Close() error {
+	if h.certificates != nil {
+		h.certificates.close()
+	}
+	h.extAuthz.close()
+	if h.globalLimiter != nil {
+		return h.globalLimiter.close()
+	}
+	return nil
+}
+
+// ConfigVersion returns the version of the routing table currently serving traffic
+func (h *Handler) ConfigVersion() int64 {
+	return h.routes.Load().version
+}
+
+
+// EndpointHealth returns the health of every endpoint in the live route table
+func (h *Handler) EndpointHealth() []*pb.EndpointHealth {
+	return h.routes.Load().endpointHealth()
+}
+
+// HealthChanges is signalled when the health of an endpoint may have changed
+// Signals are coalesced: readers should look at EndpointHealth() for the current state
+func (h *Handler) HealthChanges() <-chan struct{} {
+	return h.healthChanged
+}
+
+func (h *Handler) notifyHealthChanged() {
+	select {
+	case h.healthChanged <- struct{}{}:
+	default:
+		// A notification is already pending
+	}
+}
+
+// Serve through the reverse Proxy
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Everything after this sees the same client address (rate limits, hashing, access lists, headers)
+	client := h.trustedProxies.client(r)
+	r = r.WithContext(withClient(r.Context(), client))
+
+	// Listener access control: denied clients don't even learn which routes exist
+	if h.listenerAccess != nil && !h.listenerAccess.allows(client.addr) {
+		// Not routed yet: same service label as the request metrics of unrouted requests
+		denyByIP(w, r, h.logger, h.metrics, "unknown", "listener", client.addr)
+		return
+	}
+
+	// Routing: pick the route for this request (path, then method, host, headers and query)
+	rt := h.routes.Load().match(r)
+	if rt == nil {
+		traceID := tracing.GetTraceID(r)
+
+		h.logger.Warn("no route found",
+			zap.String("path", r.URL.Path),
+			zap.String("trace_id", traceID),
+		)
+
+		http.Error(w, fmt.Sprintf("No route found for path %s (trace_id: %s)", r.URL.Path, traceID), http.StatusNotFound)
+		return
+	}
+
+	// Traffic split: the rest of the pipeline only sees the backend picked for this request
+	if rt.split != nil {
+		var cookie *http.Cookie
+		rt, cookie = rt.split.pick(r)
+		if cookie != nil {
+			r = r.WithContext(withAffinityCookie(r.Context(), cookie))
+		}
+	}
+
+	if labels := requestLabelsFromContext(r.Context()); labels != nil {
+		labels.service = rt.service()
+		labels.version = rt.version
+	}
+
+	h.pipeline.ServeHTTP(w, r.WithContext(withRoute(r.Context(), rt)))
+}
+
+// Last step of the pipeline: send the request to the route backend (or answer it, for redirects and direct responses)
+func (h *Handler) forward(w http.ResponseWriter, r *http.Request) {
+
+	rt := routeFromContext(r.Context())
+
+	// The request passed every check: the client can keep the cluster the split picked
+	if cookie := affinityCookieFromContext(r.Context()); cookie != nil {
+		http.SetCookie(w, cookie)
+	}
+
+	// Redirects and direct responses never reach a backend
+	if rt.cluster == nil {
+		h.respondLocally(w, r, rt)
+		return
+	}
+
+	// Mirroring: a copy goes to the shadow cluster in the background, whatever happens to this request
+	if rt.mirror != nil {
+		if err := h.mirrors.mirror(r, rt); err != nil {
+			h.logger.Warn("failed to read request body",
+				zap.Error(err),
+				zap.String("trace_id", tracing.GetTraceID(r)),
+			)
+			h.metrics.RecordError(rt.service(), "bad_request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Apply the route timeout (and any budget left by an upstream hop)
+	ctx, cancel := withRequestTimeout(r, rt.config.TimeoutMs)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	// Circuit breaking: the cluster may be failing or already at its concurrency limit
+	if rt.cluster.breaker != nil {
+		release, err := rt.cluster.breaker.acquire(ctx)
+		if err != nil {
+			h.rejectByBreaker(w, r, rt, err)
+			return
+		}
+
+		// Only the answers of the endpoints count, not clients going away or the 503s of the proxy itself
+		outcome := new(breakerOutcome)
+		r = r.WithContext(withBreakerOutcome(r.Context(), outcome))
+		defer func() {
+			release(*outcome)
+		}()
+	}
+
+	// Sticky routing: the ring hash balancer reads the key from the context
+	if rt.config.LbPolicy == pb.LoadBalancerPolicy_RING_HASH {
+		if key, cookie, ok := requestHashKey(rt.config.HashPolicy, r); ok {
+			if cookie != nil {
+				http.SetCookie(w, cookie)
+			}
+			r = r.WithContext(withHashKey(r.Context(), key))
+		}
+	}
+
+	// Active requests size the retry budget of the cluster
+	rt.cluster.retryBudget.active.Add(1)
+	defer rt.cluster.retryBudget.active.Add(-1)
+
+	if rt.retry != nil {
+		h.forwardWithRetries(w, r, rt)
+		return
+	}
+
+	endpoint := h.pickEndpoint(w, r, rt)
+	if endpoint == nil {
+		return
+	}
+
+	h.send(w, r, rt, endpoint)
+}
+
+// Load balancing: pick the endpoint of the route cluster
+// When none is available the request is answered with 503 and nil is returned
+func (h *Handler) pickEndpoint(w http.ResponseWriter, r *http.Request, rt *route) *Endpoint {
+	endpoint := rt.balancer.Pick(r)
+	if endpoint == nil {
+		h.logger.Error("no endpoint available",
+			zap.String("cluster", rt.cluster.name),
+			zap.String("trace_id", tracing.GetTraceID(r)),
+		)
+		h.metrics.RecordError(rt.service(), "no_endpoint")
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	}
+	return endpoint
+}
+
+// Send the request to one endpoint through the reverse proxy
+func (h *Handler) send(w http.ResponseWriter, r *http.Request, rt *route, endpoint *Endpoint) {
+
+	endpoint.activeRequests.Add(1)
+	defer endpoint.activeRequests.Add(-1)
+
+	outcome := breakerOutcomeFromContext(r.Context())
+	if rt.cluster.outlier == nil && outcome == nil {
+		h.reverseProxy.ServeHTTP(w, r.WithContext(withEndpoint(r.Context(), endpoint)))
+		return
+	}
+
+	// Outlier detection and circuit breaking: judge the endpoint on the response, including the ErrorHandler ones
+	recorder := newResponseWriter(w)
+	start := time.Now()
+
+	h.reverseProxy.ServeHTTP(recorder, r.WithContext(withEndpoint(r.Context(), endpoint)))
+
+	// A client that went away says nothing about the endpoint
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		if outcome != nil {
+			*outcome = outcomeUnknown
+		}
+		return
+	}
+
+	// The last attempt decides for the breaker
+	if outcome != nil {
+		*outcome = outcomeSuccess
+		if recorder.statusCode >= 500 {
+			*outcome = outcomeUpstreamFailure
+		}
+	}
+
+	if rt.cluster.outlier != nil {
+		rt.cluster.outlier.record(endpoint, recorder.statusCode, time.Since(start))
+	}
+}
+
+// Answer 503 for a request the circuit breaker didn't let through
+func (h *Handler) rejectByBreaker(w http.ResponseWriter, r *http.Request, rt *route, err error) {
+
+	errorType := "circuit_open"
+	if errors.Is(err, errCircuitOverflow) {
+		errorType = "circuit_overflow"
+	}
+
+	// The client went away (or the budget ran out) while waiting for a slot
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		errorType = "circuit_pending_timeout"
+	}
+
+	h.logger.Warn("request rejected by circuit breaker",
+		zap.String("cluster", rt.cluster.name),
+		zap.String("reason", err.Error()),
+		zap.String("trace_id", tracing.GetTraceID(r)),
+	)
+	h.metrics.RecordError(rt.service(), errorType)
+	http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+}
+
+// Create the reverse proxy that forwards to the endpoint stored in the request context
+func newReverseProxy(logger *logging.Logger, metrics *Metrics) *httputil.ReverseProxy {
+
 	// Create a new reverse proxy from the builtin Go lib (it copies headers and streams)
-	reverseProxy := httputil.NewSingleHostReverseProxy(backendURL)
+	reverseProxy := &httputil.ReverseProxy{}
 
 	// Customize proxy to handle errors differently
 	reverseProxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		logger.Error("proxy error",
-			zap.Error(err),
-			zap.String("url", r.URL.Path),
-		)
-		http.Error(w, "Gateway Error", http.StatusBadGateway)
+		traceID := tracing.GetTraceID(r)
+		rt := routeFromContext(r.Context())
+		endpoint := endpointFromContext(r.Context())
+
+		// Tell the retry loop why the attempt failed
+		attempt := 1
+		if state := attemptFromContext(r.Context()); state != nil {
+			state.err = err
+			attempt = state.number
+		}
+
+		// Writing the answer tells the retry loop whether there is another attempt
+		// Retried attempts show up in gomesh_retries_total, only the final answer is an error
+		recordError := func(errorType string) {
+			if state := attemptFromContext(r.Context()); state == nil || !state.retried {
+				metrics.RecordError(rt.service(), errorType)
+			}
+		}
+
+		switch {
+		// The client went away, nobody is left to answer
+		case errors.Is(r.Context().Err(), context.Canceled):
+			logger.Debug("client canceled request",
+				zap.String("url", r.URL.Path),
+				zap.String("backend_url", endpoint.url.String()),
+				zap.Int("attempt", attempt),
+				zap.String("trace_id", traceID),
+			)
+			w.WriteHeader(statusClientClosedRequest)
+			recordError("client_canceled")
+
+		// The route deadline fired before the backend answered
+		case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+			logger.Warn("upstream request timed out",
+				zap.Error(err),
+				zap.String("url", r.URL.Path),
+				zap.String("backend_url", endpoint.url.String()),
+				zap.Int("attempt", attempt),
+				zap.String("trace_id", traceID),
+			)
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+			recordError("timeout")
+
+		default:
+			logger.Error("proxy error",
+				zap.Error(err),
+				zap.String("url", r.URL.Path),
+				zap.String("backend_url", endpoint.url.String()),
+				zap.Int("attempt", attempt),
+				zap.String("trace_id", traceID),
+			)
+			http.Error(w, "Gateway Error", http.StatusBadGateway)
+			recordError("bad_gateway")
+		}
+	}
+
+	// Header policies of the route on the upstream response (local replies like 404 or 502 are left alone)
+	reverseProxy.ModifyResponse = func(resp *http.Response) error {
+		rt := routeFromContext(resp.Request.Context())
+		if len(rt.responseHeaders) > 0 {
+			vars := newHeaderVars(resp.Request, rt, endpointFromContext(resp.Request.Context()))
+			applyHeaderPolicies(rt.responseHeaders, resp.Header, vars)
+		}
+		return nil
 	}
 
 	// Modify outgoing requests to backend
-	originalDirector := reverseProxy.Director
 	reverseProxy.Director = func(req *http.Request) {
 
-		originalDirector(req)
+		rt := routeFromContext(req.Context())
+
+		// Forwarded headers describe the request as the client sent it, before any rewrite
+		setForwardedHeaders(req)
+
+		// Point the request to the picked endpoint, the path stays the same unless the route rewrites it
+		endpoint := endpointFromContext(req.Context())
+		req.URL.Scheme = endpoint.url.Scheme
+		req.URL.Host = endpoint.url.Host
+
+		if rt.rewrite != nil {
+			rt.rewrite.apply(req, endpoint)
+		}
 
-		req.Header.Set("X-Forwarded-By", "GoMesh-Proxy")
+		// Explicitly disable the Go default User-Agent (same as NewSingleHostReverseProxy)
+		if _, ok := req.Header["User-Agent"]; !ok {
+			req.Header.Set("User-Agent", "")
+		}
+
+		if len(rt.requestHeaders) > 0 {
+			applyHeaderPolicies(rt.requestHeaders, req.Header, newHeaderVars(req, rt, endpoint))
+
+			// The reverse proxy adds X-Forwarded-For after us, unless the header is there with no value
+			if removesHeader(rt.requestHeaders, "X-Forwarded-For") && req.Header.Get("X-Forwarded-For") == "" {
+				req.Header["X-Forwarded-For"] = nil
+			}
+		}
+
+		// Tell the backend how much time it has left
+		setTimeoutHeader(req)
 
 		logger.Info("forwarding request",
 			zap.String("method", req.Method),
 			zap.String("url", req.URL.String()),
-			zap.String("backend_url", backendURL.String()),
+			zap.String("backend_url", endpoint.url.String()),
 		)
 	}
 
-
-	return &Handler{
-		config: config,
-		reverseProxy: reverseProxy,
-	}, nil
-
+	return reverseProxy
 }
-
-
-// Serve through the reverse Proxy
-func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// TODO: To implement later
-	// routing logic
-	// load balancing
-	// Circuit Breaking 
-	// Rate limiting
-
-	h.reverseProxy.ServeHTTP(w, r)
-}
\ No newline at end of file
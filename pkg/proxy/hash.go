@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/cespare/xxhash/v2"
+)
+
+// Points each endpoint gets on the ring
+// More points spread the keys more evenly, at the cost of a bigger ring
+const ringPointsPerEndpoint = 160
+
+// Ring hash: the key and the endpoints are hashed on the same ring,
+// a key goes to the first endpoint point after it
+// Adding or removing an endpoint only moves the keys next to its points
+type ringHashBalancer struct {
+	endpoints []*Endpoint
+	points []ringPoint
+}
+
+type ringPoint struct {
+	hash uint64
+	endpoint *Endpoint
+}
+
+// Build the ring over the cluster endpoints
+// Points only depend on the endpoint address, so every proxy builds the same ring
+func newRingHashBalancer(cluster *Cluster) *ringHashBalancer {
+
+	balancer := &ringHashBalancer{
+		endpoints: cluster.endpoints,
+		points: make([]ringPoint, 0, len(cluster.endpoints)*ringPointsPerEndpoint),
+	}
+
+	for _, endpoint := range cluster.endpoints {
+		for i := 0; i < ringPointsPerEndpoint; i++ {
+			balancer.points = append(balancer.points, ringPoint{
+				hash: xxhash.Sum64String(endpoint.address + "_" + strconv.Itoa(i)),
+				endpoint: endpoint,
+			})
+		}
+	}
+
+	sort.Slice(balancer.points, func(i, j int) bool {
+		return balancer.points[i].hash < balancer.points[j].hash
+	})
+
+	return balancer
+}
+
+func (b *ringHashBalancer) Pick(r *http.Request) *Endpoint {
+	if len(b.points) == 0 {
+		return nil
+	}
+
+	// Without a key there is nothing to be sticky to
+	key, ok := hashKeyFromContext(r.Context())
+	if !ok {
+		endpoints := availableEndpoints(b.endpoints)
+		if len(endpoints) == 0 {
+			return nil
+		}
+		return endpoints[rand.IntN(len(endpoints))]
+	}
+
+	// First point at or after the key hash, wrapping around the ring
+	hash := xxhash.Sum64String(key)
+	start := sort.Search(len(b.points), func(i int) bool {
+		return b.points[i].hash >= hash
+	})
+
+	// Keep walking the ring past unavailable endpoints,
+	// only the keys of the missing endpoint move
+	for i := 0; i < len(b.points); i++ {
+		point := b.points[(start+i)%len(b.points)]
+		if point.endpoint.Available() {
+			return point.endpoint
+		}
+	}
+
+	return nil
+}
+
+// Check a hash policy before it's used on a route
+func validateHashPolicy(policy *pb.HashPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	switch policy.Key {
+	case pb.HashKey_HASH_KEY_CLIENT_IP:
+	case pb.HashKey_HASH_KEY_HEADER, pb.HashKey_HASH_KEY_COOKIE:
+		if policy.Name == "" {
+			return fmt.Errorf("hash policy on %v needs a name", policy.Key)
+		}
+	default:
+		return fmt.Errorf("unknown hash key %v", policy.Key)
+	}
+
+	if policy.IssueCookie && policy.Key != pb.HashKey_HASH_KEY_COOKIE {
+		return fmt.Errorf("issue_cookie is only valid with HASH_KEY_COOKIE")
+	}
+
+	if policy.CookieTtlSeconds < 0 {
+		return fmt.Errorf("cookie_ttl_seconds must not be negative")
+	}
+
+	return nil
+}
+
+// Extract the hashing key of a request according to the policy (nil policy means client IP)
+// When the policy issues cookies and the request has none, a new affinity cookie is returned for the response
+// and its value is used as the key, so the first request already lands where the next ones will
+func requestHashKey(policy *pb.HashPolicy, r *http.Request) (string, *http.Cookie, bool) {
+
+	if policy == nil {
+		return clientIP(r), nil, true
+	}
+
+	switch policy.Key {
+	case pb.HashKey_HASH_KEY_HEADER:
+		value := r.Header.Get(policy.Name)
+		return value, nil, value != ""
+
+	case pb.HashKey_HASH_KEY_COOKIE:
+		if cookie, err := r.Cookie(policy.Name); err == nil && cookie.Value != "" {
+			return cookie.Value, nil, true
+		}
+
+		// Already issued for this request (by the traffic split), the balancer must use the same value
+		if cookie := affinityCookieFromContext(r.Context()); cookie != nil && cookie.Name == policy.Name {
+			return cookie.Value, nil, true
+		}
+
+		if !policy.IssueCookie {
+			return "", nil, false
+		}
+
+		value, err := newAffinityCookieValue()
+		if err != nil {
+			return "", nil, false
+		}
+
+		return value, &http.Cookie{
+			Name: policy.Name,
+			Value: value,
+			Path: "/",
+			MaxAge: int(policy.CookieTtlSeconds),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		}, true
+
+	default:
+		return clientIP(r), nil, true
+	}
+}
+
+// Random value for a proxy issued affinity cookie
+func newAffinityCookieValue() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := cryptorand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
@@ -0,0 +1,298 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+)
+
+// Variables header values can use, replaced per request
+var headerVariables = map[string]func(vars *headerVars) string{
+	"TRACE_ID": func(vars *headerVars) string { return vars.traceID },
+	"CLIENT_IP": func(vars *headerVars) string { return vars.clientIP },
+	"ROUTE": func(vars *headerVars) string { return vars.route },
+	"CLUSTER": func(vars *headerVars) string { return vars.cluster },
+	"UPSTREAM_ADDRESS": func(vars *headerVars) string { return vars.upstream },
+}
+
+// Global request header policy when the config has none, an empty policy turns it off
+var defaultRequestHeaders = &pb.HeaderPolicy{
+	Set: []*pb.HeaderValue{{Name: "X-Forwarded-By", Value: "GoMesh-Proxy"}},
+}
+
+// Values of the variables for one request
+type headerVars struct {
+	traceID string
+	clientIP string
+	route string
+	cluster string
+	upstream string
+}
+
+// Variables of a request sent by rt to endpoint (nil when not picked yet)
+func newHeaderVars(r *http.Request, rt *route, endpoint *Endpoint) *headerVars {
+	vars := &headerVars{
+		traceID: tracing.GetTraceID(r),
+		clientIP: clientIP(r),
+		route: rt.config.Name,
+		cluster: rt.service(),
+	}
+	if vars.route == "" {
+		vars.route = rt.config.Path
+	}
+	if endpoint != nil {
+		vars.upstream = endpoint.url.Host
+	}
+	return vars
+}
+
+// headerPolicy is the validated form of a HeaderPolicy
+type headerPolicy struct {
+	set []headerValue
+	append []headerValue
+	add []headerValue
+	remove []string
+}
+
+type headerValue struct {
+	name string
+
+	// The value split on its variables: literal, variable, literal, variable...
+	parts []string
+}
+
+// Build a header policy (nil when there is nothing to change)
+func newHeaderPolicy(config *pb.HeaderPolicy) (*headerPolicy, error) {
+	if config == nil || len(config.Set)+len(config.Append)+len(config.Add)+len(config.Remove) == 0 {
+		return nil, nil
+	}
+
+	policy := &headerPolicy{}
+	var err error
+
+	if policy.set, err = newHeaderValues(config.Set); err != nil {
+		return nil, fmt.Errorf("set: %w", err)
+	}
+	if policy.append, err = newHeaderValues(config.Append); err != nil {
+		return nil, fmt.Errorf("append: %w", err)
+	}
+	if policy.add, err = newHeaderValues(config.Add); err != nil {
+		return nil, fmt.Errorf("add: %w", err)
+	}
+
+	for _, name := range config.Remove {
+		if err := validateHeaderName(name); err != nil {
+			return nil, fmt.Errorf("remove: %w", err)
+		}
+		policy.remove = append(policy.remove, http.CanonicalHeaderKey(name))
+	}
+
+	return policy, nil
+}
+
+// Policies to apply in order, without the nil ones
+func headerPolicies(policies ...*headerPolicy) []*headerPolicy {
+	var nonNil []*headerPolicy
+	for _, p := range policies {
+		if p != nil {
+			nonNil = append(nonNil, p)
+		}
+	}
+	return nonNil
+}
+
+func newHeaderValues(configs []*pb.HeaderValue) ([]headerValue, error) {
+	values := make([]headerValue, 0, len(configs))
+	for _, config := range configs {
+		if err := validateHeaderName(config.Name); err != nil {
+			return nil, err
+		}
+
+		parts, err := parseHeaderValue(config.Value)
+		if err != nil {
+			return nil, fmt.Errorf("header %q: %w", config.Name, err)
+		}
+
+		values = append(values, headerValue{name: http.CanonicalHeaderKey(config.Name), parts: parts})
+	}
+	return values, nil
+}
+
+// Host is not a header for Go, it has its own rewrite options
+func validateHeaderName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	if strings.EqualFold(name, "Host") {
+		return fmt.Errorf("Host can't be changed by a header policy, use host_rewrite")
+	}
+	return nil
+}
+
+// Split a value on its %VARIABLES%, checking they exist
+func parseHeaderValue(value string) ([]string, error) {
+	parts := []string{}
+	literal := strings.Builder{}
+
+	for {
+		start := strings.IndexByte(value, '%')
+		if start < 0 {
+			literal.WriteString(value)
+			break
+		}
+		literal.WriteString(value[:start])
+		value = value[start+1:]
+
+		end := strings.IndexByte(value, '%')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated variable")
+		}
+
+		name := value[:end]
+		value = value[end+1:]
+
+		// %% is a literal %
+		if name == "" {
+			literal.WriteByte('%')
+			continue
+		}
+
+		if _, ok := headerVariables[name]; !ok {
+			return nil, fmt.Errorf("unknown variable %%%s%%", name)
+		}
+		parts = append(parts, literal.String(), name)
+		literal.Reset()
+	}
+
+	return append(parts, literal.String()), nil
+}
+
+// Value with the variables of this request
+func (v headerValue) expand(vars *headerVars) string {
+	if len(v.parts) == 1 {
+		return v.parts[0]
+	}
+
+	var value strings.Builder
+	for i, part := range v.parts {
+		if i%2 == 0 {
+			value.WriteString(part)
+		} else {
+			value.WriteString(headerVariables[part](vars))
+		}
+	}
+	return value.String()
+}
+
+// Apply the policies to the headers, in order
+func applyHeaderPolicies(policies []*headerPolicy, header http.Header, vars *headerVars) {
+	for _, p := range policies {
+		p.apply(header, vars)
+	}
+}
+
+// Whether one of the policies removes the header
+func removesHeader(policies []*headerPolicy, name string) bool {
+	for _, p := range policies {
+		for _, removed := range p.remove {
+			if removed == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *headerPolicy) apply(header http.Header, vars *headerVars) {
+	for _, name := range p.remove {
+		header.Del(name)
+	}
+	for _, v := range p.set {
+		header.Set(v.name, v.expand(vars))
+	}
+	for _, v := range p.append {
+		header.Add(v.name, v.expand(vars))
+	}
+	for _, v := range p.add {
+		if _, ok := header[v.name]; !ok {
+			header.Set(v.name, v.expand(vars))
+		}
+	}
+}
+
+// Tell the upstream where the request came from: X-Forwarded-Proto, X-Forwarded-Host and Forwarded (RFC 7239)
+// Must run before the Host is rewritten. X-Forwarded-For is appended to by the reverse proxy itself
+// Only a trusted proxy in front of us may have set these already, anything else sent by the client is dropped
+func setForwardedHeaders(req *http.Request) {
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+
+	trusted := false
+	if client := clientFromContext(req.Context()); client != nil {
+		trusted = client.trustedPeer
+	}
+
+	if !trusted {
+		req.Header.Del("X-Forwarded-For")
+		req.Header.Del("Forwarded")
+	}
+
+	// The trusted proxy knows what the client used
+	if !trusted || req.Header.Get("X-Forwarded-Proto") == "" {
+		req.Header.Set("X-Forwarded-Proto", proto)
+	}
+	if !trusted || req.Header.Get("X-Forwarded-Host") == "" {
+		req.Header.Del("X-Forwarded-Host")
+		if req.Host != "" {
+			req.Header.Set("X-Forwarded-Host", req.Host)
+		}
+	}
+
+	element := "for=" + forwardedNode(peerIP(req))
+	if req.Host != "" {
+		element += ";host=" + forwardedValue(req.Host)
+	}
+	element += ";proto=" + proto
+
+	if prior := req.Header.Values("Forwarded"); len(prior) > 0 {
+		element = strings.Join(prior, ", ") + ", " + element
+	}
+	req.Header.Set("Forwarded", element)
+}
+
+// Add the client to X-Forwarded-For, for requests that don't go through the reverse proxy
+func appendForwardedFor(req *http.Request) {
+	ip := peerIP(req)
+	if prior := req.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+		ip = strings.Join(prior, ", ") + ", " + ip
+	}
+	req.Header.Set("X-Forwarded-For", ip)
+}
+
+// Node of a Forwarded element, IPv6 addresses must be bracketed and quoted
+func forwardedNode(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return `"[` + ip + `]"`
+	}
+	return forwardedValue(ip)
+}
+
+// Values that are not plain tokens must be quoted
+func forwardedValue(value string) string {
+	for _, c := range value {
+		if !isTokenChar(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
+
+func isTokenChar(c rune) bool {
+	return c < 127 && c > 32 && !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c)
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+func TestDefaultRequestHeaders(t *testing.T) {
+	var forwardedBy []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedBy = r.Header.Values("X-Forwarded-By")
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name string
+		requestHeaders *pb.HeaderPolicy
+		want []string
+	}{
+		{name: "unset", want: []string{"GoMesh-Proxy"}},
+		{name: "empty", requestHeaders: &pb.HeaderPolicy{}},
+		{name: "own policy", requestHeaders: &pb.HeaderPolicy{Set: []*pb.HeaderValue{{Name: "X-Env", Value: "test"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, &pb.ConfigUpdate{
+				Routes: []*pb.Route{{Path: "/", Backend: backend.URL}},
+				RequestHeaders: tt.requestHeaders,
+			})
+
+			forwardedBy = nil
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if len(forwardedBy) != len(tt.want) || (len(tt.want) > 0 && forwardedBy[0] != tt.want[0]) {
+				t.Fatalf("X-Forwarded-By = %q, want %q", forwardedBy, tt.want)
+			}
+		})
+	}
+}
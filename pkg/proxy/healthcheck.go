@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Defaults for the fields left empty in a HealthCheck
+const (
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultHealthCheckTimeout = time.Second
+	defaultHealthyThreshold = 2
+	defaultUnhealthyThreshold = 3
+	defaultExpectedStatusMin = 200
+	defaultExpectedStatusMax = 299
+)
+
+// Check the health check settings (zero values mean "use the default")
+func validateHealthCheck(config *pb.HealthCheck) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.Path == "" || config.Path[0] != '/' {
+		return fmt.Errorf("path %q must start with /", config.Path)
+	}
+
+	if config.IntervalMs < 0 || config.TimeoutMs < 0 || config.HealthyThreshold < 0 || config.UnhealthyThreshold < 0 {
+		return fmt.Errorf("interval, timeout and thresholds must not be negative")
+	}
+
+	statusMin, statusMax := expectedStatusRange(config)
+	if statusMin < 100 || statusMax > 599 || statusMin > statusMax {
+		return fmt.Errorf("invalid expected status range %d-%d", statusMin, statusMax)
+	}
+
+	return nil
+}
+
+func expectedStatusRange(config *pb.HealthCheck) (int, int) {
+	statusMin, statusMax := int(config.ExpectedStatusMin), int(config.ExpectedStatusMax)
+	if statusMin == 0 {
+		statusMin = defaultExpectedStatusMin
+	}
+	if statusMax == 0 {
+		statusMax = defaultExpectedStatusMax
+	}
+	return statusMin, statusMax
+}
+
+// healthChecker probes every endpoint of a cluster on its own goroutine
+// and flips the endpoint health once a threshold of consecutive results is reached
+type healthChecker struct {
+	cluster *Cluster
+	path string
+	interval time.Duration
+	timeout time.Duration
+	healthyThreshold int
+	unhealthyThreshold int
+	statusMin int
+	statusMax int
+
+	client *http.Client
+	logger *logging.Logger
+	metrics *Metrics
+
+	// Called after every health transition
+	onChange func()
+
+	cancel context.CancelFunc
+	wg sync.WaitGroup
+}
+
+// Start probing the cluster endpoints (no-op if the cluster has no health check)
+func (c *Cluster) startHealthChecks(logger *logging.Logger, metrics *Metrics, onChange func(), transport http.RoundTripper) {
+
+	config := c.config.HealthCheck
+	if config == nil {
+		return
+	}
+
+	checker := &healthChecker{
+		cluster: c,
+		path: config.Path,
+		interval: durationOrDefault(config.IntervalMs, defaultHealthCheckInterval),
+		timeout: durationOrDefault(config.TimeoutMs, defaultHealthCheckTimeout),
+		healthyThreshold: intOrDefault(config.HealthyThreshold, defaultHealthyThreshold),
+		unhealthyThreshold: intOrDefault(config.UnhealthyThreshold, defaultUnhealthyThreshold),
+		client: &http.Client{
+			Transport: transport,
+			// A redirect is an answer too, it's checked against the expected status range
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger.With(zap.String("cluster", c.name)),
+		metrics: metrics,
+		onChange: onChange,
+	}
+	checker.statusMin, checker.statusMax = expectedStatusRange(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	checker.cancel = cancel
+
+	for _, endpoint := range c.endpoints {
+		checker.wg.Add(1)
+		go checker.probeLoop(ctx, endpoint)
+	}
+
+	c.healthChecker = checker
+
+	logger.Info("health checking started",
+		zap.String("cluster", c.name),
+		zap.String("path", checker.path),
+		zap.Duration("interval", checker.interval),
+	)
+}
+
+// Stop probing the cluster endpoints
+func (c *Cluster) stopHealthChecks() {
+	if c.healthChecker != nil {
+		c.healthChecker.cancel()
+		c.healthChecker.wg.Wait()
+	}
+}
+
+// Probe one endpoint until the context is cancelled
+func (hc *healthChecker) probeLoop(ctx context.Context, endpoint *Endpoint) {
+	defer hc.wg.Done()
+
+	// Random first delay, so the endpoints are not all probed at the same instant
+	timer := time.NewTimer(time.Duration(rand.Int64N(int64(hc.interval))))
+	defer timer.Stop()
+
+	successes, failures := 0, 0
+
+	for {
+		select {
+		case <- ctx.Done():
+			return
+		case <- timer.C:
+		}
+
+		err := hc.probe(ctx, endpoint)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			successes, failures = successes+1, 0
+			if !endpoint.Healthy() && successes >= hc.healthyThreshold {
+				hc.setHealth(endpoint, true, nil)
+			}
+		} else {
+			successes, failures = 0, failures+1
+			if endpoint.Healthy() && failures >= hc.unhealthyThreshold {
+				hc.setHealth(endpoint, false, err)
+			}
+		}
+
+		timer.Reset(hc.interval)
+	}
+}
+
+// Send one probe, any error or unexpected status is a failure
+func (hc *healthChecker) probe(ctx context.Context, endpoint *Endpoint) error {
+
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout)
+	defer cancel()
+
+	// The transport reads the endpoint to know whether to use mTLS
+	ctx = withEndpoint(ctx, endpoint)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.url.Scheme+"://"+endpoint.url.Host+hc.path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "GoMesh-HealthChecker")
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain (a bit of) the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < hc.statusMin || resp.StatusCode > hc.statusMax {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Flip the endpoint health, export it and notify
+func (hc *healthChecker) setHealth(endpoint *Endpoint, healthy bool, reason error) {
+	endpoint.unhealthy.Store(!healthy)
+	hc.metrics.SetEndpointHealth(hc.cluster.name, endpoint.address, healthy)
+
+	if healthy {
+		hc.logger.Info("endpoint is healthy again, back in load balancing",
+			zap.String("endpoint", endpoint.address),
+		)
+	} else {
+		hc.logger.Warn("endpoint is unhealthy, ejected from load balancing",
+			zap.String("endpoint", endpoint.address),
+			zap.Error(reason),
+		)
+	}
+
+	if hc.onChange != nil {
+		hc.onChange()
+	}
+}
+
+func durationOrDefault(ms int32, fallback time.Duration) time.Duration {
+	if ms <= 0 {
+		return fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func intOrDefault(value int32, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return int(value)
+}
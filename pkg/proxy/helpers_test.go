@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+)
+
+// Metrics register with the default registry, so the whole package shares one set
+var testMetrics = NewMetrics()
+
+func newTestLogger(t *testing.T) *logging.Logger {
+	t.Helper()
+
+	logger, err := logging.NewLogger(true)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	return logger
+}
+
+// Handler serving the routes and clusters of update
+func newTestHandler(t *testing.T, update *pb.ConfigUpdate) *Handler {
+	t.Helper()
+
+	h, err := NewHandler(&Config{}, newTestLogger(t), testMetrics)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	if err := h.ApplyConfig(update); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	return h
+}
+
+// Clock moved by hand, for the components that take a now function
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
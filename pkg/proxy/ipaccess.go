@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// ipAccessList allows or denies clients by IP address
+type ipAccessList struct {
+	allow []netip.Prefix
+	deny []netip.Prefix
+}
+
+// Build the list (nil when both sides are empty: every client is allowed)
+func newIPAccessList(allow []string, deny []string) (*ipAccessList, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+
+	allowPrefixes, err := parsePrefixes(allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allow entry: %w", err)
+	}
+
+	denyPrefixes, err := parsePrefixes(deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deny entry: %w", err)
+	}
+
+	return &ipAccessList{allow: allowPrefixes, deny: denyPrefixes}, nil
+}
+
+// Parse CIDR ranges, a plain IP is a range of one address
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an IP or CIDR range", value)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP or CIDR range", value)
+		}
+
+		// Client addresses are unmapped, so IPv4-mapped ranges must be too (::ffff:10.0.0.0/104 is 10.0.0.0/8)
+		if prefix.Addr().Is4In6() {
+			if prefix.Bits() < 96 {
+				return nil, fmt.Errorf("%q spans beyond the IPv4-mapped addresses", value)
+			}
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Deny wins, then a non-empty allow list must contain the address
+// A client without a valid address is only allowed when nothing is configured
+func (l *ipAccessList) allows(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+
+	if containsAddr(l.deny, addr) {
+		return false
+	}
+	return len(l.allow) == 0 || containsAddr(l.allow, addr)
+}
+
+// trustedProxies are the load balancers in front of the listener, whose X-Forwarded-* headers are believed
+type trustedProxies []netip.Prefix
+
+// clientInfo is who sent a request, resolved once when it reaches the handler
+type clientInfo struct {
+	// Invalid when the address can't be told
+	addr netip.Addr
+
+	// The peer is a trusted proxy: the forwarded headers it sent describe the client
+	trustedPeer bool
+}
+
+// Address of the client: the peer, or behind trusted proxies the last X-Forwarded-For entry they didn't add
+// Entries left of an untrusted hop could be made up by the client, so they are never used
+func (t trustedProxies) client(r *http.Request) *clientInfo {
+	addr, err := netip.ParseAddr(peerIP(r))
+	if err != nil {
+		return &clientInfo{}
+	}
+	addr = addr.Unmap()
+
+	if !containsAddr(t, addr) {
+		return &clientInfo{addr: addr}
+	}
+	client := &clientInfo{trustedPeer: true}
+
+	// Walk the hops from the closest one
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A broken entry: the last trusted hop is as far as we can tell
+			client.addr = addr
+			return client
+		}
+
+		addr = hop.Unmap()
+		if !containsAddr(t, addr) {
+			client.addr = addr
+			return client
+		}
+	}
+
+	// Every hop is trusted, the first one is the client
+	client.addr = addr
+	return client
+}
+
+// Address of the client (invalid when it can't be told)
+// Behind trusted proxies it is the one they forwarded, every client IP of the proxy comes from here
+func clientAddr(r *http.Request) netip.Addr {
+	if client := clientFromContext(r.Context()); client != nil {
+		return client.addr
+	}
+
+	addr, err := netip.ParseAddr(peerIP(r))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// Client address as text, for rate limit keys, hash keys and header values
+func clientIP(r *http.Request) string {
+	if addr := clientAddr(r); addr.IsValid() {
+		return addr.String()
+	}
+	return peerIP(r)
+}
+
+// Address of the peer without the port: the client, or the last proxy in front of us
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Check the route IP access list before anything else runs for the request
+func ipAccessMiddleware(logger *logging.Logger, metrics *Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rt := routeFromContext(r.Context())
+		if rt == nil || rt.ipAccess == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if addr := clientAddr(r); !rt.ipAccess.allows(addr) {
+			denyByIP(w, r, logger, metrics, rt.service(), "route", addr)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Answer 403 to a client whose address is not allowed
+func denyByIP(w http.ResponseWriter, r *http.Request, logger *logging.Logger, metrics *Metrics, service string, scope string, addr netip.Addr) {
+	logger.Warn("request denied by IP access list",
+		zap.String("scope", scope),
+		zap.String("client_ip", addr.String()),
+		zap.String("remote_addr", r.RemoteAddr),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("trace_id", tracing.GetTraceID(r)),
+	)
+	metrics.RecordError(service, "ip_denied")
+
+	http.Error(w, "Forbidden", http.StatusForbidden)
+}
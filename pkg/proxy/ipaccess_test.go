@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		name string
+		value string
+		want string
+		wantErr bool
+	}{
+		{name: "IPv4", value: "10.0.0.1", want: "10.0.0.1/32"},
+		{name: "IPv6", value: "2001:db8::1", want: "2001:db8::1/128"},
+		{name: "IPv4-mapped IP", value: "::ffff:10.0.0.1", want: "10.0.0.1/32"},
+		{name: "IPv4 range", value: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{name: "IPv4-mapped range", value: "::ffff:10.0.0.0/104", want: "10.0.0.0/8"},
+		{name: "IPv4-mapped range beyond the mapped addresses", value: "::ffff:0.0.0.0/95", wantErr: true},
+		{name: "spaces", value: " 192.168.0.0/16 ", want: "192.168.0.0/16"},
+		{name: "host name", value: "example.com", wantErr: true},
+		{name: "bad range", value: "10.0.0.0/33", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := parsePrefixes([]string{tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePrefixes(%q): error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if err == nil && prefixes[0].String() != tt.want {
+				t.Fatalf("parsePrefixes(%q) = %v, want %v", tt.value, prefixes[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestIPAccessListMappedRange(t *testing.T) {
+	list, err := newIPAccessList(nil, []string{"::ffff:10.0.0.0/104"})
+	if err != nil {
+		t.Fatalf("newIPAccessList: %v", err)
+	}
+
+	// Client addresses are unmapped before the check
+	if list.allows(netip.MustParseAddr("::ffff:10.1.2.3").Unmap()) {
+		t.Fatal("an address of the denied IPv4-mapped range is allowed")
+	}
+	if !list.allows(netip.MustParseAddr("192.168.1.1")) {
+		t.Fatal("an address outside the denied range is denied")
+	}
+}
+
+func TestTrustedProxiesClient(t *testing.T) {
+	trusted, err := parsePrefixes([]string{"10.0.0.0/8", "::ffff:172.16.0.0/108"})
+	if err != nil {
+		t.Fatalf("parsePrefixes: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		remoteAddr string
+		xff []string
+		want string
+		wantTrustedPeer bool
+	}{
+		{
+			name: "untrusted peer with a spoofed XFF",
+			remoteAddr: "203.0.113.7:4000",
+			xff: []string{"1.2.3.4"},
+			want: "203.0.113.7",
+		},
+		{
+			name: "trusted peer",
+			remoteAddr: "10.0.0.1:4000",
+			xff: []string{"203.0.113.7"},
+			want: "203.0.113.7",
+			wantTrustedPeer: true,
+		},
+		{
+			name: "chain of trusted hops",
+			remoteAddr: "10.0.0.1:4000",
+			xff: []string{"203.0.113.7, 10.0.0.3, 10.0.0.2"},
+			want: "203.0.113.7",
+			wantTrustedPeer: true,
+		},
+		{
+			name: "client made up entries left of the first trusted hop",
+			remoteAddr: "10.0.0.1:4000",
+			xff: []string{"1.2.3.4, 203.0.113.7, 10.0.0.2"},
+			want: "203.0.113.7",
+			wantTrustedPeer: true,
+		},
+		{
+			name: "unparsable hop",
+			remoteAddr: "10.0.0.1:4000",
+			xff: []string{"203.0.113.7, garbage, 10.0.0.2"},
+			want: "10.0.0.2",
+			wantTrustedPeer: true,
+		},
+		{
+			name: "several XFF header lines",
+			remoteAddr: "10.0.0.1:4000",
+			xff: []string{"1.2.3.4, 203.0.113.7", "10.0.0.3", "10.0.0.2"},
+			want: "203.0.113.7",
+			wantTrustedPeer: true,
+		},
+		{
+			name: "every hop trusted",
+			remoteAddr: "10.0.0.1:4000",
+			xff: []string{"10.0.0.3, 10.0.0.2"},
+			want: "10.0.0.3",
+			wantTrustedPeer: true,
+		},
+		{
+			name: "trusted peer without XFF",
+			remoteAddr: "10.0.0.1:4000",
+			want: "10.0.0.1",
+			wantTrustedPeer: true,
+		},
+		{
+			name: "IPv4-mapped peer in a mapped trusted range",
+			remoteAddr: "[::ffff:172.16.0.5]:4000",
+			xff: []string{"::ffff:203.0.113.7"},
+			want: "203.0.113.7",
+			wantTrustedPeer: true,
+		},
+		{
+			name: "unparsable peer",
+			remoteAddr: "not-an-address",
+			xff: []string{"203.0.113.7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			client := trustedProxies(trusted).client(r)
+
+			got := ""
+			if client.addr.IsValid() {
+				got = client.addr.String()
+			}
+			if got != tt.want {
+				t.Errorf("client address = %q, want %q", got, tt.want)
+			}
+			if client.trustedPeer != tt.wantTrustedPeer {
+				t.Errorf("trusted peer = %v, want %v", client.trustedPeer, tt.wantTrustedPeer)
+			}
+		})
+	}
+}
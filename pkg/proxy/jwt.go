@@ -0,0 +1,286 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Tolerated clock difference between the token issuer and the proxy
+const jwtClockSkew = 30 * time.Second
+
+// A key from a JSON Web Key Set (only the fields we use)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+
+	// Symmetric key (kty "oct")
+	K string `json:"k"`
+
+	// RSA public key (kty "RSA")
+	N string `json:"n"`
+	E string `json:"e"`
+}
+
+// A verification key ready to use, either an HMAC secret or an RSA public key
+type jwtKey struct {
+	id string
+	alg string
+	secret []byte
+	publicKey *rsa.PublicKey
+}
+
+// JWTAuthenticator validates HS256 and RS256 bearer tokens against a JWKS
+type JWTAuthenticator struct {
+	keys []*jwtKey
+	issuer string
+	audience string
+}
+
+// Build a JWT authenticator from a JWKS document
+func NewJWTAuthenticator(jwks []byte, issuer string, audience string) (*JWTAuthenticator, error) {
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	if len(set.Keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no keys")
+	}
+
+	authenticator := &JWTAuthenticator{
+		issuer: issuer,
+		audience: audience,
+	}
+
+	for i, jwk := range set.Keys {
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d (kid %q) in JWKS: %w", i, jwk.Kid, err)
+		}
+		authenticator.keys = append(authenticator.keys, key)
+	}
+
+	return authenticator, nil
+}
+
+// Turn a JWK into a verification key
+// The algorithm is bound to the key type, so an RSA public key can never be used as an HMAC secret
+func parseJSONWebKey(jwk jsonWebKey) (*jwtKey, error) {
+	switch jwk.Kty {
+	case "oct":
+		if jwk.Alg != "" && jwk.Alg != "HS256" {
+			return nil, fmt.Errorf("unsupported algorithm %q for oct key", jwk.Alg)
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.K, "="))
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("invalid symmetric key value")
+		}
+		return &jwtKey{id: jwk.Kid, alg: "HS256", secret: secret}, nil
+
+	case "RSA":
+		if jwk.Alg != "" && jwk.Alg != "RS256" {
+			return nil, fmt.Errorf("unsupported algorithm %q for RSA key", jwk.Alg)
+		}
+		n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.N, "="))
+		if err != nil || len(n) == 0 {
+			return nil, fmt.Errorf("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.E, "="))
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		publicKey := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		return &jwtKey{id: jwk.Kid, alg: "RS256", publicKey: publicKey}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// Scheme used in the WWW-Authenticate challenge
+func (a *JWTAuthenticator) Scheme() string {
+	return "Bearer"
+}
+
+// Authenticate verifies the bearer token in the Authorization header
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return nil, errNoCredentials
+	}
+
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return nil, errNoCredentials
+	}
+
+	claims, err := a.verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, err
+	}
+
+	return claims.identity(), nil
+}
+
+// Claims we read from the token payload
+type jwtClaims struct {
+	Subject string `json:"sub"`
+	Issuer string `json:"iss"`
+	Audience audience `json:"aud"`
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+
+	// Scopes come either as a space separated "scope" or as a "scp" list
+	Scope string `json:"scope"`
+	Scp []string `json:"scp"`
+}
+
+func (c *jwtClaims) identity() *Identity {
+	scopes := c.Scp
+	if c.Scope != "" {
+		scopes = append(scopes, strings.Fields(c.Scope)...)
+	}
+
+	return &Identity{
+		Subject: c.Subject,
+		Scopes: scopes,
+	}
+}
+
+// The "aud" claim can be a string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("aud must be a string or a list of strings")
+	}
+	*a = list
+	return nil
+}
+
+// Check signature and claims of a compact JWT
+func (a *JWTAuthenticator) verify(token string) (*jwtClaims, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed token header")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+
+	if header.Alg != "HS256" && header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+
+	signingInput := []byte(parts[0] + "." + parts[1])
+	if !a.verifySignature(header.Alg, header.Kid, signingInput, signature) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	// Only look at the payload once the signature is valid
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token payload")
+	}
+
+	var claims jwtClaims
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, errors.New("malformed token payload")
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt != nil && now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtClockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Add(jwtClockSkew).Before(time.Unix(*claims.NotBefore, 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return nil, errors.New("unexpected token issuer")
+	}
+
+	if a.audience != "" && !claims.Audience.contains(a.audience) {
+		return nil, errors.New("unexpected token audience")
+	}
+
+	return &claims, nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, aud := range a {
+		if aud == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Try the keys that match the algorithm (and kid, if the token has one)
+func (a *JWTAuthenticator) verifySignature(alg string, kid string, signingInput []byte, signature []byte) bool {
+
+	digest := sha256.Sum256(signingInput)
+
+	for _, key := range a.keys {
+		if key.alg != alg || (kid != "" && key.id != kid) {
+			continue
+		}
+
+		switch alg {
+		case "HS256":
+			mac := hmac.New(sha256.New, key.secret)
+			mac.Write(signingInput)
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return true
+			}
+
+		case "RS256":
+			if rsa.VerifyPKCS1v15(key.publicKey, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,333 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testHMACSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// Keys of the test JWKS: an HMAC secret (kid "hs") and an RSA key pair (kid "rs")
+type jwtTestKeys struct {
+	rsaKey *rsa.PrivateKey
+	jwks []byte
+}
+
+func newJWTTestKeys(t *testing.T) *jwtTestKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks, _ := json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			{"kty": "oct", "kid": "hs", "k": encode(testHMACSecret)},
+			{
+				"kty": "RSA",
+				"kid": "rs",
+				"n": encode(rsaKey.N.Bytes()),
+				"e": encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+		},
+	})
+
+	return &jwtTestKeys{rsaKey: rsaKey, jwks: jwks}
+}
+
+// Build a compact JWT, signing it with the HMAC secret or the RSA key depending on the header alg
+func signTestToken(t *testing.T, header map[string]any, claims map[string]any, secret []byte, rsaKey *rsa.PrivateKey) string {
+	t.Helper()
+
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch {
+	case secret != nil:
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case rsaKey != nil:
+		digest := sha256.Sum256([]byte(signingInput))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("SignPKCS1v15: %v", err)
+		}
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthenticatorVerify(t *testing.T) {
+	keys := newJWTTestKeys(t)
+
+	authenticator, err := NewJWTAuthenticator(keys.jwks, "https://issuer.example.com", "gomesh")
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+
+	// The RSA public key as an attacker would find it, used as an HMAC secret
+	publicDER, _ := x509.MarshalPKIXPublicKey(&keys.rsaKey.PublicKey)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	now := time.Now().Unix()
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"sub": "alice",
+			"iss": "https://issuer.example.com",
+			"aud": "gomesh",
+			"exp": now + 3600,
+			"scope": "read write",
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT", "kid": "hs"}
+	rs256 := map[string]any{"alg": "RS256", "typ": "JWT", "kid": "rs"}
+
+	tests := []struct {
+		name string
+		token string
+		wantErr string
+	}{
+		{
+			name: "valid HS256",
+			token: signTestToken(t, hs256, validClaims(), testHMACSecret, nil),
+		},
+		{
+			name: "valid RS256",
+			token: signTestToken(t, rs256, validClaims(), nil, keys.rsaKey),
+		},
+		{
+			name: "valid without kid",
+			token: signTestToken(t, map[string]any{"alg": "RS256"}, validClaims(), nil, keys.rsaKey),
+		},
+		{
+			name: "audience list containing ours",
+			token: signTestToken(t, hs256, with("aud", []string{"other", "gomesh"}), testHMACSecret, nil),
+		},
+		{
+			name: "alg none",
+			token: signTestToken(t, map[string]any{"alg": "none"}, validClaims(), nil, nil),
+			wantErr: "unsupported token algorithm",
+		},
+		{
+			name: "alg none in another case",
+			token: signTestToken(t, map[string]any{"alg": "None"}, validClaims(), nil, nil),
+			wantErr: "unsupported token algorithm",
+		},
+		{
+			name: "HS256 signed with the RSA public key",
+			token: signTestToken(t, map[string]any{"alg": "HS256", "kid": "rs"}, validClaims(), publicPEM, nil),
+			wantErr: "invalid token signature",
+		},
+		{
+			name: "HS256 signed with the RSA public key, no kid",
+			token: signTestToken(t, map[string]any{"alg": "HS256"}, validClaims(), publicPEM, nil),
+			wantErr: "invalid token signature",
+		},
+		{
+			name: "HS256 signed with the RSA modulus",
+			token: signTestToken(t, map[string]any{"alg": "HS256"}, validClaims(), keys.rsaKey.N.Bytes(), nil),
+			wantErr: "invalid token signature",
+		},
+		{
+			name: "RS256 header on an HMAC key id",
+			token: signTestToken(t, map[string]any{"alg": "RS256", "kid": "hs"}, validClaims(), nil, keys.rsaKey),
+			wantErr: "invalid token signature",
+		},
+		{
+			name: "unknown kid",
+			token: signTestToken(t, map[string]any{"alg": "HS256", "kid": "other"}, validClaims(), testHMACSecret, nil),
+			wantErr: "invalid token signature",
+		},
+		{
+			name: "wrong HMAC secret",
+			token: signTestToken(t, hs256, validClaims(), []byte("not the secret"), nil),
+			wantErr: "invalid token signature",
+		},
+		{
+			name: "expired",
+			token: signTestToken(t, hs256, with("exp", now-3600), testHMACSecret, nil),
+			wantErr: "token expired",
+		},
+		{
+			name: "expired within the clock skew",
+			token: signTestToken(t, hs256, with("exp", now-10), testHMACSecret, nil),
+		},
+		{
+			name: "not valid yet",
+			token: signTestToken(t, hs256, with("nbf", now+3600), testHMACSecret, nil),
+			wantErr: "token not valid yet",
+		},
+		{
+			name: "not valid yet within the clock skew",
+			token: signTestToken(t, hs256, with("nbf", now+10), testHMACSecret, nil),
+		},
+		{
+			name: "wrong issuer",
+			token: signTestToken(t, hs256, with("iss", "https://evil.example.com"), testHMACSecret, nil),
+			wantErr: "unexpected token issuer",
+		},
+		{
+			name: "missing issuer",
+			token: signTestToken(t, hs256, with("iss", nil), testHMACSecret, nil),
+			wantErr: "unexpected token issuer",
+		},
+		{
+			name: "wrong audience",
+			token: signTestToken(t, hs256, with("aud", "other"), testHMACSecret, nil),
+			wantErr: "unexpected token audience",
+		},
+		{
+			name: "audience list without ours",
+			token: signTestToken(t, hs256, with("aud", []string{"a", "b"}), testHMACSecret, nil),
+			wantErr: "unexpected token audience",
+		},
+		{
+			name: "malformed",
+			token: "not.a-token",
+			wantErr: "malformed token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := authenticator.verify(tt.token)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verify: unexpected error %v", err)
+				}
+				if claims.Subject != "alice" {
+					t.Errorf("subject = %q, want alice", claims.Subject)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("verify: error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorTamperedPayload(t *testing.T) {
+	keys := newJWTTestKeys(t)
+
+	authenticator, err := NewJWTAuthenticator(keys.jwks, "", "")
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+
+	token := signTestToken(t, map[string]any{"alg": "RS256"}, map[string]any{"sub": "alice"}, nil, keys.rsaKey)
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(map[string]any{"sub": "admin"})
+	parts[1] = base64.RawURLEncoding.EncodeToString(forged)
+
+	if _, err := authenticator.verify(strings.Join(parts, ".")); err == nil {
+		t.Fatal("verify accepted a token with a swapped payload")
+	}
+}
+
+func TestParseJSONWebKey(t *testing.T) {
+	secret := base64.RawURLEncoding.EncodeToString(testHMACSecret)
+
+	tests := []struct {
+		name string
+		jwk jsonWebKey
+		wantAlg string
+		wantErr bool
+	}{
+		{name: "oct", jwk: jsonWebKey{Kty: "oct", K: secret}, wantAlg: "HS256"},
+		{name: "oct with padding", jwk: jsonWebKey{Kty: "oct", K: base64.URLEncoding.EncodeToString(testHMACSecret)}, wantAlg: "HS256"},
+		{name: "oct declared RS256", jwk: jsonWebKey{Kty: "oct", Alg: "RS256", K: secret}, wantErr: true},
+		{name: "empty oct", jwk: jsonWebKey{Kty: "oct"}, wantErr: true},
+		{name: "RSA declared HS256", jwk: jsonWebKey{Kty: "RSA", Alg: "HS256", N: "AQAB", E: "AQAB"}, wantErr: true},
+		{name: "RSA without exponent", jwk: jsonWebKey{Kty: "RSA", N: "AQAB"}, wantErr: true},
+		{name: "EC", jwk: jsonWebKey{Kty: "EC"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parseJSONWebKey(tt.jwk)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseJSONWebKey accepted %+v", tt.jwk)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJSONWebKey: %v", err)
+			}
+			if key.alg != tt.wantAlg {
+				t.Errorf("alg = %q, want %q", key.alg, tt.wantAlg)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorAuthenticate(t *testing.T) {
+	keys := newJWTTestKeys(t)
+
+	authenticator, err := NewJWTAuthenticator(keys.jwks, "", "")
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+
+	token := signTestToken(t, map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice", "scp": []string{"read"}}, testHMACSecret, nil)
+
+	tests := []struct {
+		name string
+		authorization string
+		wantErr error
+		wantSubject string
+	}{
+		{name: "bearer", authorization: "Bearer " + token, wantSubject: "alice"},
+		{name: "lower case scheme", authorization: "bearer " + token, wantSubject: "alice"},
+		{name: "no header", wantErr: errNoCredentials},
+		{name: "basic", authorization: "Basic YWxpY2U6c2VjcmV0", wantErr: errNoCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			identity, err := authenticator.Authenticate(r)
+			if err != tt.wantErr {
+				t.Fatalf("Authenticate: error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && identity.Subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", identity.Subject, tt.wantSubject)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+// LoadBalancer picks the endpoint of a cluster that serves a request
+type LoadBalancer interface {
+	// Pick returns nil when no endpoint can take the request
+	// Unavailable endpoints (e.g., failing health checks) are never picked
+	Pick(r *http.Request) *Endpoint
+}
+
+// Endpoints that can take traffic right now
+// Returns the same slice when all of them are available, to avoid allocating on the happy path
+func availableEndpoints(endpoints []*Endpoint) []*Endpoint {
+	for i, endpoint := range endpoints {
+		if endpoint.Available() {
+			continue
+		}
+
+		// At least one is out, copy the available ones
+		available := make([]*Endpoint, 0, len(endpoints)-1)
+		available = append(available, endpoints[:i]...)
+		for _, endpoint := range endpoints[i+1:] {
+			if endpoint.Available() {
+				available = append(available, endpoint)
+			}
+		}
+		return available
+	}
+
+	return endpoints
+}
+
+// Create the load balancer for the given policy over the cluster endpoints
+func newLoadBalancer(policy pb.LoadBalancerPolicy, cluster *Cluster) (LoadBalancer, error) {
+	switch policy {
+	case pb.LoadBalancerPolicy_ROUND_ROBIN:
+		return &roundRobinBalancer{endpoints: cluster.endpoints}, nil
+	case pb.LoadBalancerPolicy_RANDOM:
+		return &randomBalancer{endpoints: cluster.endpoints}, nil
+	case pb.LoadBalancerPolicy_LEAST_REQUEST:
+		return &leastRequestBalancer{endpoints: cluster.endpoints}, nil
+	case pb.LoadBalancerPolicy_POWER_OF_TWO_CHOICES:
+		return &powerOfTwoBalancer{endpoints: cluster.endpoints}, nil
+	case pb.LoadBalancerPolicy_RING_HASH:
+		return newRingHashBalancer(cluster), nil
+	default:
+		return nil, fmt.Errorf("unknown load balancer policy %v", policy)
+	}
+}
+
+// Round robin: each endpoint in turn
+type roundRobinBalancer struct {
+	endpoints []*Endpoint
+	next atomic.Uint64
+}
+
+func (b *roundRobinBalancer) Pick(r *http.Request) *Endpoint {
+	endpoints := availableEndpoints(b.endpoints)
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	// Add returns the new value, subtract one to start from the first endpoint
+	i := (b.next.Add(1) - 1) % uint64(len(endpoints))
+	return endpoints[i]
+}
+
+// Random: uniformly random endpoint, no shared state between requests
+type randomBalancer struct {
+	endpoints []*Endpoint
+}
+
+func (b *randomBalancer) Pick(r *http.Request) *Endpoint {
+	endpoints := availableEndpoints(b.endpoints)
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	return endpoints[rand.IntN(len(endpoints))]
+}
+
+// Least request: the endpoint with the fewest in-flight requests
+type leastRequestBalancer struct {
+	endpoints []*Endpoint
+}
+
+func (b *leastRequestBalancer) Pick(r *http.Request) *Endpoint {
+	if len(b.endpoints) == 0 {
+		return nil
+	}
+
+	// Start from a random position, so ties don't always go to the first endpoint
+	start := rand.IntN(len(b.endpoints))
+
+	var best *Endpoint
+	for i := range b.endpoints {
+		endpoint := b.endpoints[(start+i)%len(b.endpoints)]
+		if !endpoint.Available() {
+			continue
+		}
+		if best == nil || endpoint.ActiveRequests() < best.ActiveRequests() {
+			best = endpoint
+		}
+	}
+
+	return best
+}
+
+// Power of two choices: the least loaded of two random endpoints
+// Close to least request, without scanning the whole cluster
+type powerOfTwoBalancer struct {
+	endpoints []*Endpoint
+}
+
+func (b *powerOfTwoBalancer) Pick(r *http.Request) *Endpoint {
+	endpoints := availableEndpoints(b.endpoints)
+	n := len(endpoints)
+	if n == 0 {
+		return nil
+	}
+	if n == 1 {
+		return endpoints[0]
+	}
+
+	// Two distinct random endpoints
+	i := rand.IntN(n)
+	j := rand.IntN(n - 1)
+	if j >= i {
+		j++
+	}
+
+	first, second := endpoints[i], endpoints[j]
+	if second.ActiveRequests() < first.ActiveRequests() {
+		return second
+	}
+	return first
+}
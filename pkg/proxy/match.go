@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// requestMatcher checks the conditions of a route beyond its path
+// The zero value matches every request
+type requestMatcher struct {
+	methods map[string]bool
+	hosts []string
+	headers []*valueMatcher
+	query []*valueMatcher
+}
+
+// One header or query parameter condition
+type valueMatcher struct {
+	config *pb.KeyValueMatch
+	regex *regexp.Regexp
+}
+
+// Compile the match conditions of a route
+func newRequestMatcher(routeConfig *pb.Route) (*requestMatcher, error) {
+	m := &requestMatcher{}
+
+	if len(routeConfig.Methods) > 0 {
+		m.methods = make(map[string]bool, len(routeConfig.Methods))
+		for _, method := range routeConfig.Methods {
+			if method == "" {
+				return nil, fmt.Errorf("empty method")
+			}
+			m.methods[strings.ToUpper(method)] = true
+		}
+	}
+
+	for _, host := range routeConfig.Hosts {
+		host = strings.ToLower(host)
+		if host == "" || host == "*." || (strings.Contains(host, "*") && !strings.HasPrefix(host, "*.")) || strings.Count(host, "*") > 1 {
+			return nil, fmt.Errorf("invalid host %q (wildcards only as \"*.domain\")", host)
+		}
+		m.hosts = append(m.hosts, host)
+	}
+
+	for _, config := range routeConfig.Headers {
+		header, err := newValueMatcher(config)
+		if err != nil {
+			return nil, fmt.Errorf("header %q: %w", config.Name, err)
+		}
+		m.headers = append(m.headers, header)
+	}
+
+	for _, config := range routeConfig.QueryParams {
+		param, err := newValueMatcher(config)
+		if err != nil {
+			return nil, fmt.Errorf("query param %q: %w", config.Name, err)
+		}
+		m.query = append(m.query, param)
+	}
+
+	return m, nil
+}
+
+func newValueMatcher(config *pb.KeyValueMatch) (*valueMatcher, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("name is empty")
+	}
+
+	v := &valueMatcher{config: config}
+
+	switch config.Match {
+	case pb.StringMatch_STRING_MATCH_EXACT, pb.StringMatch_STRING_MATCH_PREFIX, pb.StringMatch_STRING_MATCH_PRESENT:
+	case pb.StringMatch_STRING_MATCH_REGEX:
+		// Anchored, the whole value has to match
+		regex, err := regexp.Compile("^(?:" + config.Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		v.regex = regex
+	default:
+		return nil, fmt.Errorf("unknown match type %v", config.Match)
+	}
+
+	return v, nil
+}
+
+// Whether the request meets every condition
+func (m *requestMatcher) matches(r *http.Request) bool {
+	if m.methods != nil && !m.methods[r.Method] {
+		return false
+	}
+
+	if len(m.hosts) > 0 && !m.matchesHost(r.Host) {
+		return false
+	}
+
+	for _, header := range m.headers {
+		if !header.matches(r.Header.Values(header.config.Name)) {
+			return false
+		}
+	}
+
+	// Only parse the query when a route asks for it
+	if len(m.query) > 0 {
+		query, err := url.ParseQuery(r.URL.RawQuery)
+		if err != nil && query == nil {
+			query = url.Values{}
+		}
+		for _, param := range m.query {
+			if !param.matches(query[param.config.Name]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Hosts are compared without port and case, "*.example.com" matches subdomains but not example.com itself
+func (m *requestMatcher) matchesHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, pattern := range m.hosts {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Any of the values meeting the condition is enough (a header or parameter can repeat)
+func (v *valueMatcher) matches(values []string) bool {
+	found := false
+	for _, value := range values {
+		if v.matchesValue(value) {
+			found = true
+			break
+		}
+	}
+	return found != v.config.Invert
+}
+
+func (v *valueMatcher) matchesValue(value string) bool {
+	switch v.config.Match {
+	case pb.StringMatch_STRING_MATCH_PREFIX:
+		return strings.HasPrefix(value, v.config.Value)
+	case pb.StringMatch_STRING_MATCH_REGEX:
+		return v.regex.MatchString(value)
+	case pb.StringMatch_STRING_MATCH_PRESENT:
+		return true
+	default:
+		return value == v.config.Value
+	}
+}
+
+// Number of conditions, routes with more of them are more specific
+func (m *requestMatcher) conditions() int {
+	n := len(m.headers) + len(m.query)
+	if m.methods != nil {
+		n++
+	}
+	if len(m.hosts) > 0 {
+		n++
+	}
+	return n
+}
+
+// Whether route a must be tried before route b, they have the same path
+// Routes with hosts first, then routes with more conditions. Ties keep the config order
+func moreSpecific(a, b *route) bool {
+	aHosts, bHosts := len(a.matcher.hosts) > 0, len(b.matcher.hosts) > 0
+	if aHosts != bHosts {
+		return aHosts
+	}
+	return a.matcher.conditions() > b.matcher.conditions()
+}
+
+// Identifies what a route matches on, two routes with the same signature would shadow each other
+// Methods and hosts are compared as the matcher does: without case and order
+func matchSignature(routeConfig *pb.Route) string {
+	conditions, _ := proto.MarshalOptions{Deterministic: true}.Marshal(&pb.Route{
+		Methods: normalizedSet(routeConfig.Methods, strings.ToUpper),
+		Hosts: normalizedSet(routeConfig.Hosts, strings.ToLower),
+		Headers: routeConfig.Headers,
+		QueryParams: routeConfig.QueryParams,
+	})
+	return fmt.Sprintf("%v %s %x", routeConfig.PathMatch, routeConfig.Path, conditions)
+}
+
+// Sorted values without duplicates, after applying normalize to each
+func normalizedSet(values []string, normalize func(string) string) []string {
+	seen := make(map[string]bool, len(values))
+	set := make([]string, 0, len(values))
+	for _, value := range values {
+		value = normalize(value)
+		if !seen[value] {
+			seen[value] = true
+			set = append(set, value)
+		}
+	}
+	sort.Strings(set)
+	return set
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"testing"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+func TestMatchSignature(t *testing.T) {
+	base := &pb.Route{Path: "/api", Methods: []string{"GET", "POST"}, Hosts: []string{"api.example.com", "*.example.com"}}
+
+	tests := []struct {
+		name string
+		route *pb.Route
+		wantSame bool
+	}{
+		{name: "other case", route: &pb.Route{Path: "/api", Methods: []string{"get", "Post"}, Hosts: []string{"API.example.com", "*.EXAMPLE.com"}}, wantSame: true},
+		{name: "other order", route: &pb.Route{Path: "/api", Methods: []string{"POST", "GET"}, Hosts: []string{"*.example.com", "api.example.com"}}, wantSame: true},
+		{name: "repeated values", route: &pb.Route{Path: "/api", Methods: []string{"GET", "POST", "get"}, Hosts: []string{"api.example.com", "*.example.com", "api.example.com"}}, wantSame: true},
+		{name: "other method", route: &pb.Route{Path: "/api", Methods: []string{"GET"}, Hosts: []string{"api.example.com", "*.example.com"}}},
+		{name: "other host", route: &pb.Route{Path: "/api", Methods: []string{"GET", "POST"}, Hosts: []string{"api.example.com"}}},
+		{name: "other match type", route: &pb.Route{Path: "/api", PathMatch: pb.PathMatch_PATH_MATCH_EXACT, Methods: []string{"GET", "POST"}, Hosts: []string{"api.example.com", "*.example.com"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := matchSignature(tt.route) == matchSignature(base); same != tt.wantSame {
+				t.Fatalf("same signature = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
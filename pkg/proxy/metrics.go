@@ -1,13 +1,15 @@
 package proxy
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 type Metrics struct {
 
-	// Counter for the total number of requests (by service and status code)
+	// Counter for the total number of requests (by service, backend version and status code)
 	RequestsTotal *prometheus.CounterVec
 
 	// Tracks request latency distribution
@@ -18,20 +20,47 @@ type Metrics struct {
 
 	// Tracks the number of errors (by type)
 	ErrorsTotal *prometheus.CounterVec
+
+	// Health of each upstream endpoint (1 healthy, 0 unhealthy)
+	EndpointHealthy *prometheus.GaugeVec
+
+	// Circuit breaker state changes (by cluster, from and to state)
+	CircuitBreakerTransitions *prometheus.CounterVec
+
+	// Current circuit breaker state (1 for the active state label, 0 for the others)
+	CircuitBreakerState *prometheus.GaugeVec
+
+	// Endpoints ejected by outlier detection (by cluster, endpoint and reason)
+	OutlierEjectionsTotal *prometheus.CounterVec
+
+	// Whether an endpoint is currently ejected (1) or not (0)
+	EndpointEjected *prometheus.GaugeVec
+
+	// Retries sent to the backends (by service, attempt number and reason)
+	RetriesTotal *prometheus.CounterVec
+
+	// Retries not sent because the retry budget of the cluster was spent (by service)
+	RetryBudgetExhaustedTotal *prometheus.CounterVec
+
+	// Mirrored requests sent to shadow clusters (by service, shadow cluster and status code)
+	ShadowRequestsTotal *prometheus.CounterVec
+
+	// Latency of the shadow requests, to compare with RequestDuration
+	ShadowRequestDuration *prometheus.HistogramVec
 }
 
 func NewMetrics() *Metrics {
 
 	metrics := &Metrics{
 		// Counter for the total number of requests 
-		// Labeled by service (which backend) and status code
+		// Labeled by service (which backend), version (the cluster picked by a traffic split) and status code
 		// Using promauto to automatically register with the default registry
 		RequestsTotal: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "gomesh_requests_total",
 				Help: "Total number of requests received by the proxy",
 			},
-			[]string{"service", "status"},
+			[]string{"service", "version", "status"},
 		),
 
 		RequestDuration: promauto.NewHistogramVec(
@@ -57,28 +86,155 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"service", "error_type"},
 		),
+
+		EndpointHealthy: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gomesh_endpoint_healthy",
+				Help: "Whether an upstream endpoint is healthy (1) or not (0)",
+			},
+			[]string{"cluster", "endpoint"},
+		),
+
+		CircuitBreakerTransitions: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gomesh_circuit_breaker_transitions_total",
+				Help: "Total number of circuit breaker state changes",
+			},
+			[]string{"cluster", "from", "to"},
+		),
+
+		CircuitBreakerState: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gomesh_circuit_breaker_state",
+				Help: "Current circuit breaker state of a cluster (1 for the active state)",
+			},
+			[]string{"cluster", "state"},
+		),
+
+		OutlierEjectionsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gomesh_outlier_ejections_total",
+				Help: "Total number of endpoints ejected by outlier detection",
+			},
+			[]string{"cluster", "endpoint", "reason"},
+		),
+
+		EndpointEjected: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gomesh_endpoint_ejected",
+				Help: "Whether an upstream endpoint is ejected by outlier detection (1) or not (0)",
+			},
+			[]string{"cluster", "endpoint"},
+		),
+
+		RetriesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gomesh_retries_total",
+				Help: "Total number of retries, by attempt number and reason",
+			},
+			[]string{"service", "attempt", "reason"},
+		),
+
+		RetryBudgetExhaustedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gomesh_retry_budget_exhausted_total",
+				Help: "Total number of retries skipped because the cluster retry budget was spent",
+			},
+			[]string{"service"},
+		),
+
+		ShadowRequestsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gomesh_shadow_requests_total",
+				Help: "Total number of mirrored requests sent to shadow clusters",
+			},
+			[]string{"service", "cluster", "status"},
+		),
+
+		ShadowRequestDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "gomesh_shadow_request_duration_seconds",
+				Help: "Shadow requests duration in seconds",
+				Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+			},
+			[]string{"service", "cluster"},
+		),
 	}
 
 	return metrics
 }
 
-// Record a request (by service and status code)
-func (m *Metrics) RecordRequest(service string, statusCode int, durationSeconds float64) {
+// Record a request (by service, version and status code)
+func (m *Metrics) RecordRequest(service string, version string, statusCode int, durationSeconds float64) {
 
 	// Convert status code to string (bucket of response response type)
 	status := statusCodeToString(statusCode)
 
-	m.RequestsTotal.WithLabelValues(service, status).Inc()
+	m.RequestsTotal.WithLabelValues(service, version, status).Inc()
 
 	m.RequestDuration.WithLabelValues(service).Observe(durationSeconds)
 }
 
+// Labels of a request that are only known once it is routed
+// The metrics middleware creates them, the handler fills them in
+type requestLabels struct {
+	service string
+	version string
+}
+
+// Record a shadow request (status 0: it failed before a response)
+func (m *Metrics) RecordShadowRequest(service string, cluster string, statusCode int, durationSeconds float64) {
+	status := "error"
+	if statusCode != 0 {
+		status = statusCodeToString(statusCode)
+	}
+
+	m.ShadowRequestsTotal.WithLabelValues(service, cluster, status).Inc()
+	m.ShadowRequestDuration.WithLabelValues(service, cluster).Observe(durationSeconds)
+}
+
 // Record an error (by service and type)
 func (m *Metrics) RecordError(service string, errorType string) {
 
 	m.ErrorsTotal.WithLabelValues(service, errorType).Inc()
 }
 
+// Record the health of an endpoint
+func (m *Metrics) SetEndpointHealth(cluster string, endpoint string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	m.EndpointHealthy.WithLabelValues(cluster, endpoint).Set(value)
+}
+
+// Forget an endpoint that is no longer part of the config
+func (m *Metrics) DeleteEndpointHealth(cluster string, endpoint string) {
+	m.EndpointHealthy.DeleteLabelValues(cluster, endpoint)
+}
+
+// Record a circuit breaker state change
+func (m *Metrics) RecordCircuitBreakerTransition(cluster string, from string, to string) {
+	m.CircuitBreakerTransitions.WithLabelValues(cluster, from, to).Inc()
+	m.SetCircuitBreakerState(cluster, to)
+}
+
+// Set the active circuit breaker state of a cluster
+func (m *Metrics) SetCircuitBreakerState(cluster string, state string) {
+	for _, s := range []string{"closed", "open", "half_open"} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		m.CircuitBreakerState.WithLabelValues(cluster, s).Set(value)
+	}
+}
+
+// Forget the circuit breaker of a cluster that is no longer part of the config
+func (m *Metrics) DeleteCircuitBreakerState(cluster string) {
+	m.CircuitBreakerState.DeletePartialMatch(prometheus.Labels{"cluster": cluster})
+}
+
 // Increment the number of requests in flight
 func (m *Metrics) IncInFlight() {
 	m.RequestsInFlight.Inc()
@@ -102,4 +258,34 @@ func statusCodeToString(statusCode int) string {
 		return "5xx"
 	}
 	return "unknown"
-}
\ No newline at end of file
+}
+
+// Record an outlier ejection
+func (m *Metrics) RecordOutlierEjection(cluster string, endpoint string, reason string) {
+	m.OutlierEjectionsTotal.WithLabelValues(cluster, endpoint, reason).Inc()
+	m.SetEndpointEjected(cluster, endpoint, true)
+}
+
+// Record whether an endpoint is ejected
+func (m *Metrics) SetEndpointEjected(cluster string, endpoint string, ejected bool) {
+	value := 0.0
+	if ejected {
+		value = 1
+	}
+	m.EndpointEjected.WithLabelValues(cluster, endpoint).Set(value)
+}
+
+// Forget the ejection state of an endpoint that is no longer part of the config
+func (m *Metrics) DeleteEndpointEjected(cluster string, endpoint string) {
+	m.EndpointEjected.DeleteLabelValues(cluster, endpoint)
+}
+
+// Record a retry (attempt is 2 for the first retry)
+func (m *Metrics) RecordRetry(service string, attempt int, reason string) {
+	m.RetriesTotal.WithLabelValues(service, strconv.Itoa(attempt), reason).Inc()
+}
+
+// Record a retry skipped because the retry budget was spent
+func (m *Metrics) RecordRetryBudgetExhausted(service string) {
+	m.RetryBudgetExhaustedTotal.WithLabelValues(service).Inc()
+}
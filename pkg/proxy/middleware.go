@@ -38,6 +38,11 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 
 }
 
+// Unwrap gives http.ResponseController access to the original writer (e.g., to flush streams)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (rw *responseWriter) Write(data []byte) (int, error) {
 	// if the header hasn't been written yet, write it with the default status code
 	// It must be written before calling Write()! (this is a Go requirement)
@@ -120,14 +125,16 @@ func MetricsMiddleware(metrics *Metrics, next http.Handler) http.Handler {
 
 		wrappedWriter := newResponseWriter(w)
 
-		next.ServeHTTP(wrappedWriter, r)
+		// Requests that match no route keep the defaults
+		labels := &requestLabels{service: "unknown"}
+
+		next.ServeHTTP(wrappedWriter, r.WithContext(withRequestLabels(r.Context(), labels)))
 
 		// In Seconds to be compatible with Prometheus (which uses seconds for the histogram)
 		duration := time.Since(startTime).Seconds()
 
 		// Record the request metrics
-		// TODO: get the service name from the request header
-		metrics.RecordRequest("backend", wrappedWriter.statusCode, duration)
+		metrics.RecordRequest(labels.service, labels.version, wrappedWriter.statusCode, duration)
 	})
 }
 
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// Defaults for the fields left empty in a RequestMirror
+const (
+	defaultMirrorPercent = 100.0
+	defaultMirrorMaxBodyBytes = 64 * 1024
+)
+
+// Shadow requests of routes without a timeout give up after this long
+const defaultMirrorTimeout = 10 * time.Second
+
+// Shadow requests in flight across all routes, more are dropped so a slow shadow can't pile up goroutines
+const maxMirrorsInFlight = 256
+
+// Shadow responses are read up to this size (so the connection can be reused), then dropped
+const maxMirrorResponseDrain = 64 * 1024
+
+// mirrorPolicy is the validated form of a route RequestMirror
+type mirrorPolicy struct {
+	cluster *Cluster
+	balancer LoadBalancer
+	percent float64
+	maxBodyBytes int64
+}
+
+// Build the mirror policy of a route (nil when the route doesn't mirror)
+func (t *RouteTable) newMirrorPolicy(config *pb.RequestMirror) (*mirrorPolicy, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	cluster, ok := t.clusters[config.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %q", config.Cluster)
+	}
+
+	if err := validatePercent(config.GetPercent()); err != nil {
+		return nil, err
+	}
+
+	if config.MaxBodyBytes < 0 {
+		return nil, fmt.Errorf("max_body_bytes must not be negative")
+	}
+
+	// Unset mirrors everything, 0 mirrors nothing
+	percent := defaultMirrorPercent
+	if config.Percent != nil {
+		percent = *config.Percent
+	}
+
+	return &mirrorPolicy{
+		cluster: cluster,
+		balancer: &roundRobinBalancer{endpoints: cluster.endpoints},
+		percent: percent,
+		maxBodyBytes: int64(intOrDefault(config.MaxBodyBytes, defaultMirrorMaxBodyBytes)),
+	}, nil
+}
+
+// mirrorSender sends the shadow requests, on connections of its own
+type mirrorSender struct {
+	client *http.Client
+	slots chan struct{}
+	logger *logging.Logger
+	metrics *Metrics
+}
+
+func newMirrorSender(logger *logging.Logger, metrics *Metrics, mesh *meshTransport) *mirrorSender {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	return &mirrorSender{
+		client: &http.Client{
+			Transport: newUpstreamTransport(transport, mesh),
+			// The shadow response is discarded anyway
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		slots: make(chan struct{}, maxMirrorsInFlight),
+		logger: logger,
+		metrics: metrics,
+	}
+}
+
+// Copy the request to the shadow cluster of the route, in the background
+// The body is buffered (up to the limit) so the primary request can still send it
+// Only fails when the client body can't be read, the primary request couldn't be sent either
+func (m *mirrorSender) mirror(r *http.Request, rt *route) error {
+	policy := rt.mirror
+
+	if !sampled(policy.percent) {
+		return nil
+	}
+
+	// Protocol upgrades (e.g., WebSocket) can't be replayed
+	if r.Header.Get("Upgrade") != "" {
+		return nil
+	}
+
+	replay, err := bufferBody(r, policy.maxBodyBytes)
+	if err != nil {
+		return err
+	}
+	if replay == nil {
+		m.metrics.RecordError(rt.service(), "mirror_body_too_large")
+		return nil
+	}
+	r.Body = replay()
+
+	endpoint := policy.balancer.Pick(r)
+	if endpoint == nil {
+		m.metrics.RecordError(rt.service(), "mirror_no_endpoint")
+		return nil
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.metrics.RecordError(rt.service(), "mirror_overflow")
+		return nil
+	}
+
+	// The shadow request outlives the primary one, it must not be canceled with it
+	timeout := defaultMirrorTimeout
+	if rt.config.TimeoutMs > 0 {
+		timeout = time.Duration(rt.config.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+	ctx = withEndpoint(ctx, endpoint)
+
+	// Sent like the primary request would be (see the reverse proxy Director)
+	shadow := r.Clone(ctx)
+	shadow.RequestURI = ""
+	shadow.Body = replay()
+	setForwardedHeaders(shadow)
+	appendForwardedFor(shadow)
+	shadow.URL.Scheme = endpoint.url.Scheme
+	shadow.URL.Host = endpoint.url.Host
+	if rt.rewrite != nil {
+		rt.rewrite.apply(shadow, endpoint)
+	}
+	applyHeaderPolicies(rt.requestHeaders, shadow.Header, newHeaderVars(shadow, rt, endpoint))
+	shadow.Host = shadowHost(shadow.Host)
+	setTimeoutHeader(shadow)
+
+	go func() {
+		defer func() { <- m.slots }()
+		defer cancel()
+		m.send(shadow, rt, endpoint)
+	}()
+
+	return nil
+}
+
+// Send the shadow request and record how it went, the response is thrown away
+func (m *mirrorSender) send(shadow *http.Request, rt *route, endpoint *Endpoint) {
+	start := time.Now()
+
+	resp, err := m.client.Do(shadow)
+	if err != nil {
+		m.logger.Debug("shadow request failed",
+			zap.Error(err),
+			zap.String("cluster", rt.mirror.cluster.name),
+			zap.String("endpoint", endpoint.address),
+			zap.String("trace_id", tracing.GetTraceID(shadow)),
+		)
+		m.metrics.RecordShadowRequest(rt.service(), rt.mirror.cluster.name, 0, time.Since(start).Seconds())
+		return
+	}
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxMirrorResponseDrain))
+	resp.Body.Close()
+
+	m.metrics.RecordShadowRequest(rt.service(), rt.mirror.cluster.name, resp.StatusCode, time.Since(start).Seconds())
+}
+
+// Host of the shadow request, "-shadow" is added to the name so the backend can tell (e.g., "api-shadow:8080")
+func shadowHost(host string) string {
+	if name, port, err := net.SplitHostPort(host); err == nil {
+		return net.JoinHostPort(name+"-shadow", port)
+	}
+	return host + "-shadow"
+}
@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+// Server name mesh clients ask for, the listener answers them with the workload certificate instead of a public one
+// Peers are checked against the mesh CA, not against this name
+const meshServerName = "gomesh.mesh"
+
+// workloadIdentity is the certificate the control plane issued to this proxy, with the mesh CAs
+// It is replaced on every renewal, handshakes always use the latest one
+type workloadIdentity struct {
+	current atomic.Pointer[workloadCert]
+}
+
+type workloadCert struct {
+	cert *tls.Certificate
+
+	// CAs the peers must chain to
+	roots *x509.CertPool
+}
+
+// Parse the certificate of a config update (nil when the update has none)
+func parseWorkloadCertificate(config *pb.WorkloadCertificate) (*workloadCert, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	cert, err := tls.X509KeyPair([]byte(config.CertPem), []byte(config.KeyPem))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workload certificate: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(config.CaPem)) {
+		return nil, fmt.Errorf("invalid workload certificate: no CA certificate")
+	}
+
+	return &workloadCert{cert: &cert, roots: roots}, nil
+}
+
+// Identity of this proxy
+func (c *workloadCert) identity() string {
+	return spiffeID(c.cert.Leaf)
+}
+
+// SPIFFE ID of a certificate: its spiffe:// URI SAN (empty when it has none)
+func spiffeID(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	return ""
+}
+
+// Mesh clusters must say which identity their endpoints present, any certificate of the mesh CA is not enough
+func validateUpstreamIdentity(mtls bool, identity string) error {
+	if !mtls {
+		if identity != "" {
+			return fmt.Errorf("identity is only valid with mtls")
+		}
+		return nil
+	}
+
+	if identity == "" {
+		return fmt.Errorf("mtls needs the identity the endpoints present")
+	}
+
+	id, err := url.Parse(identity)
+	if err != nil || id.Scheme != "spiffe" || id.Host == "" || id.Path == "" || id.RawQuery != "" || id.Fragment != "" {
+		return fmt.Errorf("invalid identity %q (e.g., spiffe://gomesh/ns/default/sa/orders)", identity)
+	}
+	return nil
+}
+
+func (w *workloadIdentity) set(cert *workloadCert) {
+	w.current.Store(cert)
+}
+
+// The certificate to present, failing the handshake until the control plane sent one
+func (w *workloadIdentity) certificate() (*tls.Certificate, error) {
+	current := w.current.Load()
+	if current == nil {
+		return nil, fmt.Errorf("no workload certificate received from the control plane yet")
+	}
+	return current.cert, nil
+}
+
+// Check that the peer certificate was issued by the mesh CA for usage, to a SPIFFE ID of our trust domain
+func (w *workloadIdentity) verifyPeer(state tls.ConnectionState, usage x509.ExtKeyUsage) error {
+	current := w.current.Load()
+	if current == nil {
+		return fmt.Errorf("no workload certificate received from the control plane yet")
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("peer sent no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots: current.roots,
+		Intermediates: intermediates,
+		KeyUsages: []x509.ExtKeyUsage{usage},
+	})
+	if err != nil {
+		return fmt.Errorf("peer is not part of the mesh: %w", err)
+	}
+
+	// Principals in authorization policies are SPIFFE IDs of our trust domain, a peer without one can't be told apart
+	peer := spiffeID(state.PeerCertificates[0])
+	if peer == "" || trustDomain(peer) != trustDomain(current.identity()) {
+		return fmt.Errorf("peer identity %q is not in the trust domain of the mesh", peer)
+	}
+	return nil
+}
+
+// Trust domain of a SPIFFE ID ("gomesh" in spiffe://gomesh/ns/default/sa/orders, empty if it doesn't parse)
+func trustDomain(id string) string {
+	parsed, err := url.Parse(id)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// TLS config to connect to the endpoints of mesh clusters presenting identity
+func (w *workloadIdentity) clientTLSConfig(identity string) *tls.Config {
+	return &tls.Config{
+		ServerName: meshServerName,
+		MinVersion: tls.VersionTLS13,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return w.certificate()
+		},
+		// The standard check wants a host name: the peer is checked against the current mesh CA instead
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if err := w.verifyPeer(state, x509.ExtKeyUsageServerAuth); err != nil {
+				return err
+			}
+
+			// Any proxy of the mesh chains to the CA, only the one of the cluster may answer for it
+			if peer := spiffeID(state.PeerCertificates[0]); peer != identity {
+				return fmt.Errorf("upstream identity is %q, the cluster expects %q", peer, identity)
+			}
+			return nil
+		},
+	}
+}
+
+// Listener config for the mesh clients, the others get the config of the listener (nil)
+func (w *workloadIdentity) getConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	if hello.ServerName != meshServerName {
+		return nil, nil
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return w.certificate()
+		},
+		// Checked against the current mesh CA, which a static ClientCAs can't follow
+		ClientAuth: tls.RequireAnyClientCert,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return w.verifyPeer(state, x509.ExtKeyUsageClientAuth)
+		},
+	}, nil
+}
+
+// upstreamTransport sends the requests to mesh endpoints over mTLS, the others as before
+// The endpoint is read from the request context
+type upstreamTransport struct {
+	plain http.RoundTripper
+	mesh *meshTransport
+}
+
+func newUpstreamTransport(plain http.RoundTripper, mesh *meshTransport) *upstreamTransport {
+	return &upstreamTransport{plain: plain, mesh: mesh}
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if endpoint := endpointFromContext(req.Context()); endpoint != nil && endpoint.mtls {
+		return t.mesh.forIdentity(endpoint.identity).RoundTrip(req)
+	}
+	return t.plain.RoundTrip(req)
+}
+
+// meshTransport connects to the mesh clusters, shared by everything that talks to them
+// Each upstream identity has its own pool, so a connection checked for one identity never serves another
+type meshTransport struct {
+	workload *workloadIdentity
+
+	mu sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func newMeshTransport(workload *workloadIdentity) *meshTransport {
+	return &meshTransport{
+		workload: workload,
+		transports: make(map[string]*http.Transport),
+	}
+}
+
+// Transport to the endpoints presenting identity, created on first use
+func (t *meshTransport) forIdentity(identity string) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	transport, ok := t.transports[identity]
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = t.workload.clientTLSConfig(identity)
+		t.transports[identity] = transport
+	}
+	return transport
+}
+
+// Drop the pools of the identities no cluster expects anymore
+func (t *meshTransport) retain(identities map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for identity, transport := range t.transports {
+		if !identities[identity] {
+			transport.CloseIdleConnections()
+			delete(t.transports, identity)
+		}
+	}
+}
+
+// Close the idle connections of every pool
+func (t *meshTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, transport := range t.transports {
+		transport.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/controlplane"
+)
+
+// CAs sharing the same key and certificate, one for each trust domain
+func newTestCertificateAuthorities(t *testing.T, trustDomains ...string) []*controlplane.CertificateAuthority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{CommonName: "test CA"},
+		NotBefore: time.Now().Add(-time.Minute),
+		NotAfter: time.Now().Add(time.Hour),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	var cas []*controlplane.CertificateAuthority
+	for _, trustDomain := range trustDomains {
+		ca, err := controlplane.NewCertificateAuthority(certPEM, keyPEM, trustDomain, time.Hour)
+		if err != nil {
+			t.Fatalf("NewCertificateAuthority: %v", err)
+		}
+		cas = append(cas, ca)
+	}
+	return cas
+}
+
+// Workload identity of a proxy running as serviceAccount, issued by ca
+func newTestWorkload(t *testing.T, ca *controlplane.CertificateAuthority, serviceAccount string) *workloadIdentity {
+	t.Helper()
+
+	config, _, err := ca.Issue(&pb.ProxyInfo{ProxyId: serviceAccount, ServiceAccount: serviceAccount})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	cert, err := parseWorkloadCertificate(config)
+	if err != nil {
+		t.Fatalf("parseWorkloadCertificate: %v", err)
+	}
+
+	workload := &workloadIdentity{}
+	workload.set(cert)
+	return workload
+}
+
+func TestMeshTransportChecksUpstreamIdentity(t *testing.T) {
+	ca, err := controlplane.GenerateCertificateAuthority("gomesh", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateCertificateAuthority: %v", err)
+	}
+
+	// The upstream proxy runs as orders, the client as checkout
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	upstream.EnableHTTP2 = true
+	upstream.TLS = &tls.Config{GetConfigForClient: newTestWorkload(t, ca, "orders").getConfigForClient}
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	mesh := newMeshTransport(newTestWorkload(t, ca, "checkout"))
+	defer mesh.CloseIdleConnections()
+
+	tests := []struct {
+		name string
+		identity string
+		wantErr string
+	}{
+		{name: "expected identity", identity: "spiffe://gomesh/ns/default/sa/orders"},
+		{name: "other service", identity: "spiffe://gomesh/ns/default/sa/payments", wantErr: "the cluster expects"},
+		{name: "other trust domain", identity: "spiffe://other/ns/default/sa/orders", wantErr: "the cluster expects"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := &Endpoint{address: upstream.Listener.Addr().String(), mtls: true, identity: tt.identity}
+			req := httptest.NewRequest("GET", "https://"+endpoint.address+"/", nil)
+			req.RequestURI = ""
+			req = req.WithContext(withEndpoint(req.Context(), endpoint))
+
+			resp, err := newUpstreamTransport(http.DefaultTransport, mesh).RoundTrip(req)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("RoundTrip: %v", err)
+				}
+				resp.Body.Close()
+				return
+			}
+
+			if err == nil {
+				resp.Body.Close()
+				t.Fatal("RoundTrip reached an upstream with another identity")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("RoundTrip: error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUpstreamIdentity(t *testing.T) {
+	tests := []struct {
+		name string
+		mtls bool
+		identity string
+		wantErr bool
+	}{
+		{name: "plain cluster", mtls: false},
+		{name: "mesh cluster", mtls: true, identity: "spiffe://gomesh/ns/default/sa/orders"},
+		{name: "mesh cluster without identity", mtls: true, wantErr: true},
+		{name: "identity without mtls", mtls: false, identity: "spiffe://gomesh/ns/default/sa/orders", wantErr: true},
+		{name: "not a SPIFFE ID", mtls: true, identity: "https://gomesh/ns/default/sa/orders", wantErr: true},
+		{name: "no path", mtls: true, identity: "spiffe://gomesh", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateUpstreamIdentity(tt.mtls, tt.identity); (err != nil) != tt.wantErr {
+				t.Fatalf("validateUpstreamIdentity: error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyPeerTrustDomain(t *testing.T) {
+	cas := newTestCertificateAuthorities(t, "gomesh", "other")
+	unrelated, err := controlplane.GenerateCertificateAuthority("gomesh", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateCertificateAuthority: %v", err)
+	}
+
+	local := newTestWorkload(t, cas[0], "orders")
+
+	tests := []struct {
+		name string
+		peer *workloadIdentity
+		wantErr bool
+	}{
+		{name: "same trust domain", peer: newTestWorkload(t, cas[0], "checkout")},
+		{name: "same CA, other trust domain", peer: newTestWorkload(t, cas[1], "checkout"), wantErr: true},
+		{name: "other CA", peer: newTestWorkload(t, unrelated, "checkout"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.peer.current.Load().cert.Leaf}}
+			if err := local.verifyPeer(state, x509.ExtKeyUsageClientAuth); (err != nil) != tt.wantErr {
+				t.Fatalf("verifyPeer: error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
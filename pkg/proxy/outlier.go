@@ -0,0 +1,316 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Defaults for the fields left empty in an OutlierDetection
+const (
+	defaultOutlierInterval = 10 * time.Second
+	defaultBaseEjectionTime = 30 * time.Second
+	defaultMaxEjectionTime = 300 * time.Second
+	defaultMaxEjectionPercent = 10
+	defaultLatencyMinRequests = 10
+)
+
+// Why an endpoint was ejected (also the reason label in metrics)
+const (
+	ejectServerErrors = "consecutive_server_errors"
+	ejectGatewayFailures = "consecutive_gateway_failures"
+	ejectLatency = "latency"
+)
+
+// outlierDetector ejects the endpoints of a cluster that misbehave on real traffic
+// Errors are counted on every response, latency is compared across endpoints once per interval
+type outlierDetector struct {
+	cluster *Cluster
+	consecutiveServerErrors int
+	consecutiveGatewayFailures int
+	latencyFactor float64
+	latencyMinRequests int
+	interval time.Duration
+	baseEjectionTime time.Duration
+	maxEjectionTime time.Duration
+	maxEjectionPercent int
+
+	// Built once with the cluster, only the values change
+	mu sync.Mutex
+	stats map[*Endpoint]*outlierStats
+
+	// Clock of the ejections (time.Now, except in tests)
+	now func() time.Time
+
+	// Set when the cluster starts serving
+	logger *logging.Logger
+	metrics *Metrics
+	cancel context.CancelFunc
+	wg sync.WaitGroup
+}
+
+// What the detector knows about one endpoint
+type outlierStats struct {
+	serverErrors int
+	gatewayFailures int
+
+	// Latency observed in the current interval
+	requests int
+	totalLatency time.Duration
+
+	// Ejections in a row, each one doubles the ejection time
+	// Decreased for every interval the endpoint spends in load balancing
+	ejections int
+	ejected bool
+}
+
+// Build an outlier detector (nil when the config doesn't enable anything)
+func newOutlierDetector(cluster *Cluster, config *pb.OutlierDetection) (*outlierDetector, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	if config.ConsecutiveServerErrors < 0 || config.ConsecutiveGatewayFailures < 0 || config.LatencyMinRequests < 0 ||
+		config.IntervalMs < 0 || config.BaseEjectionTimeMs < 0 || config.MaxEjectionTimeMs < 0 {
+		return nil, fmt.Errorf("thresholds and durations must not be negative")
+	}
+
+	if config.LatencyFactor != 0 && config.LatencyFactor <= 1 {
+		return nil, fmt.Errorf("latency_factor %v must be greater than 1", config.LatencyFactor)
+	}
+
+	if config.MaxEjectionPercent < 0 || config.MaxEjectionPercent > 100 {
+		return nil, fmt.Errorf("max_ejection_percent %d must be between 1 and 100", config.MaxEjectionPercent)
+	}
+
+	if config.ConsecutiveServerErrors == 0 && config.ConsecutiveGatewayFailures == 0 && config.LatencyFactor == 0 {
+		return nil, nil
+	}
+
+	detector := &outlierDetector{
+		cluster: cluster,
+		consecutiveServerErrors: int(config.ConsecutiveServerErrors),
+		consecutiveGatewayFailures: int(config.ConsecutiveGatewayFailures),
+		latencyFactor: config.LatencyFactor,
+		latencyMinRequests: intOrDefault(config.LatencyMinRequests, defaultLatencyMinRequests),
+		interval: durationOrDefault(config.IntervalMs, defaultOutlierInterval),
+		baseEjectionTime: durationOrDefault(config.BaseEjectionTimeMs, defaultBaseEjectionTime),
+		maxEjectionTime: durationOrDefault(config.MaxEjectionTimeMs, defaultMaxEjectionTime),
+		maxEjectionPercent: intOrDefault(config.MaxEjectionPercent, defaultMaxEjectionPercent),
+		stats: make(map[*Endpoint]*outlierStats, len(cluster.endpoints)),
+		now: time.Now,
+	}
+
+	if detector.maxEjectionTime < detector.baseEjectionTime {
+		return nil, fmt.Errorf("max_ejection_time_ms must not be lower than base_ejection_time_ms")
+	}
+
+	for _, endpoint := range cluster.endpoints {
+		detector.stats[endpoint] = &outlierStats{}
+	}
+
+	return detector, nil
+}
+
+// Count the outcome of a request forwarded to the endpoint
+// status is the code sent back to the client, including the 502/504 written by the ErrorHandler
+func (od *outlierDetector) record(endpoint *Endpoint, status int, latency time.Duration) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	stats := od.stats[endpoint]
+	if stats == nil || stats.ejected {
+		return
+	}
+
+	stats.requests++
+	stats.totalLatency += latency
+
+	if status >= 500 {
+		stats.serverErrors++
+	} else {
+		stats.serverErrors = 0
+	}
+
+	if status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout {
+		stats.gatewayFailures++
+	} else {
+		stats.gatewayFailures = 0
+	}
+
+	switch {
+	case od.consecutiveGatewayFailures > 0 && stats.gatewayFailures >= od.consecutiveGatewayFailures:
+		od.eject(endpoint, stats, ejectGatewayFailures)
+	case od.consecutiveServerErrors > 0 && stats.serverErrors >= od.consecutiveServerErrors:
+		od.eject(endpoint, stats, ejectServerErrors)
+	}
+}
+
+// Take the endpoint out of load balancing, unless too much of the cluster is already out
+// (caller must hold the lock)
+func (od *outlierDetector) eject(endpoint *Endpoint, stats *outlierStats, reason string) bool {
+
+	ejected := 0
+	for _, s := range od.stats {
+		if s.ejected {
+			ejected++
+		}
+	}
+
+	// Safety cap: a cluster-wide problem must not empty the cluster
+	if ejected*100 >= od.maxEjectionPercent*len(od.stats) {
+		stats.serverErrors, stats.gatewayFailures = 0, 0
+		if od.logger != nil {
+			od.logger.Warn("outlier not ejected, max ejection percent reached",
+				zap.String("endpoint", endpoint.address),
+				zap.String("reason", reason),
+				zap.Int("ejected", ejected),
+			)
+		}
+		return false
+	}
+
+	// base, 2x base, 4x base... up to the max
+	duration := od.baseEjectionTime
+	for i := 0; i < stats.ejections && duration < od.maxEjectionTime; i++ {
+		duration *= 2
+	}
+	if duration > od.maxEjectionTime {
+		duration = od.maxEjectionTime
+	}
+
+	stats.ejections++
+	stats.ejected = true
+	stats.serverErrors, stats.gatewayFailures = 0, 0
+	stats.requests, stats.totalLatency = 0, 0
+	endpoint.ejectedUntil.Store(od.now().Add(duration).UnixNano())
+
+	if od.metrics != nil {
+		od.metrics.RecordOutlierEjection(od.cluster.name, endpoint.address, reason)
+	}
+	if od.logger != nil {
+		od.logger.Warn("endpoint ejected as an outlier",
+			zap.String("endpoint", endpoint.address),
+			zap.String("reason", reason),
+			zap.Duration("duration", duration),
+			zap.Int("ejections", stats.ejections),
+		)
+	}
+
+	return true
+}
+
+// Start the periodic analysis (latency and expired ejections)
+func (od *outlierDetector) start(logger *logging.Logger, metrics *Metrics) {
+	od.logger = logger.With(zap.String("cluster", od.cluster.name))
+	od.metrics = metrics
+
+	ctx, cancel := context.WithCancel(context.Background())
+	od.cancel = cancel
+
+	od.wg.Add(1)
+	go func() {
+		defer od.wg.Done()
+
+		ticker := time.NewTicker(od.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <- ctx.Done():
+				return
+			case <- ticker.C:
+				od.analyze()
+			}
+		}
+	}()
+}
+
+func (od *outlierDetector) stop() {
+	if od.cancel != nil {
+		od.cancel()
+		od.wg.Wait()
+	}
+}
+
+// Bring back the endpoints whose ejection expired, then look for latency outliers
+func (od *outlierDetector) analyze() {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	now := od.now()
+
+	for endpoint, stats := range od.stats {
+		if stats.ejected {
+			if endpoint.Ejected(now) {
+				continue
+			}
+
+			stats.ejected = false
+			od.metrics.SetEndpointEjected(od.cluster.name, endpoint.address, false)
+			od.logger.Info("endpoint back in load balancing after ejection",
+				zap.String("endpoint", endpoint.address),
+			)
+			continue
+		}
+
+		// A good interval makes the next ejection shorter
+		if stats.ejections > 0 {
+			stats.ejections--
+		}
+	}
+
+	if od.latencyFactor > 0 {
+		od.analyzeLatency()
+	}
+
+	for _, stats := range od.stats {
+		stats.requests, stats.totalLatency = 0, 0
+	}
+}
+
+// Eject the endpoints much slower than the rest of the cluster (caller must hold the lock)
+// Only endpoints with enough requests in the interval are compared, and at least two are needed
+func (od *outlierDetector) analyzeLatency() {
+
+	means := make(map[*Endpoint]time.Duration)
+	var total time.Duration
+
+	for endpoint, stats := range od.stats {
+		if stats.ejected || stats.requests < od.latencyMinRequests {
+			continue
+		}
+		mean := stats.totalLatency / time.Duration(stats.requests)
+		means[endpoint] = mean
+		total += mean
+	}
+
+	if len(means) < 2 {
+		return
+	}
+
+	clusterMean := total / time.Duration(len(means))
+	threshold := time.Duration(float64(clusterMean) * od.latencyFactor)
+
+	for endpoint, mean := range means {
+		if mean > threshold {
+			od.eject(endpoint, od.stats[endpoint], ejectLatency)
+		}
+	}
+}
+
+// Export the ejection state of every endpoint
+func (od *outlierDetector) exportState(metrics *Metrics) {
+	od.mu.Lock()
+	defer od.mu.Unlock()
+
+	for endpoint, stats := range od.stats {
+		metrics.SetEndpointEjected(od.cluster.name, endpoint.address, stats.ejected)
+	}
+}
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+func newTestOutlierDetector(t *testing.T, endpoints int, config *pb.OutlierDetection) (*outlierDetector, *fakeClock) {
+	t.Helper()
+
+	addresses := []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80", "10.0.0.4:80"}[:endpoints]
+	cluster, err := newCluster(&pb.Cluster{Name: "backend", Endpoints: addresses, OutlierDetection: config})
+	if err != nil {
+		t.Fatalf("newCluster: %v", err)
+	}
+	if cluster.outlier == nil {
+		t.Fatal("newCluster built no outlier detector")
+	}
+
+	clock := newFakeClock()
+	od := cluster.outlier
+	od.now = clock.Now
+	od.logger = newTestLogger(t)
+	od.metrics = testMetrics
+	return od, clock
+}
+
+// Send the same status n times to the endpoint
+func recordN(od *outlierDetector, endpoint *Endpoint, status int, n int) {
+	for i := 0; i < n; i++ {
+		od.record(endpoint, status, 10*time.Millisecond)
+	}
+}
+
+func TestNewOutlierDetector(t *testing.T) {
+	tests := []struct {
+		name string
+		config *pb.OutlierDetection
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "unset", config: nil, wantNil: true},
+		{name: "nothing enabled", config: &pb.OutlierDetection{IntervalMs: 1000}, wantNil: true},
+		{name: "gateway failures", config: &pb.OutlierDetection{ConsecutiveGatewayFailures: 5}},
+		{name: "latency factor too low", config: &pb.OutlierDetection{LatencyFactor: 1}, wantErr: true},
+		{name: "max ejection percent above 100", config: &pb.OutlierDetection{ConsecutiveServerErrors: 5, MaxEjectionPercent: 101}, wantErr: true},
+		{name: "max below base ejection time", config: &pb.OutlierDetection{ConsecutiveServerErrors: 5, BaseEjectionTimeMs: 2000, MaxEjectionTimeMs: 1000}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &Cluster{name: "backend", endpoints: []*Endpoint{{address: "10.0.0.1:80"}}}
+			od, err := newOutlierDetector(cluster, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newOutlierDetector: error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (od == nil) != tt.wantNil {
+				t.Fatalf("newOutlierDetector: detector = %v, wantNil %v", od, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestOutlierConsecutiveFailures(t *testing.T) {
+	od, clock := newTestOutlierDetector(t, 4, &pb.OutlierDetection{
+		ConsecutiveServerErrors: 5,
+		ConsecutiveGatewayFailures: 3,
+		MaxEjectionPercent: 100,
+	})
+	endpoints := od.cluster.endpoints
+
+	// A success in between starts the count over
+	recordN(od, endpoints[0], http.StatusBadGateway, 2)
+	recordN(od, endpoints[0], http.StatusOK, 1)
+	recordN(od, endpoints[0], http.StatusBadGateway, 2)
+	if endpoints[0].Ejected(clock.Now()) {
+		t.Fatal("endpoint ejected without 3 gateway failures in a row")
+	}
+	recordN(od, endpoints[0], http.StatusServiceUnavailable, 1)
+	if !endpoints[0].Ejected(clock.Now()) {
+		t.Fatal("endpoint not ejected after 3 gateway failures in a row")
+	}
+
+	// 500 is a server error but not a gateway failure
+	recordN(od, endpoints[1], http.StatusInternalServerError, 4)
+	if endpoints[1].Ejected(clock.Now()) {
+		t.Fatal("endpoint ejected after 4 server errors")
+	}
+	recordN(od, endpoints[1], http.StatusInternalServerError, 1)
+	if !endpoints[1].Ejected(clock.Now()) {
+		t.Fatal("endpoint not ejected after 5 server errors in a row")
+	}
+
+	// 4xx says nothing about the endpoint
+	recordN(od, endpoints[2], http.StatusNotFound, 10)
+	if endpoints[2].Ejected(clock.Now()) {
+		t.Fatal("endpoint ejected for client errors")
+	}
+}
+
+func TestOutlierEjectionTimeDoubles(t *testing.T) {
+	od, clock := newTestOutlierDetector(t, 2, &pb.OutlierDetection{
+		ConsecutiveGatewayFailures: 1,
+		BaseEjectionTimeMs: 10000,
+		MaxEjectionTimeMs: 35000,
+		MaxEjectionPercent: 100,
+	})
+	endpoint := od.cluster.endpoints[0]
+
+	// Ejected for base, 2x base, then capped by the max
+	for _, want := range []time.Duration{10 * time.Second, 20 * time.Second, 35 * time.Second, 35 * time.Second} {
+		recordN(od, endpoint, http.StatusBadGateway, 1)
+
+		if !endpoint.Ejected(clock.Now().Add(want - time.Millisecond)) {
+			t.Fatalf("endpoint back before %v", want)
+		}
+		if endpoint.Ejected(clock.Now().Add(want)) {
+			t.Fatalf("endpoint still ejected after %v", want)
+		}
+
+		// Responses while ejected are not counted
+		recordN(od, endpoint, http.StatusBadGateway, 3)
+
+		clock.Advance(want)
+		od.analyze()
+		if od.stats[endpoint].ejected {
+			t.Fatalf("endpoint not back in load balancing after %v", want)
+		}
+	}
+
+	// Good intervals make the next ejection shorter again
+	for i := 0; i < 3; i++ {
+		clock.Advance(od.interval)
+		od.analyze()
+	}
+	recordN(od, endpoint, http.StatusBadGateway, 1)
+	if endpoint.Ejected(clock.Now().Add(20 * time.Second)) {
+		t.Fatal("ejection time not reduced after good intervals")
+	}
+	if !endpoint.Ejected(clock.Now().Add(20*time.Second - time.Millisecond)) {
+		t.Fatal("ejection time reduced too much after good intervals")
+	}
+}
+
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	od, clock := newTestOutlierDetector(t, 4, &pb.OutlierDetection{
+		ConsecutiveGatewayFailures: 1,
+		MaxEjectionPercent: 50,
+	})
+	endpoints := od.cluster.endpoints
+
+	for _, endpoint := range endpoints {
+		recordN(od, endpoint, http.StatusBadGateway, 1)
+	}
+
+	ejected := 0
+	for _, endpoint := range endpoints {
+		if endpoint.Ejected(clock.Now()) {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Fatalf("%d endpoints ejected, want 2 (50%% of 4)", ejected)
+	}
+
+	// The ones kept in have their counters reset, they must fail again to be ejected
+	if stats := od.stats[endpoints[3]]; stats.gatewayFailures != 0 {
+		t.Fatalf("gateway failures of a capped endpoint = %d, want 0", stats.gatewayFailures)
+	}
+}
+
+func TestOutlierLatency(t *testing.T) {
+	od, clock := newTestOutlierDetector(t, 4, &pb.OutlierDetection{
+		LatencyFactor: 2,
+		LatencyMinRequests: 3,
+		MaxEjectionPercent: 100,
+	})
+	endpoints := od.cluster.endpoints
+
+	for _, endpoint := range endpoints[:3] {
+		for i := 0; i < 3; i++ {
+			od.record(endpoint, http.StatusOK, 10*time.Millisecond)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		od.record(endpoints[3], http.StatusOK, 100*time.Millisecond)
+	}
+
+	od.analyze()
+
+	for i, endpoint := range endpoints {
+		if want := i == 3; endpoint.Ejected(clock.Now()) != want {
+			t.Fatalf("endpoint %d ejected = %v, want %v", i, !want, want)
+		}
+	}
+
+	// Counts start over every interval: a single slow request is not enough
+	clock.Advance(od.baseEjectionTime)
+	od.analyze()
+	od.record(endpoints[3], http.StatusOK, time.Second)
+	for _, endpoint := range endpoints[:3] {
+		od.record(endpoint, http.StatusOK, 10*time.Millisecond)
+	}
+	od.analyze()
+	if endpoints[3].Ejected(clock.Now()) {
+		t.Fatal("endpoint ejected on fewer than latency_min_requests requests")
+	}
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// Headers telling clients where they stand against the limit
+const (
+	RateLimitLimitHeader = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader = "X-RateLimit-Reset"
+)
+
+// How often buckets that are full again get dropped
+const rateLimitSweepInterval = time.Minute
+
+// rateLimiter keeps one token bucket per client of a route
+type rateLimiter struct {
+	config *pb.RateLimit
+	rate float64
+	burst float64
+
+	mu sync.Mutex
+	buckets map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	updated time.Time
+}
+
+// Build the rate limiter of a route (nil when the route has no limit)
+func newRateLimiter(config *pb.RateLimit, authRequired bool) (*rateLimiter, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	if config.RequestsPerSecond <= 0 || math.IsInf(config.RequestsPerSecond, 0) || math.IsNaN(config.RequestsPerSecond) {
+		return nil, fmt.Errorf("requests_per_second must be a positive number")
+	}
+
+	if config.Burst < 0 {
+		return nil, fmt.Errorf("burst must not be negative")
+	}
+
+	if err := validateRateLimitKey(config.Key, config.Header, authRequired); err != nil {
+		return nil, err
+	}
+
+	burst := float64(config.Burst)
+	if burst == 0 {
+		burst = math.Max(1, math.Ceil(config.RequestsPerSecond))
+	}
+
+	return &rateLimiter{
+		config: config,
+		rate: config.RequestsPerSecond,
+		burst: burst,
+		buckets: make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}, nil
+}
+
+// Check that the key can be read from the requests of the route
+func validateRateLimitKey(key pb.RateLimitKey, header string, authRequired bool) error {
+	switch key {
+	case pb.RateLimitKey_RATE_LIMIT_KEY_CLIENT_IP:
+	case pb.RateLimitKey_RATE_LIMIT_KEY_SUBJECT:
+		if !authRequired {
+			return fmt.Errorf("RATE_LIMIT_KEY_SUBJECT needs an auth_required route")
+		}
+	case pb.RateLimitKey_RATE_LIMIT_KEY_HEADER:
+		if header == "" {
+			return fmt.Errorf("RATE_LIMIT_KEY_HEADER needs a header name")
+		}
+	default:
+		return fmt.Errorf("unknown key %v", key)
+	}
+	return nil
+}
+
+// Value of the key for this request
+func requestKey(r *http.Request, key pb.RateLimitKey, header string) string {
+	switch key {
+	case pb.RateLimitKey_RATE_LIMIT_KEY_SUBJECT:
+		if identity := IdentityFromContext(r.Context()); identity != nil {
+			return identity.Subject
+		}
+		return ""
+	case pb.RateLimitKey_RATE_LIMIT_KEY_HEADER:
+		return r.Header.Get(header)
+	default:
+		return clientIP(r)
+	}
+}
+
+// Take a token from the bucket of the key
+// Returns whether the request may go, the tokens left, and the time until a token and until a full bucket
+func (rl *rateLimiter) take(key string, now time.Time) (bool, int, time.Duration, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.sweep(now)
+
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.burst, updated: now}
+		rl.buckets[key] = bucket
+	}
+
+	// Refill for the time elapsed since the last request
+	bucket.tokens = math.Min(rl.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*rl.rate)
+	bucket.updated = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+
+	retryAfter := time.Duration(0)
+	if bucket.tokens < 1 {
+		retryAfter = rl.refillTime(1 - bucket.tokens)
+	}
+
+	return allowed, int(bucket.tokens), retryAfter, rl.refillTime(rl.burst - bucket.tokens)
+}
+
+func (rl *rateLimiter) refillTime(tokens float64) time.Duration {
+	return time.Duration(tokens / rl.rate * float64(time.Second))
+}
+
+// Forget the buckets that refilled completely, they are the same as new ones (caller must hold the lock)
+func (rl *rateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	rl.lastSweep = now
+
+	for key, bucket := range rl.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*rl.rate >= rl.burst {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// RateLimitMiddleware rejects with 429 the requests over the route rate limit
+// It runs after authentication, so limits can be keyed on the subject
+func RateLimitMiddleware(logger *logging.Logger, metrics *Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rt := routeFromContext(r.Context())
+		if rt == nil || rt.limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, remaining, retryAfter, reset := rt.limiter.take(requestKey(r, rt.limiter.config.Key, rt.limiter.config.Header), time.Now())
+
+		w.Header().Set(RateLimitLimitHeader, strconv.Itoa(int(rt.limiter.burst)))
+		w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+		w.Header().Set(RateLimitResetHeader, strconv.Itoa(ceilSeconds(reset)))
+
+		if allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger.Warn("request rejected: rate limit exceeded",
+			zap.String("path", r.URL.Path),
+			zap.String("key_type", rt.limiter.config.Key.String()),
+			zap.String("trace_id", tracing.GetTraceID(r)),
+		)
+		metrics.RecordError(rt.service(), "rate_limited")
+
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	})
+}
+
+// Whole seconds, rounded up (headers don't take fractions)
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,448 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// Defaults for the fields left empty in a RetryPolicy and a RetryBudget
+const (
+	defaultRetryMaxAttempts = 2
+	maxRetryAttempts = 10
+	defaultRetryBackoffBase = 25 * time.Millisecond
+	defaultRetryMaxBodyBytes = 64 * 1024
+	defaultRetryBudgetPercent = 20.0
+	defaultMinRetryConcurrency = 3
+)
+
+// retryPolicy is the validated form of a route RetryPolicy
+type retryPolicy struct {
+	maxAttempts int
+	retryOn map[pb.RetryOn]bool
+	perTryTimeout time.Duration
+	backoffBase time.Duration
+	backoffMax time.Duration
+	retryNonIdempotent bool
+	maxBodyBytes int64
+}
+
+// Build a retry policy (nil when the route doesn't retry)
+func newRetryPolicy(config *pb.RetryPolicy) (*retryPolicy, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	if config.MaxAttempts < 0 || config.MaxAttempts > maxRetryAttempts {
+		return nil, fmt.Errorf("max_attempts %d must be between 1 and %d", config.MaxAttempts, maxRetryAttempts)
+	}
+
+	if config.PerTryTimeoutMs < 0 || config.BackoffBaseMs < 0 || config.BackoffMaxMs < 0 || config.MaxBodyBytes < 0 {
+		return nil, fmt.Errorf("timeouts, backoffs and body size must not be negative")
+	}
+
+	policy := &retryPolicy{
+		maxAttempts: intOrDefault(config.MaxAttempts, defaultRetryMaxAttempts),
+		retryOn: make(map[pb.RetryOn]bool),
+		backoffBase: durationOrDefault(config.BackoffBaseMs, defaultRetryBackoffBase),
+		retryNonIdempotent: config.RetryNonIdempotent,
+		maxBodyBytes: int64(intOrDefault(config.MaxBodyBytes, defaultRetryMaxBodyBytes)),
+	}
+
+	if policy.maxAttempts == 1 {
+		return nil, nil
+	}
+
+	if config.PerTryTimeoutMs > 0 {
+		policy.perTryTimeout = time.Duration(config.PerTryTimeoutMs) * time.Millisecond
+	}
+
+	policy.backoffMax = durationOrDefault(config.BackoffMaxMs, 10*policy.backoffBase)
+	if policy.backoffMax < policy.backoffBase {
+		return nil, fmt.Errorf("backoff_max_ms must not be lower than backoff_base_ms")
+	}
+
+	retryOn := config.RetryOn
+	if len(retryOn) == 0 {
+		for value := range pb.RetryOn_name {
+			retryOn = append(retryOn, pb.RetryOn(value))
+		}
+	}
+	for _, condition := range retryOn {
+		if _, ok := pb.RetryOn_name[int32(condition)]; !ok {
+			return nil, fmt.Errorf("unknown retry_on condition %v", condition)
+		}
+		policy.retryOn[condition] = true
+	}
+
+	return policy, nil
+}
+
+// Backoff before the given retry (1 for the first one): base, 2x base, 4x base... up to the max, jittered
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	backoff := p.backoffBase
+	for i := 1; i < retry && backoff < p.backoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > p.backoffMax {
+		backoff = p.backoffMax
+	}
+	return jitter(backoff)
+}
+
+// Methods that can safely reach the endpoint twice
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// What went wrong in an attempt, from the ErrorHandler error or the endpoint status
+// Returns false when the attempt didn't fail in a retriable way
+func failureKind(status int, err error) (pb.RetryOn, bool) {
+	if err != nil {
+		var opErr *net.OpError
+		switch {
+		case errors.As(err, &opErr) && opErr.Op == "dial":
+			return pb.RetryOn_RETRY_ON_CONNECT_FAILURE, true
+		case errors.Is(err, context.DeadlineExceeded):
+			return pb.RetryOn_RETRY_ON_GATEWAY_TIMEOUT, true
+		case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+			return pb.RetryOn_RETRY_ON_RESET, true
+		default:
+			return pb.RetryOn_RETRY_ON_BAD_GATEWAY, true
+		}
+	}
+
+	switch status {
+	case http.StatusBadGateway:
+		return pb.RetryOn_RETRY_ON_BAD_GATEWAY, true
+	case http.StatusServiceUnavailable:
+		return pb.RetryOn_RETRY_ON_SERVICE_UNAVAILABLE, true
+	case http.StatusGatewayTimeout:
+		return pb.RetryOn_RETRY_ON_GATEWAY_TIMEOUT, true
+	default:
+		return 0, false
+	}
+}
+
+// Label used in logs and metrics (e.g., "connect_failure")
+func retryReason(kind pb.RetryOn) string {
+	return strings.ToLower(strings.TrimPrefix(kind.String(), "RETRY_ON_"))
+}
+
+// retryBudget caps the retries in flight to a cluster
+type retryBudget struct {
+	percent float64
+	minConcurrency int64
+
+	// Requests and retries currently in flight to the cluster
+	active atomic.Int64
+	retries atomic.Int64
+}
+
+// Build the retry budget of a cluster, every cluster has one
+func newRetryBudget(config *pb.RetryBudget) (*retryBudget, error) {
+	budget := &retryBudget{
+		percent: defaultRetryBudgetPercent,
+		minConcurrency: defaultMinRetryConcurrency,
+	}
+
+	if config == nil {
+		return budget, nil
+	}
+
+	if config.BudgetPercent < 0 || config.BudgetPercent > 100 {
+		return nil, fmt.Errorf("budget_percent %v must be between 0 and 100", config.BudgetPercent)
+	}
+	if config.MinRetryConcurrency < 0 {
+		return nil, fmt.Errorf("min_retry_concurrency must not be negative")
+	}
+
+	if config.BudgetPercent > 0 {
+		budget.percent = config.BudgetPercent
+	}
+	if config.MinRetryConcurrency > 0 {
+		budget.minConcurrency = int64(config.MinRetryConcurrency)
+	}
+
+	return budget, nil
+}
+
+// Take a place for a retry, false when the budget is spent
+func (b *retryBudget) tryAcquire() bool {
+	limit := int64(float64(b.active.Load()) * b.percent / 100)
+	if limit < b.minConcurrency {
+		limit = b.minConcurrency
+	}
+
+	if b.retries.Add(1) > limit {
+		b.retries.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (b *retryBudget) release() {
+	b.retries.Add(-1)
+}
+
+// Read the request body so every attempt can send it again
+// Returns nil when the body is too large to buffer: it is then streamed once, without retries
+func bufferBody(r *http.Request, maxBytes int64) (func() io.ReadCloser, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return func() io.ReadCloser { return http.NoBody }, nil
+	}
+
+	if r.ContentLength > maxBytes {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	// Too large after all (no Content-Length): send what we read followed by the rest
+	if int64(len(body)) > maxBytes {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, nil
+	}
+
+	return func() io.ReadCloser {
+		return io.NopCloser(bytes.NewReader(body))
+	}, nil
+}
+
+// State of one attempt, shared with the ErrorHandler through the request context
+type attemptState struct {
+	number int
+
+	// Set by the ErrorHandler when the attempt failed before a response
+	err error
+
+	// The failure is followed by another attempt, so it isn't the answer to the request
+	retried bool
+}
+
+// retryWriter holds back the response of an attempt that is going to be retried
+// Nothing reaches the client until a response is accepted
+type retryWriter struct {
+	w http.ResponseWriter
+	header http.Header
+
+	// Asked once, with the status of the response
+	shouldRetry func(status int) bool
+
+	status int
+	committed bool
+	discarded bool
+}
+
+func newRetryWriter(w http.ResponseWriter, shouldRetry func(status int) bool) *retryWriter {
+	return &retryWriter{
+		w: w,
+		header: make(http.Header),
+		shouldRetry: shouldRetry,
+	}
+}
+
+func (rw *retryWriter) Header() http.Header {
+	if rw.committed {
+		return rw.w.Header()
+	}
+	return rw.header
+}
+
+func (rw *retryWriter) WriteHeader(status int) {
+	if rw.committed || rw.discarded {
+		return
+	}
+
+	// Informational responses are dropped, the attempt may still be thrown away
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		return
+	}
+
+	rw.status = status
+	if rw.shouldRetry(status) {
+		rw.discarded = true
+		return
+	}
+
+	header := rw.w.Header()
+	for key, values := range rw.header {
+		header[key] = values
+	}
+	rw.committed = true
+	rw.w.WriteHeader(status)
+}
+
+func (rw *retryWriter) Write(data []byte) (int, error) {
+	if !rw.committed && !rw.discarded {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.discarded {
+		return len(data), nil
+	}
+	return rw.w.Write(data)
+}
+
+// FlushError keeps a held back response from being flushed to the client
+func (rw *retryWriter) FlushError() error {
+	if !rw.committed {
+		return nil
+	}
+	return http.NewResponseController(rw.w).Flush()
+}
+
+// Unwrap gives http.ResponseController access to the original writer (e.g., to hijack upgrades)
+func (rw *retryWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+// Forward the request, retrying failed attempts as the route policy allows
+func (h *Handler) forwardWithRetries(w http.ResponseWriter, r *http.Request, rt *route) {
+
+	policy := rt.retry
+	budget := rt.cluster.retryBudget
+	traceID := tracing.GetTraceID(r)
+
+	replay, err := bufferBody(r, policy.maxBodyBytes)
+	if err != nil {
+		h.logger.Warn("failed to read request body",
+			zap.Error(err),
+			zap.String("trace_id", traceID),
+		)
+		h.metrics.RecordError(rt.service(), "bad_request")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	idempotent := policy.retryNonIdempotent || isIdempotent(r.Method)
+
+	// A request holds one place in the budget from its first retry to its last attempt
+	holdsBudget := false
+	defer func() {
+		if holdsBudget {
+			budget.release()
+		}
+	}()
+
+	for attempt := 1; ; attempt++ {
+
+		endpoint := h.pickEndpoint(w, r, rt)
+		if endpoint == nil {
+			return
+		}
+
+		state := &attemptState{number: attempt}
+		var reason pb.RetryOn
+
+		shouldRetry := func(status int) bool {
+			// Out of attempts, body not replayable, or the client (or the route timeout) is done
+			if attempt >= policy.maxAttempts || replay == nil || r.Context().Err() != nil {
+				return false
+			}
+
+			kind, failed := failureKind(status, state.err)
+			if !failed || !policy.retryOn[kind] {
+				return false
+			}
+
+			// The endpoint may have processed the request already
+			if kind != pb.RetryOn_RETRY_ON_CONNECT_FAILURE && !idempotent {
+				return false
+			}
+
+			if !holdsBudget {
+				if !budget.tryAcquire() {
+					h.logger.Warn("retry budget exhausted, not retrying",
+						zap.String("cluster", rt.cluster.name),
+						zap.Int("attempt", attempt),
+						zap.String("trace_id", traceID),
+					)
+					h.metrics.RecordRetryBudgetExhausted(rt.service())
+					return false
+				}
+				holdsBudget = true
+			}
+
+			reason = kind
+			state.retried = true
+			return true
+		}
+
+		ctx := withAttempt(r.Context(), state)
+		cancel := func() {}
+		if policy.perTryTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, policy.perTryTimeout)
+		}
+
+		req := r.WithContext(ctx)
+		if replay != nil {
+			req.Body = replay()
+		}
+
+		recorder := newRetryWriter(w, shouldRetry)
+		h.send(recorder, req, rt, endpoint)
+		cancel()
+
+		if !recorder.discarded {
+			return
+		}
+
+		backoff := policy.backoff(attempt)
+
+		h.logger.Warn("retrying request",
+			zap.Int("attempt", attempt+1),
+			zap.String("reason", retryReason(reason)),
+			zap.Int("status", recorder.status),
+			zap.String("endpoint", endpoint.address),
+			zap.Duration("backoff", backoff),
+			zap.String("trace_id", traceID),
+		)
+		h.metrics.RecordRetry(rt.service(), attempt+1, retryReason(reason))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <- r.Context().Done():
+			timer.Stop()
+
+			// The client went away while backing off, nobody is left to answer
+			if errors.Is(r.Context().Err(), context.Canceled) {
+				h.logger.Debug("client canceled request while backing off",
+					zap.Int("attempt", attempt),
+					zap.String("trace_id", traceID),
+				)
+				h.metrics.RecordError(rt.service(), "client_canceled")
+				w.WriteHeader(statusClientClosedRequest)
+				return
+			}
+
+			// The route timeout fired while backing off, the failed response is gone
+			h.metrics.RecordError(rt.service(), "timeout")
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+			return
+		case <- timer.C:
+		}
+	}
+}
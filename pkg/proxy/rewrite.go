@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+// urlRewrite changes the path and Host of the requests a route sends upstream
+type urlRewrite struct {
+	// Matched path or prefix of the route, and what replaces it (only when prefixSet)
+	matched string
+	prefix string
+	prefixSet bool
+
+	// Only set for regex rewrites
+	regex *regexp.Regexp
+	substitution string
+
+	host string
+	autoHost bool
+}
+
+// Build the rewrite of a route (nil when the route sends requests unchanged)
+func newURLRewrite(routeConfig *pb.Route) (*urlRewrite, error) {
+	if routeConfig.PrefixRewrite == "" && routeConfig.RegexRewrite == nil && routeConfig.HostRewrite == "" && !routeConfig.AutoHostRewrite {
+		return nil, nil
+	}
+
+	if routeConfig.PrefixRewrite != "" && routeConfig.RegexRewrite != nil {
+		return nil, fmt.Errorf("prefix_rewrite and regex_rewrite can't be combined")
+	}
+
+	if routeConfig.HostRewrite != "" && routeConfig.AutoHostRewrite {
+		return nil, fmt.Errorf("host_rewrite and auto_host_rewrite can't be combined")
+	}
+
+	rewrite := &urlRewrite{
+		matched: routeConfig.Path,
+		host: routeConfig.HostRewrite,
+		autoHost: routeConfig.AutoHostRewrite,
+	}
+
+	if routeConfig.PrefixRewrite != "" {
+		if !strings.HasPrefix(routeConfig.PrefixRewrite, "/") {
+			return nil, fmt.Errorf("prefix_rewrite %q must start with /", routeConfig.PrefixRewrite)
+		}
+		rewrite.prefix = routeConfig.PrefixRewrite
+		rewrite.prefixSet = true
+	}
+
+	if config := routeConfig.RegexRewrite; config != nil {
+		regex, err := regexp.Compile(config.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex_rewrite pattern: %w", err)
+		}
+		rewrite.regex = regex
+		rewrite.substitution = config.Substitution
+	}
+
+	return rewrite, nil
+}
+
+// Rewrite the outgoing request, endpoint is where it is sent
+func (rw *urlRewrite) apply(req *http.Request, endpoint *Endpoint) {
+
+	if rw.prefixSet || rw.regex != nil {
+		path := rw.rewritePath(req.URL.Path)
+
+		// The escaped form of the old path doesn't apply anymore, it is derived again from Path
+		req.URL.Path = path
+		req.URL.RawPath = ""
+	}
+
+	if rw.autoHost {
+		req.Host = endpoint.url.Host
+	} else if rw.host != "" {
+		req.Host = rw.host
+	}
+}
+
+func (rw *urlRewrite) rewritePath(path string) string {
+	if rw.regex != nil {
+		path = rw.regex.ReplaceAllString(path, rw.substitution)
+	} else {
+		// The route matched, so path starts with the matched prefix
+		rest := strings.TrimPrefix(path, rw.matched)
+
+		// The rest is empty or starts with a slash, whatever the matched prefix ends with
+		if strings.HasSuffix(rw.matched, "/") {
+			rest = "/" + rest
+		}
+
+		// Don't double the slash between the new prefix and the rest ("/" + "/42")
+		if strings.HasSuffix(rw.prefix, "/") {
+			rest = strings.TrimPrefix(rest, "/")
+		}
+		path = rw.prefix + rest
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
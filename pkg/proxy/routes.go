@@ -0,0 +1,494 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// A route ready to serve traffic: the rule from the control plane
+// plus the cluster and load balancer that serve it
+type route struct {
+	config *pb.Route
+	cluster *Cluster
+	balancer LoadBalancer
+
+	// Conditions on method, host, headers and query, besides the path
+	matcher *requestMatcher
+
+	// Only set when the route retries failed requests
+	retry *retryPolicy
+
+	// Only set when the route is rate limited
+	limiter *rateLimiter
+
+	// Only set when the route copies requests to a shadow cluster
+	mirror *mirrorPolicy
+
+	// Only set when the route rewrites the path or Host sent upstream
+	rewrite *urlRewrite
+
+	// Header policies, the global ones first (empty when headers are sent unchanged)
+	requestHeaders []*headerPolicy
+	responseHeaders []*headerPolicy
+
+	// Only set when the route requires authentication
+	authenticators []Authenticator
+
+	// Authorization policies, shared by all routes (nil when there are none)
+	authz *authorizer
+
+	// Only set when the route restricts clients by IP address
+	ipAccess *ipAccessList
+
+	// Only set when the route asks an external service whether to let requests through
+	extAuthz *extAuthzPolicy
+
+	// Only set when the route spreads requests over several clusters,
+	// the request is then served by one of its backends
+	split *trafficSplit
+
+	// Version label of the backend in metrics (empty for routes without a split)
+	version string
+}
+
+// Name used for this route's backend in metrics
+// Routes answering at the proxy have no cluster, they go by their name (or path)
+func (rt *route) service() string {
+	if rt.cluster == nil {
+		if rt.config.Name != "" {
+			return rt.config.Name
+		}
+		return rt.config.Path
+	}
+	return rt.cluster.name
+}
+
+// RouteTable is an immutable snapshot of the routing configuration
+// A new table is built for every ConfigUpdate and swapped in atomically
+type RouteTable struct {
+	version int64
+
+	// Exact routes are a lookup by path, routes on the same path are sorted from the most specific
+	exact map[string][]*route
+
+	// Prefix routes, sorted from the longest to the shortest path, then from the most specific
+	prefixes []*route
+
+	// Clusters by name, including the implicit ones of routes using a plain backend
+	clusters map[string]*Cluster
+}
+
+// Build a route table from a config update
+// Fails if any route is invalid, so a bad update never replaces a good table
+// localAuth is used when the update carries no auth config of its own
+// Clusters that didn't change are taken from the previous table (nil on the first build),
+// so they keep their endpoint state and running health checks
+func newRouteTable(update *pb.ConfigUpdate, localAuth *pb.AuthConfig, previous *RouteTable) (*RouteTable, error) {
+
+	// The control plane auth config wins over the local one
+	authConfig := update.Auth
+	if authConfig == nil {
+		authConfig = localAuth
+	}
+
+	authenticators, err := newAuthenticators(authConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth config: %w", err)
+	}
+
+	globalRequestConfig := update.RequestHeaders
+	if globalRequestConfig == nil {
+		globalRequestConfig = defaultRequestHeaders
+	}
+
+	globalRequestHeaders, err := newHeaderPolicy(globalRequestConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid global request headers: %w", err)
+	}
+
+	globalResponseHeaders, err := newHeaderPolicy(update.ResponseHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("invalid global response headers: %w", err)
+	}
+
+	authz, err := newAuthorizer(update.AuthorizationPolicies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization policies: %w", err)
+	}
+
+	table := &RouteTable{
+		version: update.Version,
+		exact: make(map[string][]*route),
+		clusters: make(map[string]*Cluster, len(update.Clusters)),
+	}
+
+	for _, clusterConfig := range update.Clusters {
+		if _, exists := table.clusters[clusterConfig.Name]; exists {
+			return nil, fmt.Errorf("duplicate cluster %q", clusterConfig.Name)
+		}
+
+		if _, err := table.addCluster(clusterConfig, previous); err != nil {
+			return nil, err
+		}
+	}
+
+	// Used to reject two routes matching the same requests
+	seen := make(map[string]bool)
+
+	for _, routeConfig := range update.Routes {
+		if !strings.HasPrefix(routeConfig.Path, "/") {
+			return nil, fmt.Errorf("route with backend %q has an invalid path %q (must start with /)", routeConfig.Backend, routeConfig.Path)
+		}
+
+		// Redirects and direct responses have no upstream
+		var cluster *Cluster
+		var balancer LoadBalancer
+
+		if localAction(routeConfig) {
+			if err := validateLocalAction(routeConfig); err != nil {
+				return nil, fmt.Errorf("invalid action for route %q: %w", routeConfig.Path, err)
+			}
+		} else {
+			cluster, err = table.clusterFor(routeConfig, previous)
+			if err != nil {
+				return nil, fmt.Errorf("invalid upstream for route %q: %w", routeConfig.Path, err)
+			}
+
+			balancer, err = newLoadBalancer(routeConfig.LbPolicy, cluster)
+			if err != nil {
+				return nil, fmt.Errorf("invalid load balancer for route %q: %w", routeConfig.Path, err)
+			}
+		}
+
+		if err := validateHashPolicy(routeConfig.HashPolicy); err != nil {
+			return nil, fmt.Errorf("invalid hash policy for route %q: %w", routeConfig.Path, err)
+		}
+
+		if routeConfig.TimeoutMs < 0 {
+			return nil, fmt.Errorf("route %q has a negative timeout_ms %d", routeConfig.Path, routeConfig.TimeoutMs)
+		}
+
+		matcher, err := newRequestMatcher(routeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match conditions for route %q: %w", routeConfig.Path, err)
+		}
+
+		retry, err := newRetryPolicy(routeConfig.RetryPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry policy for route %q: %w", routeConfig.Path, err)
+		}
+
+		limiter, err := newRateLimiter(routeConfig.RateLimit, routeConfig.AuthRequired)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit for route %q: %w", routeConfig.Path, err)
+		}
+
+		if err := validateGlobalRateLimit(routeConfig.GlobalRateLimit, routeConfig.AuthRequired); err != nil {
+			return nil, fmt.Errorf("invalid global rate limit for route %q: %w", routeConfig.Path, err)
+		}
+
+		if err := validateFaultInjection(routeConfig.Fault); err != nil {
+			return nil, fmt.Errorf("invalid fault injection for route %q: %w", routeConfig.Path, err)
+		}
+
+		rewrite, err := newURLRewrite(routeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite for route %q: %w", routeConfig.Path, err)
+		}
+
+		requestHeaders, err := newHeaderPolicy(routeConfig.RequestHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request headers for route %q: %w", routeConfig.Path, err)
+		}
+
+		responseHeaders, err := newHeaderPolicy(routeConfig.ResponseHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response headers for route %q: %w", routeConfig.Path, err)
+		}
+
+		mirror, err := table.newMirrorPolicy(routeConfig.Mirror)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror for route %q: %w", routeConfig.Path, err)
+		}
+
+		ipAccess, err := newIPAccessList(routeConfig.GetIpAccess().GetAllow(), routeConfig.GetIpAccess().GetDeny())
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip_access for route %q: %w", routeConfig.Path, err)
+		}
+
+		extAuthz, err := newExtAuthzPolicy(routeConfig.ExtAuthz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ext_authz for route %q: %w", routeConfig.Path, err)
+		}
+
+		// An unchanged limit keeps its buckets, clients don't get a fresh burst on every update
+		if old := previous.sameRoute(routeConfig); old != nil && old.limiter != nil && proto.Equal(old.limiter.config, routeConfig.RateLimit) {
+			limiter = old.limiter
+		}
+
+		rt := &route{
+			config: routeConfig,
+			cluster: cluster,
+			balancer: balancer,
+			matcher: matcher,
+			retry: retry,
+			limiter: limiter,
+			mirror: mirror,
+			rewrite: rewrite,
+			requestHeaders: headerPolicies(globalRequestHeaders, requestHeaders),
+			responseHeaders: headerPolicies(globalResponseHeaders, responseHeaders),
+			authz: authz,
+			ipAccess: ipAccess,
+			extAuthz: extAuthz,
+		}
+
+		// Refuse routes that could never let a request through
+		if routeConfig.AuthRequired {
+			if len(authenticators) == 0 {
+				return nil, fmt.Errorf("route %q requires auth but no JWKS or API keys are configured", routeConfig.Path)
+			}
+			rt.authenticators = authenticators
+		}
+
+		// Built last, each backend is a copy of the route
+		if routeConfig.TrafficSplit != nil {
+			if rt.split, err = table.newTrafficSplit(rt); err != nil {
+				return nil, fmt.Errorf("invalid traffic split for route %q: %w", routeConfig.Path, err)
+			}
+		}
+
+		signature := matchSignature(routeConfig)
+		if seen[signature] {
+			return nil, fmt.Errorf("duplicate %v route for path %q with the same match conditions", routeConfig.PathMatch, routeConfig.Path)
+		}
+		seen[signature] = true
+
+		switch routeConfig.PathMatch {
+		case pb.PathMatch_PATH_MATCH_EXACT:
+			table.exact[routeConfig.Path] = append(table.exact[routeConfig.Path], rt)
+
+		case pb.PathMatch_PATH_MATCH_PREFIX:
+			table.prefixes = append(table.prefixes, rt)
+
+		default:
+			return nil, fmt.Errorf("route %q has an unknown path match type %v", routeConfig.Path, routeConfig.PathMatch)
+		}
+	}
+
+	// Most specific first, so the first match in match() is the one to use
+	// The sort is stable: routes as specific as each other keep the config order
+	for _, routes := range table.exact {
+		sort.SliceStable(routes, func(i, j int) bool {
+			return moreSpecific(routes[i], routes[j])
+		})
+	}
+	sort.SliceStable(table.prefixes, func(i, j int) bool {
+		a, b := table.prefixes[i], table.prefixes[j]
+		if len(a.config.Path) != len(b.config.Path) {
+			return len(a.config.Path) > len(b.config.Path)
+		}
+		return moreSpecific(a, b)
+	})
+
+	return table, nil
+}
+
+// gRPC addresses of the external authorization services the routes ask
+func (t *RouteTable) extAuthzAddresses() map[string]bool {
+	addresses := make(map[string]bool)
+	add := func(rt *route) {
+		if rt.extAuthz != nil && rt.extAuthz.config.GrpcAddress != "" {
+			addresses[rt.extAuthz.config.GrpcAddress] = true
+		}
+	}
+
+	for _, routes := range t.exact {
+		for _, rt := range routes {
+			add(rt)
+		}
+	}
+	for _, rt := range t.prefixes {
+		add(rt)
+	}
+	return addresses
+}
+
+// Number of exact routes, over all paths
+func (t *RouteTable) exactRoutes() int {
+	n := 0
+	for _, routes := range t.exact {
+		n += len(routes)
+	}
+	return n
+}
+
+// Find the route with the same path, match type and conditions (nil if none, or if the table is nil)
+func (t *RouteTable) sameRoute(routeConfig *pb.Route) *route {
+	if t == nil {
+		return nil
+	}
+
+	candidates := t.prefixes
+	if routeConfig.PathMatch == pb.PathMatch_PATH_MATCH_EXACT {
+		candidates = t.exact[routeConfig.Path]
+	}
+
+	signature := matchSignature(routeConfig)
+	for _, rt := range candidates {
+		if matchSignature(rt.config) == signature {
+			return rt
+		}
+	}
+	return nil
+}
+
+// Find the cluster a route sends traffic to
+// A plain backend becomes a single endpoint cluster named after its address
+// With a traffic split, it is the first cluster of the split (requests go to the one picked for them)
+func (t *RouteTable) clusterFor(routeConfig *pb.Route, previous *RouteTable) (*Cluster, error) {
+
+	name := routeConfig.Cluster
+	if split := routeConfig.TrafficSplit; split != nil && len(split.Clusters) > 0 {
+		name = split.Clusters[0].Cluster
+	}
+
+	if name != "" {
+		cluster, ok := t.clusters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cluster %q", name)
+		}
+		return cluster, nil
+	}
+
+	backendURL, err := parseBackendURL(routeConfig.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	// Routes sharing a backend share its cluster too
+	if cluster, ok := t.clusters[backendURL.Host]; ok {
+		return cluster, nil
+	}
+
+	return t.addCluster(&pb.Cluster{
+		Name: backendURL.Host,
+		Endpoints: []string{routeConfig.Backend},
+	}, previous)
+}
+
+// Add a cluster to the table, reusing the previous one if its config is the same
+func (t *RouteTable) addCluster(config *pb.Cluster, previous *RouteTable) (*Cluster, error) {
+
+	if previous != nil {
+		if existing, ok := previous.clusters[config.Name]; ok && proto.Equal(existing.config, config) {
+			t.clusters[config.Name] = existing
+			return existing, nil
+		}
+	}
+
+	cluster, err := newCluster(config)
+	if err != nil {
+		return nil, err
+	}
+
+	t.clusters[cluster.name] = cluster
+	return cluster, nil
+}
+
+// Health of every endpoint in the table
+func (t *RouteTable) endpointHealth() []*pb.EndpointHealth {
+	var health []*pb.EndpointHealth
+	for _, cluster := range t.clusters {
+		health = append(health, cluster.endpointHealth()...)
+	}
+	return health
+}
+
+// Find the route serving the request (nil if nothing matches)
+// An exact route always wins, then the longest matching prefix.
+// On the same path, routes with hosts come first, then routes with more conditions, then config order
+func (t *RouteTable) match(r *http.Request) *route {
+	path := r.URL.Path
+
+	for _, rt := range t.exact[path] {
+		if rt.matcher.matches(r) {
+			return rt
+		}
+	}
+
+	for _, rt := range t.prefixes {
+		if hasPathPrefix(path, rt.config.Path) && rt.matcher.matches(r) {
+			return rt
+		}
+	}
+
+	return nil
+}
+
+// Prefixes only match on whole path segments: "/api" matches "/api/users" but not "/apiary"
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	// Same path, or the prefix already ends on a segment boundary
+	if len(path) == len(prefix) || strings.HasSuffix(prefix, "/") {
+		return true
+	}
+
+	return path[len(prefix)] == '/'
+}
+
+// Endpoints of mesh clusters are always https (mTLS), the scheme can be left out
+func parseEndpointURL(address string, mtls bool) (*url.URL, error) {
+	if !mtls {
+		return parseBackendURL(address)
+	}
+
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+
+	endpointURL, err := parseBackendURL(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpointURL.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint %q of a mesh cluster must use https", address)
+	}
+	return endpointURL, nil
+}
+
+// Backends come as "host:port" from the control plane, a scheme is optional
+func parseBackendURL(backend string) (*url.URL, error) {
+	if backend == "" {
+		return nil, fmt.Errorf("backend is empty")
+	}
+
+	if !strings.Contains(backend, "://") {
+		backend = "http://" + backend
+	}
+
+	backendURL, err := url.Parse(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	if backendURL.Host == "" {
+		return nil, fmt.Errorf("backend %q has no host", backend)
+	}
+
+	// Requests keep their own path, the backend only says where to send them
+	if backendURL.Path != "" && backendURL.Path != "/" {
+		return nil, fmt.Errorf("backend %q must not have a path", backend)
+	}
+
+	return backendURL, nil
+}
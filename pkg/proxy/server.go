@@ -25,7 +25,7 @@ func NewServer(config *Config, logger *logging.Logger) (*Server, error) {
 	metrics := NewMetrics()
 
 	// Create the handler
-	handler, err := NewHandler(config, logger)
+	handler, err := NewHandler(config, logger, metrics)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create handler for the server: %w", err)
 	}
@@ -58,6 +58,13 @@ func NewServer(config *Config, logger *logging.Logger) (*Server, error) {
 		IdleTimeout: config.Proxy.Timeout.IdleTimeout,
 	}
 
+	if config.Proxy.TLS.Enabled {
+		httpServer.TLSConfig, err = newServerTLSConfig(&config.Proxy.TLS, handler.certificates, handler.workload)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TLS configuration: %w", err)
+		}
+	}
+
 	return &Server{
 		config: config,
 		handler: handler,
@@ -68,18 +75,39 @@ func NewServer(config *Config, logger *logging.Logger) (*Server, error) {
 
 }
 
+// Handler returns the proxy handler, so config updates can be applied to it
+func (s *Server) Handler() *Handler {
+	return s.handler
+}
+
 // Starts the Server: will run till blocked
 func (s *Server) Start() error {
+	tlsEnabled := s.httpServer.TLSConfig != nil
+
+	scheme := "http"
+	if tlsEnabled {
+		scheme = "https"
+	}
+
 	s.logger.Info("proxy server starting",
 		zap.Int("port", s.config.Proxy.ListenPort),
+		zap.Bool("tls", tlsEnabled),
 		zap.String("backend_url", s.config.GetBackendURL()),
 	)
 
 	s.logger.Info("metrics endpoint registered at /metrics",
-		zap.String("url", fmt.Sprintf("http://localhost:%d/metrics", s.config.Proxy.ListenPort)),
+		zap.String("url", fmt.Sprintf("%s://localhost:%d/metrics", scheme, s.config.Proxy.ListenPort)),
 	)
 
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var err error
+	if tlsEnabled {
+		// The certificates come from TLSConfig.GetCertificate, not from files
+		err = s.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+
+	if err != nil && err != http.ErrServerClosed {
 		s.logger.Error("failure in the server...stopping",
 			zap.Error(err),
 		)
@@ -101,6 +129,12 @@ func (s *Server) Shutdown(timeout time.Duration) error {
 		return fmt.Errorf("Server shutdown failed: %w", err)
 	}
 
+	if err := s.handler.Close(); err != nil {
+		s.logger.Warn("failed to close handler connections",
+			zap.Error(err),
+		)
+	}
+
 	s.logger.Info("server stopped gracefully!")
 	return nil
 }
\ No newline at end of file
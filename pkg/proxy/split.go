@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/cespare/xxhash/v2"
+)
+
+// Resolution of the hash used to pin clients, buckets are stable when weights change
+const splitBuckets = 1_000_000
+
+// trafficSplit spreads the requests of a route over several clusters by weight
+type trafficSplit struct {
+	config *pb.TrafficSplit
+
+	// One route per cluster, with its own cluster, balancer and version
+	backends []*route
+
+	// Running total of the weights, cumulative[i] ends the share of backends[i]
+	cumulative []int64
+	total int64
+}
+
+// Build the split of a route, rt is copied into one route per weighted cluster
+func (t *RouteTable) newTrafficSplit(rt *route) (*trafficSplit, error) {
+	config := rt.config.TrafficSplit
+
+	if len(config.Clusters) == 0 {
+		return nil, fmt.Errorf("no clusters")
+	}
+
+	if err := validateHashPolicy(config.HashPolicy); err != nil {
+		return nil, err
+	}
+
+	split := &trafficSplit{config: config}
+	seen := make(map[string]bool, len(config.Clusters))
+
+	for _, weighted := range config.Clusters {
+		cluster, ok := t.clusters[weighted.Cluster]
+		if !ok {
+			return nil, fmt.Errorf("unknown cluster %q", weighted.Cluster)
+		}
+		if seen[weighted.Cluster] {
+			return nil, fmt.Errorf("cluster %q appears twice", weighted.Cluster)
+		}
+		seen[weighted.Cluster] = true
+
+		if weighted.Weight < 0 {
+			return nil, fmt.Errorf("cluster %q has a negative weight", weighted.Cluster)
+		}
+
+		balancer, err := newLoadBalancer(rt.config.LbPolicy, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		version := weighted.Version
+		if version == "" {
+			version = cluster.name
+		}
+
+		backend := *rt
+		backend.cluster = cluster
+		backend.balancer = balancer
+		backend.version = version
+		backend.split = nil
+
+		split.total += int64(weighted.Weight)
+		split.backends = append(split.backends, &backend)
+		split.cumulative = append(split.cumulative, split.total)
+	}
+
+	if split.total == 0 {
+		return nil, fmt.Errorf("all weights are zero")
+	}
+
+	return split, nil
+}
+
+// Pick the cluster for this request
+// With a hash policy the same key always lands on the same cluster (as long as the weights don't move its bucket),
+// otherwise the pick is random with the configured weights.
+// A newly issued affinity cookie is returned, to be set only if the request gets through
+func (s *trafficSplit) pick(r *http.Request) (*route, *http.Cookie) {
+
+	var point int64
+	key, cookie, ok := s.hashKey(r)
+	if ok {
+		bucket := xxhash.Sum64String(key) % splitBuckets
+		point = int64(bucket) * s.total / splitBuckets
+	} else {
+		point = rand.Int64N(s.total)
+	}
+
+	for i, end := range s.cumulative {
+		if point < end {
+			return s.backends[i], cookie
+		}
+	}
+	return s.backends[len(s.backends)-1], cookie
+}
+
+// Key pinning the client to a cluster (false without a hash policy, or when the request has no key)
+func (s *trafficSplit) hashKey(r *http.Request) (string, *http.Cookie, bool) {
+	if s.config.HashPolicy == nil {
+		return "", nil, false
+	}
+	return requestHashKey(s.config.HashPolicy, r)
+}
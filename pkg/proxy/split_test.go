@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+)
+
+func TestTrafficSplitCookieOnlyForAdmittedRequests(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	address := backend.Listener.Addr().String()
+	h := newTestHandler(t, &pb.ConfigUpdate{
+		Clusters: []*pb.Cluster{
+			{Name: "stable", Endpoints: []string{address}},
+			{Name: "canary", Endpoints: []string{address}},
+		},
+		Routes: []*pb.Route{{
+			Path: "/",
+			IpAccess: &pb.IPAccess{Deny: []string{"192.0.2.1/32"}},
+			TrafficSplit: &pb.TrafficSplit{
+				Clusters: []*pb.WeightedCluster{{Cluster: "stable", Weight: 90}, {Cluster: "canary", Weight: 10}},
+				HashPolicy: &pb.HashPolicy{Key: pb.HashKey_HASH_KEY_COOKIE, Name: "affinity", IssueCookie: true},
+			},
+		}},
+	})
+
+	tests := []struct {
+		name string
+		remoteAddr string
+		wantStatus int
+		wantCookie bool
+	}{
+		{name: "denied client", remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusForbidden},
+		{name: "allowed client", remoteAddr: "192.0.2.2:1234", wantStatus: http.StatusOK, wantCookie: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if cookies := w.Result().Cookies(); (len(cookies) > 0) != tt.wantCookie {
+				t.Fatalf("cookies = %v, want a cookie %v", cookies, tt.wantCookie)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header carrying the remaining time budget (in milliseconds) to the backend
+// Downstream services can use it to cut work short, and the next proxy hop honours it
+const TimeoutHeader = "X-GoMesh-Timeout-Ms"
+
+// Build the context for the upstream request
+// The deadline is the shortest between the route timeout and the budget received in TimeoutHeader
+// With no limits at all, the request context is returned as it is
+func withRequestTimeout(r *http.Request, routeTimeoutMs int32) (context.Context, context.CancelFunc) {
+
+	var timeout time.Duration
+	if routeTimeoutMs > 0 {
+		timeout = time.Duration(routeTimeoutMs) * time.Millisecond
+	}
+
+	// A caller (or a previous proxy) may have a tighter budget than ours
+	if budget, ok := timeoutFromHeader(r); ok && (timeout == 0 || budget < timeout) {
+		timeout = budget
+	}
+
+	if timeout == 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// Read the budget from the request header, ignoring missing or malformed values
+func timeoutFromHeader(r *http.Request) (time.Duration, bool) {
+	value := r.Header.Get(TimeoutHeader)
+	if value == "" {
+		return 0, false
+	}
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// Write the remaining budget on the outgoing request (if it has a deadline)
+func setTimeoutHeader(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		req.Header.Del(TimeoutHeader)
+		return
+	}
+
+	// Never send 0 or less: the backend would read it as "no budget"
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining < 1 {
+		remaining = 1
+	}
+
+	req.Header.Set(TimeoutHeader, strconv.FormatInt(remaining, 10))
+}
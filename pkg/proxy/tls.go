@@ -0,0 +1,330 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/SimonePesci/gomesh/api/proto"
+	"github.com/SimonePesci/gomesh/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Build the TLS config of the listener, certificates are picked from the store on every handshake
+// Other proxies of the mesh get the workload certificate and must present theirs (mTLS)
+func newServerTLSConfig(config *TLSConfig, certificates *certStore, workload *workloadIdentity) (*tls.Config, error) {
+	minVersion, err := tlsVersion(config.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := cipherSuites(config.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: minVersion,
+		CipherSuites: suites,
+		GetCertificate: certificates.getCertificate,
+		GetConfigForClient: workload.getConfigForClient,
+	}, nil
+}
+
+// TLS version from its number (e.g., "1.2")
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q (must be 1.2 or 1.3)", version)
+	}
+}
+
+// Cipher suites from their Go names (e.g., "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"), insecure ones are refused
+// They only apply to TLS 1.2, TLS 1.3 suites are not configurable (nil: Go defaults)
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+// certStore holds the certificates served by the listener: the local files and the ones from the control plane
+// Files are watched and reloaded when they change, a broken file keeps the previous certificates
+type certStore struct {
+	files []CertificateFileConfig
+	interval time.Duration
+	logger *logging.Logger
+
+	// Serializes reloads and control plane updates
+	mu sync.Mutex
+	local []*servedCert
+	pushed []*servedCert
+	modTimes map[string]time.Time
+
+	// Rebuilt on every change, read on every handshake
+	index atomic.Pointer[certIndex]
+
+	stop chan struct{}
+	stopOnce sync.Once
+}
+
+// A certificate and the names it serves
+type servedCert struct {
+	source string
+	cert *tls.Certificate
+	names []string
+}
+
+// Certificates by server name
+type certIndex struct {
+	exact map[string]*tls.Certificate
+
+	// "*.example.com" is stored as "example.com"
+	wildcard map[string]*tls.Certificate
+
+	// Served to clients without SNI, or with an unknown name
+	fallback *tls.Certificate
+}
+
+// Load the local certificates, failing if any of them is broken
+func newCertStore(config *TLSConfig, logger *logging.Logger) (*certStore, error) {
+	store := &certStore{
+		files: config.Certificates,
+		interval: config.ReloadInterval,
+		logger: logger,
+		modTimes: make(map[string]time.Time),
+		stop: make(chan struct{}),
+	}
+
+	local, modTimes, err := store.loadFiles()
+	if err != nil {
+		return nil, err
+	}
+	store.local = local
+	store.modTimes = modTimes
+	store.rebuild()
+
+	if len(local) == 0 {
+		logger.Warn("TLS enabled without local certificates, only mesh proxies can connect until the control plane sends some")
+	}
+
+	return store, nil
+}
+
+// Watch the certificate files until close
+func (s *certStore) start() {
+	if len(s.files) == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <- s.stop:
+				return
+			case <- ticker.C:
+				s.reloadIfChanged()
+			}
+		}
+	}()
+}
+
+func (s *certStore) close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Reload the files if any of them changed since the last load
+func (s *certStore) reloadIfChanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, file := range s.files {
+		for _, path := range []string{file.CertFile, file.KeyFile} {
+			info, err := os.Stat(path)
+			if err != nil || !info.ModTime().Equal(s.modTimes[path]) {
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// Cert and key are often written one after the other: a mismatch is retried on the next tick
+	local, modTimes, err := s.loadFiles()
+	if err != nil {
+		s.logger.Error("failed to reload TLS certificates, keeping the current ones",
+			zap.Error(err),
+		)
+		return
+	}
+
+	s.local = local
+	s.modTimes = modTimes
+	s.rebuild()
+
+	s.logger.Info("TLS certificates reloaded",
+		zap.Int("certificates", len(local)),
+	)
+}
+
+// Read and parse every certificate file
+func (s *certStore) loadFiles() ([]*servedCert, map[string]time.Time, error) {
+	certs := make([]*servedCert, 0, len(s.files))
+	modTimes := make(map[string]time.Time, 2*len(s.files))
+
+	for _, file := range s.files {
+		for _, path := range []string{file.CertFile, file.KeyFile} {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			modTimes[path] = info.ModTime()
+		}
+
+		certPEM, err := os.ReadFile(file.CertFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyPEM, err := os.ReadFile(file.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cert, err := newServedCert(file.CertFile, certPEM, keyPEM)
+		if err != nil {
+			return nil, nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, modTimes, nil
+}
+
+// Parse the certificates of a config update, so a broken one rejects the update
+func parsePushedCertificates(configs []*pb.TlsCertificate) ([]*servedCert, error) {
+	certs := make([]*servedCert, 0, len(configs))
+	for i, config := range configs {
+		source := config.Name
+		if source == "" {
+			source = fmt.Sprintf("control plane certificate %d", i)
+		}
+
+		cert, err := newServedCert(source, []byte(config.CertPem), []byte(config.KeyPem))
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// Replace the certificates from the control plane
+func (s *certStore) setPushed(certs []*servedCert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pushed = certs
+	s.rebuild()
+}
+
+func newServedCert(source string, certPEM []byte, keyPEM []byte) (*servedCert, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate %s: %w", source, err)
+	}
+
+	names := cert.Leaf.DNSNames
+	if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+		names = []string{cert.Leaf.Subject.CommonName}
+	}
+
+	return &servedCert{source: source, cert: &cert, names: names}, nil
+}
+
+// Index all the certificates by name, the control plane ones win over the local ones
+// (caller must hold the lock)
+func (s *certStore) rebuild() {
+	index := &certIndex{
+		exact: make(map[string]*tls.Certificate),
+		wildcard: make(map[string]*tls.Certificate),
+	}
+
+	now := time.Now()
+	for _, certs := range [][]*servedCert{s.local, s.pushed} {
+		for _, served := range certs {
+			if now.After(served.cert.Leaf.NotAfter) {
+				s.logger.Warn("serving an expired TLS certificate",
+					zap.String("certificate", served.source),
+					zap.Time("not_after", served.cert.Leaf.NotAfter),
+				)
+			}
+
+			for _, name := range served.names {
+				name = strings.ToLower(name)
+				if domain, ok := strings.CutPrefix(name, "*."); ok {
+					index.wildcard[domain] = served.cert
+				} else {
+					index.exact[name] = served.cert
+				}
+			}
+
+			// The first local certificate, or the first pushed one without local ones
+			if index.fallback == nil {
+				index.fallback = served.cert
+			}
+		}
+	}
+
+	s.index.Store(index)
+}
+
+// Pick the certificate for the name the client asked for (SNI)
+func (s *certStore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	index := s.index.Load()
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+
+	if cert, ok := index.exact[name]; ok {
+		return cert, nil
+	}
+
+	// A wildcard only covers one label: "*.example.com" serves "api.example.com", not "a.b.example.com"
+	if _, domain, ok := strings.Cut(name, "."); ok {
+		if cert, ok := index.wildcard[domain]; ok {
+			return cert, nil
+		}
+	}
+
+	if index.fallback == nil {
+		return nil, fmt.Errorf("no certificate for %q", hello.ServerName)
+	}
+	return index.fallback, nil
+}